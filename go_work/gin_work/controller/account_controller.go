@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"fmt"
+	"gin_work/mailer"
+	"gin_work/models"
+	"gin_work/response"
+	"gin_work/setting"
+	"gin_work/toolkit"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/mail"
+	"net/url"
+	"time"
+)
+
+// 一次性令牌有效期
+const (
+	verifyEmailTTL   = 24 * time.Hour
+	resetPasswordTTL = 30 * time.Minute
+)
+
+// 忘记密码请求
+type forgotForm struct {
+	Email string `json:"email" binding:"required"`
+}
+
+// 重置密码请求
+type resetForm struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+//验证邮箱
+
+func VerifyEmailHandler(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		response.FailWithMsg(c, "token required")
+		return
+	}
+	userId, err := models.ConsumeUserToken(models.TokenVerifyEmail, token)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	err = models.MarkEmailVerified(userId)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithMsg(c, "email verified")
+}
+
+//重新发送验证邮件
+
+func ResendVerifyEmailHandler(c *gin.Context) {
+	user := toolkit.CurrentUser(c)
+	if user.Email == nil || user.EmailVerified {
+		response.FailWithMsg(c, "no email to verify")
+		return
+	}
+	if err := sendVerifyEmail(user); err != nil {
+		response.FailWithMsg(c, "send email failed")
+		return
+	}
+	response.OkWithMsg(c, "email sent")
+}
+
+//忘记密码，向邮箱发送重置链接
+//只向已验证的邮箱发送，无论邮箱是否存在都返回成功，避免泄露注册信息
+
+func ForgotPasswordHandler(c *gin.Context) {
+	var form forgotForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		response.FailWithMsg(c, "参数错误")
+		return
+	}
+	user, err := models.GetUserByEmail(form.Email)
+	if err == nil && user.EmailVerified {
+		if err = sendResetEmail(user); err != nil {
+			log.Printf("send reset email to %s failed, err:%v", form.Email, err)
+		}
+	}
+	response.OkWithMsg(c, "email sent")
+}
+
+//重置密码，成功后该用户在所有设备上的登录失效
+
+func ResetPasswordHandler(c *gin.Context) {
+	var form resetForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		response.FailWithMsg(c, "参数错误")
+		return
+	}
+	userId, err := models.ConsumeUserToken(models.TokenResetPassword, form.Token)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	err = models.SetPassword(userId, form.Password)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	if user, err := models.GetUserById(userId); err == nil {
+		_ = models.RevokeUserTokens(user.UserName)
+	}
+	response.OkWithMsg(c, "password reset")
+}
+
+// 发送邮箱验证邮件
+func sendVerifyEmail(user *models.User) error {
+	token, err := toolkit.RandomToken()
+	if err != nil {
+		return err
+	}
+	if err = models.CreateUserToken(user.UserId, models.TokenVerifyEmail, token, verifyEmailTTL); err != nil {
+		return err
+	}
+	link := fmt.Sprintf("%s/user/verify?token=%s", setting.Conf.BaseURL, url.QueryEscape(token))
+	return mailer.Send(mailer.Message{
+		To:      user.EmailAddress(),
+		Subject: "验证你的邮箱",
+		Body:    fmt.Sprintf("%s，你好：\n\n请在24小时内打开以下链接完成邮箱验证：\n%s\n", user.UserName, link),
+	})
+}
+
+// 发送重置密码邮件
+func sendResetEmail(user *models.User) error {
+	token, err := toolkit.RandomToken()
+	if err != nil {
+		return err
+	}
+	if err = models.CreateUserToken(user.UserId, models.TokenResetPassword, token, resetPasswordTTL); err != nil {
+		return err
+	}
+	return mailer.Send(mailer.Message{
+		To:      user.EmailAddress(),
+		Subject: "重置密码",
+		Body: fmt.Sprintf("%s，你好：\n\n你的重置密码令牌为（30分钟内有效，只能使用一次）：\n%s\n\n如果不是你本人操作，请忽略这封邮件。\n",
+			user.UserName, token),
+	})
+}
+
+// 校验邮箱格式
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
@@ -0,0 +1,29 @@
+package controller
+
+import (
+	"gin_work/models"
+	"gin_work/response"
+	"github.com/gin-gonic/gin"
+)
+
+// 修改角色请求
+type roleForm struct {
+	UserName string `json:"userName" binding:"required"`
+	Role     string `json:"role" binding:"required"`
+}
+
+// 修改用户角色
+func SetUserRoleHandler(c *gin.Context) {
+	var form roleForm
+	err := c.ShouldBindJSON(&form)
+	if err != nil {
+		response.FailWithMsg(c, "参数错误")
+		return
+	}
+	err = models.SetUserRole(form.UserName, form.Role)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+	} else {
+		response.OkWithMsg(c, "修改成功")
+	}
+}
@@ -1,10 +1,15 @@
 package controller
 
 import (
+	"errors"
+	"gin_work/markdown"
 	"gin_work/models"
+	"gin_work/moderation"
 	"gin_work/response"
+	"gin_work/toolkit"
 	"github.com/gin-gonic/gin"
 	"strconv"
+	"time"
 )
 
 // 创建博客
@@ -15,15 +20,48 @@ func CreateBlogHandler(c *gin.Context) {
 	err := c.ShouldBind(&blog)
 
 	if err != nil {
-		println(err.Error())
+		response.FailWithMsg(c, "参数错误")
 		return
 	}
+	// 作者信息来自登录令牌，不信任请求中的内容
+	user := toolkit.CurrentUser(c)
+	blog.UserId = user.UserId
+	blog.UserName = user.UserName
+	// 以下字段只能由服务端设置
+	blog.BlogId = 0
+	blog.Version = 0
+	blog.CreatedAt = time.Time{}
+	blog.UpdatedAt = time.Time{}
+	blog.Tags = nil
+	blog.Rendered = nil
+	blog.LikeCount = 0
+	blog.ViewCount = 0
+	blog.DeletedAt = nil
+	// 默认立即发布，也可以保存为草稿或定时发布
+	if err = models.PrepareBlogStatus(&blog, time.Now()); err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	content := &moderation.Content{Kind: moderation.KindBlog, Title: blog.Title, Text: blog.Content}
+	result, ok := moderate(c, content)
+	if !ok {
+		return
+	}
+	// 需要人工审核时先不公开，立即发布的博客在审核通过时确定发布时间
+	if result.Verdict == moderation.Hold && holdsBlog(blog.Status) {
+		if blog.Status == models.BlogPublished {
+			blog.PublishAt = nil
+		}
+		blog.Status = models.BlogPending
+		blog.HeldReasons = result.Reasons
+	}
 
 	err = models.CreateBlog(&blog)
 
 	if err != nil {
-		response.FailWithMsg(c, "blog create fail")
+		response.FailWithMsg(c, err.Error())
 	} else {
+		moderated(c, content)
 		response.OkWithData(c, blog)
 	}
 }
@@ -34,18 +72,43 @@ func UpdateBlogHandler(c *gin.Context) {
 	id, ok := c.Params.Get("id")
 	if !ok {
 		response.FailWithMsg(c, "id not found")
+		return
 	}
 	idiot, _ := strconv.Atoi(id)
+	// 只有作者本人或管理员可以修改
+	origin, err := models.GetABlog(idiot)
+	if err != nil {
+		response.FailWithMsg(c, "blog not found")
+		return
+	}
+	if !toolkit.CanModify(c, origin.UserId) {
+		response.FailWithCode(c, toolkit.CodeForbidden)
+		return
+	}
 	var blog models.Blog
 
-	err := c.ShouldBind(&blog)
+	err = c.ShouldBind(&blog)
 	if err != nil {
-		println(err.Error())
+		response.FailWithMsg(c, "参数错误")
 		return
 	}
-	err = models.UpdateBlog(idiot, &blog)
-	if err != nil {
-		response.FailWithMsg(c, "blog update fail")
+	// 必须提交读取时的版本号，避免覆盖他人的修改
+	if blog.Version == 0 {
+		response.FailWithMsg(c, "version required")
+		return
+	}
+	result, ok := moderate(c, &moderation.Content{Kind: moderation.KindBlog, Title: blog.Title, Text: blog.Content, Update: true})
+	if !ok {
+		return
+	}
+	if result.Verdict == moderation.Hold && holdsBlog(origin.Status) {
+		blog.HeldReasons = result.Reasons
+	}
+	err = models.UpdateBlog(idiot, &blog, toolkit.CurrentUser(c))
+	if err == models.ErrVersionConflict {
+		versionConflict(c, idiot)
+	} else if err != nil {
+		response.FailWithMsg(c, err.Error())
 	} else {
 		response.OkWithData(c, gin.H{
 			"blog": blog,
@@ -58,27 +121,48 @@ func DeleteBlogHandler(c *gin.Context) {
 	id, ok := c.Params.Get("id")
 	if !ok {
 		response.FailWithMsg(c, "id not found")
+		return
 	}
 	idiot, _ := strconv.Atoi(id)
+	// 只有作者本人或管理员可以删除
+	blog, err := models.GetABlog(idiot)
+	if err != nil {
+		response.FailWithMsg(c, "blog not found")
+		return
+	}
+	if !toolkit.CanModify(c, blog.UserId) {
+		response.FailWithCode(c, toolkit.CodeForbidden)
+		return
+	}
 
-	err := models.DelBlog(idiot)
+	err = models.DelBlog(idiot)
 	if err != nil {
 		response.FailWithMsg(c, "blog delete fail")
 	} else {
-		response.OkWithMsg(c, "删除成功")
+		response.OkWithMsg(c, "已移入回收站")
 	}
 }
 
-// 查看所有博客
+// 分页查看博客
+// 参数: limit、cursor 或 page、sort、author、tag、category、from、to
 func GetAllBlogsHandler(c *gin.Context) {
-	var blogs []models.Blog
-
-	err := models.GetAllBlog(&blogs)
+	query, err := parseBlogQuery(c)
 	if err != nil {
-		response.FailWithMsg(c, "blog get fail")
-	} else {
-		response.OkWithData(c, blogs)
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	page, err := models.ListBlogs(query)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
 	}
+	response.OkWithPage(c, response.PageData{
+		List:       page.List,
+		Total:      page.Total,
+		Limit:      query.Limit,
+		Page:       query.Page,
+		NextCursor: page.NextCursor,
+	})
 }
 
 // 查看单个博客
@@ -88,25 +172,131 @@ func GetBlogByIdHandler(c *gin.Context) {
 		response.FailWithMsg(c, "id not found")
 	}
 	idiot, _ := strconv.Atoi(id)
-	// 从数据库中读取所有博客
-	blog, err := models.GetABlog(idiot)
-	if err != nil {
+	// 从数据库中读取博客，未发布的博客只有作者本人和管理员可以查看
+	blog, ok := visibleBlog(c, idiot)
+	if !ok {
 		response.FailWithMsg(c, "blog get fail")
 	} else {
+		// 作者本人的阅读不计入浏览数
+		if blog.UserId != toolkit.CurrentUser(c).UserId {
+			models.RecordView(blog.BlogId)
+		}
+		// Content 保留Markdown原文，同时返回渲染好的HTML、目录、阅读时间和摘要
+		blog.Rendered = markdown.Render(blog.Content)
 		response.OkWithData(c, blog)
 	}
 }
 
+// 读取当前用户可见的博客
+func visibleBlog(c *gin.Context, blogId int) (*models.Blog, bool) {
+	blog, err := models.GetABlog(blogId)
+	if err != nil {
+		return nil, false
+	}
+	if !blog.IsPublic() && !toolkit.CanModify(c, blog.UserId) {
+		return nil, false
+	}
+	return blog, true
+}
+
 // 博客搜索
+// 结果按相关度排序，title和snippet为带<em>高亮的HTML片段，参数: limit、page
 func SearchBlogsHandler(c *gin.Context) {
 	query, ok := c.Params.Get("query")
 	if !ok {
 		response.FailWithMsg(c, "query not found")
+		return
 	}
-	blogList, err := models.SearchBlog(query)
+	limit, page := pageParams(c)
+	hits, blogs, total, err := models.SearchBlog(query, toolkit.CurrentUser(c).UserId, (page-1)*limit, limit)
 	if err != nil {
 		response.FailWithMsg(c, "blog search fail")
-	} else {
-		response.OkWithData(c, blogList)
+		return
+	}
+	list := make([]gin.H, 0, len(hits))
+	for _, hit := range hits {
+		blog, ok := blogs[hit.ID]
+		if !ok {
+			continue
+		}
+		list = append(list, gin.H{
+			"blog":      blog,
+			"score":     hit.Score,
+			"highlight": gin.H{"title": hit.Title, "snippet": hit.Snippet},
+		})
+	}
+	response.OkWithPage(c, response.PageData{List: list, Total: total, Limit: limit, Page: page})
+}
+
+// 解析页码分页参数 limit、page，不合法时使用默认值
+func pageParams(c *gin.Context) (limit, page int) {
+	limit, page = models.DefaultPageSize, 1
+	if v := c.Query("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= models.MaxPageSize {
+			limit = n
+		}
+	}
+	if v := c.Query("page"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			page = n
+		}
+	}
+	return limit, page
+}
+
+// 解析博客列表的查询参数
+func parseBlogQuery(c *gin.Context) (query models.BlogQuery, err error) {
+	query = models.BlogQuery{
+		Limit:  models.DefaultPageSize,
+		Cursor: c.Query("cursor"),
+		Sort:   c.DefaultQuery("sort", "-created_at"),
+		Author: c.Query("author"),
+		Tag:    c.Query("tag"),
+	}
+	if user := toolkit.CurrentUser(c); user != nil {
+		query.ViewerId = user.UserId
+	}
+	if v := c.Query("limit"); v != "" {
+		if query.Limit, err = strconv.Atoi(v); err != nil || query.Limit <= 0 {
+			return query, errors.New("invalid limit")
+		}
+		if query.Limit > models.MaxPageSize {
+			query.Limit = models.MaxPageSize
+		}
+	}
+	if v := c.Query("page"); v != "" {
+		if query.Page, err = strconv.Atoi(v); err != nil || query.Page <= 0 {
+			return query, errors.New("invalid page")
+		}
+	}
+	if v := c.Query("category"); v != "" {
+		if query.CategoryId, err = strconv.Atoi(v); err != nil || query.CategoryId <= 0 {
+			return query, errors.New("invalid category")
+		}
+	}
+	if !models.ValidBlogSort(query.Sort) {
+		return query, errors.New("invalid sort")
+	}
+	if query.From, err = parseTimeParam(c.Query("from")); err != nil {
+		return query, errors.New("invalid from")
+	}
+	if query.To, err = parseTimeParam(c.Query("to")); err != nil {
+		return query, errors.New("invalid to")
+	}
+	// 只有日期时包含当天
+	if len(c.Query("to")) == len("2006-01-02") {
+		query.To = query.To.AddDate(0, 0, 1)
+	}
+	return query, nil
+}
+
+// 时间参数支持 RFC3339 和 2006-01-02 两种格式，空字符串返回零值
+func parseTimeParam(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
 	}
+	return time.ParseInLocation("2006-01-02", v, time.Local)
 }
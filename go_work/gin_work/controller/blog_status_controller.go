@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"gin_work/models"
+	"gin_work/moderation"
+	"gin_work/response"
+	"gin_work/toolkit"
+	"github.com/gin-gonic/gin"
+	"strconv"
+	"time"
+)
+
+// 发布请求，publishAt为空时立即发布
+type publishForm struct {
+	PublishAt *time.Time `form:"publishAt" json:"publishAt" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+// 发布或定时发布博客
+func PublishBlogHandler(c *gin.Context) {
+	blog, ok := ownedBlog(c)
+	if !ok {
+		return
+	}
+	var form publishForm
+	if err := c.ShouldBind(&form); err != nil {
+		response.FailWithMsg(c, "参数错误")
+		return
+	}
+	// 待审核的博客只能由管理员在审核队列中处理
+	if blog.Status == models.BlogPending {
+		response.FailWithMsg(c, "blog is pending review")
+		return
+	}
+	result, ok := moderate(c, &moderation.Content{Kind: moderation.KindBlog, Title: blog.Title, Text: blog.Content, Update: true})
+	if !ok {
+		return
+	}
+	if result.Verdict == moderation.Hold {
+		if err := models.HoldBlog(blog.BlogId, form.PublishAt, result.Reasons, toolkit.CurrentUser(c)); err != nil {
+			response.FailWithMsg(c, err.Error())
+			return
+		}
+		respondBlog(c, blog.BlogId)
+		return
+	}
+	if err := models.PublishBlog(blog.BlogId, form.PublishAt); err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	respondBlog(c, blog.BlogId)
+}
+
+// 取消发布，博客回到草稿
+func UnpublishBlogHandler(c *gin.Context) {
+	blog, ok := ownedBlog(c)
+	if !ok {
+		return
+	}
+	if err := models.UnpublishBlog(blog.BlogId); err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	respondBlog(c, blog.BlogId)
+}
+
+// 归档博客
+func ArchiveBlogHandler(c *gin.Context) {
+	blog, ok := ownedBlog(c)
+	if !ok {
+		return
+	}
+	if err := models.ArchiveBlog(blog.BlogId); err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	respondBlog(c, blog.BlogId)
+}
+
+// 读取路径中id对应的博客，并校验当前用户是作者本人或管理员
+// 校验失败时已经写入响应
+func ownedBlog(c *gin.Context) (*models.Blog, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.FailWithMsg(c, "id not found")
+		return nil, false
+	}
+	blog, err := models.GetABlog(id)
+	if err != nil {
+		response.FailWithMsg(c, "blog not found")
+		return nil, false
+	}
+	if !toolkit.CanModify(c, blog.UserId) {
+		response.FailWithCode(c, toolkit.CodeForbidden)
+		return nil, false
+	}
+	return blog, true
+}
+
+// 返回博客的最新内容
+func respondBlog(c *gin.Context, blogId int) {
+	blog, err := models.GetABlog(blogId)
+	if err != nil {
+		response.FailWithMsg(c, "blog get fail")
+		return
+	}
+	response.OkWithData(c, blog)
+}
@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"gin_work/models"
+	"gin_work/response"
+	"github.com/gin-gonic/gin"
+	"strconv"
+)
+
+// 分类树
+func CategoryTreeHandler(c *gin.Context) {
+	tree, err := models.CategoryTree()
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithData(c, tree)
+}
+
+// 新建或修改分类请求，parentId 为0表示顶级分类
+type categoryForm struct {
+	Name     string `json:"name" binding:"required"`
+	ParentId int    `json:"parentId"`
+}
+
+// 新建分类
+func CreateCategoryHandler(c *gin.Context) {
+	var form categoryForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		response.FailWithMsg(c, "参数错误")
+		return
+	}
+	category, err := models.CreateCategory(form.Name, form.ParentId)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithData(c, category)
+}
+
+// 修改分类的名字或上级分类
+func UpdateCategoryHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.FailWithMsg(c, "id not found")
+		return
+	}
+	var form categoryForm
+	if err = c.ShouldBindJSON(&form); err != nil {
+		response.FailWithMsg(c, "参数错误")
+		return
+	}
+	category, err := models.UpdateCategory(id, form.Name, form.ParentId)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithData(c, category)
+}
+
+// 删除分类
+func DeleteCategoryHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.FailWithMsg(c, "id not found")
+		return
+	}
+	if err = models.DelCategory(id); err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithMsg(c, "删除成功")
+}
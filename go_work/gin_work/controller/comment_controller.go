@@ -2,41 +2,179 @@ package controller
 
 import (
 	"gin_work/models"
+	"gin_work/moderation"
 	"gin_work/response"
+	"gin_work/setting"
+	"gin_work/toolkit"
 	"github.com/gin-gonic/gin"
 	"strconv"
+	"strings"
+	"time"
 )
 
+// 未配置时评论允许修改的时长
+const defaultCommentEditWindow = 15 * time.Minute
+
 // 评论新增
+// 开启审核时，他人的评论需要博客作者审核通过后才会公开
 func CommentsAddHandler(c *gin.Context) {
 	var comments models.Comment
 	err := c.BindJSON(&comments)
+	if err != nil {
+		return
+	}
+	if strings.TrimSpace(comments.Content) == "" {
+		response.FailWithMsg(c, "评论内容不能为空")
+		return
+	}
+	// 只能评论可见的博客
+	blog, ok := visibleBlog(c, comments.BlogID)
+	if !ok {
+		response.FailWithMsg(c, "博客不存在")
+		return
+	}
+	// 评论者信息来自登录令牌，不信任请求中的内容
+	user := toolkit.CurrentUser(c)
+	comments.UserId = user.UserId
+	comments.UserName = user.UserName
+	comments.Status = models.CommentApproved
+	if needsModeration(c, blog) {
+		comments.Status = models.CommentPending
+	}
+	content := &moderation.Content{Kind: moderation.KindComment, Text: comments.Content}
+	result, ok := moderate(c, content)
+	if !ok {
+		return
+	}
+	if result.Verdict == moderation.Hold {
+		comments.Status = models.CommentPending
+		comments.HeldReasons = result.Reasons
+	}
+	// 以下字段只能由服务端设置
+	comments.CommentId = 0
+	comments.CreatedAt = time.Time{}
+	comments.UpdatedAt = time.Time{}
+	comments.EditedAt = nil
+	comments.RemovedAt = nil
 	err = models.CreateComment(&comments)
 
 	if err != nil {
-		response.FailWithMsg(c, "增加评论失败")
+		response.FailWithMsg(c, err.Error())
 	} else {
-		response.OkWithMsg(c, "新增成功")
+		moderated(c, content)
+		// 需要人工审核的评论在审核通过时再通知
+		if len(comments.HeldReasons) == 0 {
+			models.NotifyNewComment(blog, &comments)
+		}
+		response.OkWithData(c, comments)
 	}
 }
 
 // 获取评论列表
+// 参数 mode: tree 返回评论树(默认)，flat 按时间分页返回，分页参数 limit、page
 func CommentGetHandler(c *gin.Context) {
 
 	blogId, ok := c.Params.Get("id")
 
 	if !ok {
 		response.FailWithMsg(c, "参数错误")
+		return
 	}
 	blogIdiot, _ := strconv.Atoi(blogId)
-	var commentList []models.Comment
-	err := models.GetComment(blogIdiot, &commentList)
+	blog, ok := visibleBlog(c, blogIdiot)
+	if !ok {
+		response.FailWithMsg(c, "博客不存在")
+		return
+	}
+	query := models.CommentQuery{
+		BlogId:    blogIdiot,
+		ViewerId:  toolkit.CurrentUser(c).UserId,
+		Moderator: toolkit.CanModify(c, blog.UserId),
+	}
+	switch c.DefaultQuery("mode", "tree") {
+	case "tree":
+		commentList, _, err := models.GetComment(query)
+		if err != nil {
+			response.FailWithMsg(c, err.Error())
+			return
+		}
+		response.OkWithData(c, models.CommentTree(commentList))
+	case "flat":
+		query.Limit, query.Page = pageParams(c)
+		commentList, total, err := models.GetComment(query)
+		if err != nil {
+			response.FailWithMsg(c, err.Error())
+			return
+		}
+		response.OkWithPage(c, response.PageData{List: commentList, Total: total, Limit: query.Limit, Page: query.Page})
+	default:
+		response.FailWithMsg(c, "invalid mode")
+	}
+}
 
+// 修改评论请求
+type commentEditForm struct {
+	Content string `json:"content" binding:"required"`
+}
+
+// 修改评论，只有评论者本人可以在发布后的一段时间内修改
+func CommentEditHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		response.FailWithMsg(c, "<UNK>")
-	} else {
-		response.OkWithData(c, commentList)
+		response.FailWithMsg(c, "参数错误")
+		return
+	}
+	var form commentEditForm
+	if err = c.ShouldBindJSON(&form); err != nil || strings.TrimSpace(form.Content) == "" {
+		response.FailWithMsg(c, "评论内容不能为空")
+		return
+	}
+	comment, err := models.GetAComment(id)
+	if err != nil || comment.IsRemoved() {
+		response.FailWithMsg(c, "评论不存在")
+		return
+	}
+	if comment.UserId != toolkit.CurrentUser(c).UserId {
+		response.FailWithCode(c, toolkit.CodeForbidden)
+		return
+	}
+	window := setting.Conf.EditWindow
+	if window <= 0 {
+		window = defaultCommentEditWindow
+	}
+	if time.Since(comment.CreatedAt) > window {
+		response.FailWithMsg(c, "评论已超过可修改时间")
+		return
+	}
+	blog, err := models.GetABlog(comment.BlogID)
+	if err != nil {
+		response.FailWithMsg(c, "博客不存在")
+		return
+	}
+	// 需要审核时，修改后的内容重新进入审核
+	status := comment.Status
+	if needsModeration(c, blog) {
+		status = models.CommentPending
+	}
+	result, ok := moderate(c, &moderation.Content{Kind: moderation.KindComment, Text: form.Content, Update: true})
+	if !ok {
+		return
+	}
+	var held []string
+	if result.Verdict == moderation.Hold {
+		status = models.CommentPending
+		held = result.Reasons
+	}
+	if err = models.EditComment(comment, form.Content, status, held); err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
 	}
+	comment, err = models.GetAComment(id)
+	if err != nil {
+		response.FailWithMsg(c, "评论不存在")
+		return
+	}
+	response.OkWithData(c, comment)
 }
 
 // 删除评论
@@ -45,13 +183,106 @@ func CommentDeleteHandler(c *gin.Context) {
 
 	if !ok {
 		response.FailWithMsg(c, "参数错误")
+		return
 	}
 	idiot, _ := strconv.Atoi(id)
+	// 评论者本人、博客作者或管理员可以删除
+	comment, err := models.GetAComment(idiot)
+	if err != nil || comment.IsRemoved() {
+		response.FailWithMsg(c, "评论不存在")
+		return
+	}
+	if !toolkit.CanModify(c, comment.UserId) && !canModerate(c, comment) {
+		response.FailWithCode(c, toolkit.CodeForbidden)
+		return
+	}
 
-	err := models.DelComment(idiot)
+	err = models.DelComment(idiot)
 	if err != nil {
 		response.FailWithMsg(c, err.Error())
 	} else {
 		response.OkWithMsg(c, "删除成功")
 	}
 }
+
+// 待审核评论列表，博客作者看到自己博客下的评论，管理员看到全部，参数: limit、page
+func CommentPendingHandler(c *gin.Context) {
+	limit, page := pageParams(c)
+	user := toolkit.CurrentUser(c)
+	ownerId := user.UserId
+	if user.Role == models.RoleAdmin {
+		ownerId = 0
+	}
+	commentList, total, err := models.PendingComments(ownerId, (page-1)*limit, limit)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithPage(c, response.PageData{List: commentList, Total: total, Limit: limit, Page: page})
+}
+
+// 审核通过评论
+func CommentApproveHandler(c *gin.Context) {
+	moderateComment(c, models.CommentApproved)
+}
+
+// 拒绝评论
+func CommentRejectHandler(c *gin.Context) {
+	moderateComment(c, models.CommentRejected)
+}
+
+// 修改评论的审核状态，只有博客作者或管理员可以审核
+func moderateComment(c *gin.Context, status string) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.FailWithMsg(c, "参数错误")
+		return
+	}
+	comment, err := models.GetAComment(id)
+	if err != nil || comment.IsRemoved() {
+		response.FailWithMsg(c, "评论不存在")
+		return
+	}
+	if !canModerate(c, comment) {
+		response.FailWithCode(c, toolkit.CodeForbidden)
+		return
+	}
+	if err = models.SetCommentStatus(id, status); err != nil {
+		if err == models.ErrUnderReview {
+			response.FailWithMsg(c, "评论正在等待管理员审核")
+			return
+		}
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	// 回复审核通过后才通知被回复的评论者
+	if status == models.CommentApproved && comment.Status != models.CommentApproved {
+		if blog, err := models.GetABlog(comment.BlogID); err == nil {
+			comment.Status = status
+			models.NotifyCommentReply(blog, comment)
+		}
+	}
+	response.OkWithMsg(c, "审核成功")
+}
+
+// 当前用户是否可以审核评论：评论所在博客的作者或管理员
+func canModerate(c *gin.Context, comment *models.Comment) bool {
+	blog, err := models.GetABlog(comment.BlogID)
+	if err != nil {
+		return false
+	}
+	return toolkit.CanModify(c, blog.UserId)
+}
+
+// 博客作者开启审核时，除博客作者和管理员外的评论都需要审核
+// 作者没有设置时使用站点的默认设置
+func needsModeration(c *gin.Context, blog *models.Blog) bool {
+	if toolkit.CanModify(c, blog.UserId) {
+		return false
+	}
+	owner, err := models.GetUserById(blog.UserId)
+	if err != nil {
+		return setting.Conf.ModerateComments
+	}
+	return owner.ModeratesComments(setting.Conf.ModerateComments)
+}
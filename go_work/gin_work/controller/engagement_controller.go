@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"gin_work/models"
+	"gin_work/response"
+	"gin_work/toolkit"
+	"github.com/gin-gonic/gin"
+	"strconv"
+	"time"
+)
+
+// 点赞
+func LikeBlogHandler(c *gin.Context) {
+	likeBlog(c, true)
+}
+
+// 取消点赞
+func UnlikeBlogHandler(c *gin.Context) {
+	likeBlog(c, false)
+}
+
+func likeBlog(c *gin.Context, like bool) {
+	blog, ok := engagedBlog(c)
+	if !ok {
+		return
+	}
+	userId := toolkit.CurrentUser(c).UserId
+	var count int
+	var err error
+	if like {
+		count, err = models.LikeBlog(blog.BlogId, userId)
+	} else {
+		count, err = models.UnlikeBlog(blog.BlogId, userId)
+	}
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithData(c, gin.H{"liked": like, "likeCount": count})
+}
+
+// 收藏
+func BookmarkBlogHandler(c *gin.Context) {
+	blog, ok := engagedBlog(c)
+	if !ok {
+		return
+	}
+	if err := models.AddBookmark(blog.BlogId, toolkit.CurrentUser(c).UserId); err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithData(c, gin.H{"bookmarked": true})
+}
+
+// 取消收藏，博客已经不可见时也允许取消
+func UnbookmarkBlogHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.FailWithMsg(c, "id not found")
+		return
+	}
+	if err = models.DelBookmark(id, toolkit.CurrentUser(c).UserId); err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithData(c, gin.H{"bookmarked": false})
+}
+
+// 热门博客，参数: limit、page
+func HotBlogsHandler(c *gin.Context) {
+	limit, page := pageParams(c)
+	list, err := models.HotBlogs(time.Now(), (page-1)*limit, limit)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithPage(c, response.PageData{List: list, Limit: limit, Page: page})
+}
+
+// 我的收藏，参数: limit、page
+func MyBookmarksHandler(c *gin.Context) {
+	limit, page := pageParams(c)
+	list, total, err := models.GetBookmarks(toolkit.CurrentUser(c).UserId, (page-1)*limit, limit)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithPage(c, response.PageData{List: list, Total: total, Limit: limit, Page: page})
+}
+
+// 读取路径中id对应的博客，只能点赞和收藏当前用户可见的博客
+func engagedBlog(c *gin.Context) (*models.Blog, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.FailWithMsg(c, "id not found")
+		return nil, false
+	}
+	blog, ok := visibleBlog(c, id)
+	if !ok {
+		response.FailWithMsg(c, "blog not found")
+		return nil, false
+	}
+	return blog, true
+}
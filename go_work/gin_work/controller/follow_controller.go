@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"gin_work/models"
+	"gin_work/response"
+	"gin_work/toolkit"
+	"github.com/gin-gonic/gin"
+	"strconv"
+)
+
+// 关注用户
+func FollowUserHandler(c *gin.Context) {
+	followee, err := models.GetUserByName(c.Param("name"))
+	if err != nil {
+		response.FailWithMsg(c, "user not found")
+		return
+	}
+	if err = models.FollowUser(toolkit.CurrentUser(c).UserId, followee.UserId); err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithData(c, gin.H{"following": true})
+}
+
+// 取消关注
+func UnfollowUserHandler(c *gin.Context) {
+	followee, err := models.GetUserByName(c.Param("name"))
+	if err != nil {
+		response.FailWithMsg(c, "user not found")
+		return
+	}
+	if err = models.UnfollowUser(toolkit.CurrentUser(c).UserId, followee.UserId); err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithData(c, gin.H{"following": false})
+}
+
+// 粉丝列表，参数: limit、page
+func FollowersHandler(c *gin.Context) {
+	followList(c, models.GetFollowers)
+}
+
+// 关注列表，参数: limit、page
+func FollowingHandler(c *gin.Context) {
+	followList(c, models.GetFollowing)
+}
+
+func followList(c *gin.Context, list func(userId, offset, limit int) ([]models.PublicProfile, int, error)) {
+	user, err := models.GetUserByName(c.Param("name"))
+	if err != nil {
+		response.FailWithMsg(c, "user not found")
+		return
+	}
+	limit, page := pageParams(c)
+	profiles, total, err := list(user.UserId, (page-1)*limit, limit)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithPage(c, response.PageData{List: profiles, Total: total, Limit: limit, Page: page})
+}
+
+// 关注的作者发布的博客，按发布时间倒序，参数: limit、cursor
+func FeedHandler(c *gin.Context) {
+	limit := models.DefaultPageSize
+	if v := c.Query("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= models.MaxPageSize {
+			limit = n
+		}
+	}
+	blogs, next, err := models.GetFeed(toolkit.CurrentUser(c).UserId, c.Query("cursor"), limit)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithPage(c, response.PageData{List: blogs, Limit: limit, NextCursor: next})
+}
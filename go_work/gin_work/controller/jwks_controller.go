@@ -0,0 +1,12 @@
+package controller
+
+import (
+	"gin_work/toolkit"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// JWKS 按标准格式直接返回，不使用统一的响应结构
+func JWKSHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, toolkit.JWKS())
+}
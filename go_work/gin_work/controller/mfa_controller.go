@@ -0,0 +1,180 @@
+package controller
+
+import (
+	"gin_work/models"
+	"gin_work/response"
+	"gin_work/security"
+	"gin_work/toolkit"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+// 验证器App中显示的发行方
+const totpIssuer = "go-blog"
+
+// 恢复码数量
+const recoveryCodeCount = 10
+
+// 提交验证码的请求
+type mfaCodeForm struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// 登录第二步的请求
+type mfaVerifyForm struct {
+	MFAToken string `json:"mfaToken" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+//开始绑定两步验证，返回密钥和otpauth地址
+
+func MFAEnrollHandler(c *gin.Context) {
+	user := toolkit.CurrentUser(c)
+	if user.TOTPEnabled {
+		response.FailWithMsg(c, "two-factor already enabled")
+		return
+	}
+	secret, err := toolkit.GenerateTOTPSecret()
+	if err != nil {
+		response.FailWithMsg(c, "secret generate failed")
+		return
+	}
+	err = models.SetTOTPSecret(user.UserId, secret)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithData(c, gin.H{
+		"secret": secret,
+		"uri":    toolkit.TOTPURI(totpIssuer, user.UserName, secret),
+	})
+}
+
+//确认验证码，开启两步验证并返回恢复码，恢复码只返回这一次
+
+func MFAConfirmHandler(c *gin.Context) {
+	var form mfaCodeForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		response.FailWithMsg(c, "参数错误")
+		return
+	}
+	user := toolkit.CurrentUser(c)
+	if user.TOTPEnabled || user.TOTPSecret == "" {
+		response.FailWithMsg(c, "no pending two-factor secret")
+		return
+	}
+	step, ok := toolkit.VerifyTOTP(user.TOTPSecret, form.Code, time.Now())
+	if !ok {
+		response.FailWithMsg(c, "invalid code")
+		return
+	}
+	codes, err := toolkit.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		response.FailWithMsg(c, "recovery codes generate failed")
+		return
+	}
+	err = models.EnableTOTP(user.UserId, step, codes)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithData(c, gin.H{"recoveryCodes": codes})
+}
+
+//关闭两步验证，需要验证码或恢复码
+
+func MFADisableHandler(c *gin.Context) {
+	var form mfaCodeForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		response.FailWithMsg(c, "参数错误")
+		return
+	}
+	user := toolkit.CurrentUser(c)
+	if !user.TOTPEnabled {
+		response.FailWithMsg(c, "two-factor not enabled")
+		return
+	}
+	if err := verifySecondFactor(user, form.Code); err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	if err := models.DisableTOTP(user.UserId); err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithMsg(c, "two-factor disabled")
+}
+
+//重新生成恢复码，需要验证器App中的验证码
+
+func MFARecoveryCodesHandler(c *gin.Context) {
+	var form mfaCodeForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		response.FailWithMsg(c, "参数错误")
+		return
+	}
+	user := toolkit.CurrentUser(c)
+	if !user.TOTPEnabled {
+		response.FailWithMsg(c, "two-factor not enabled")
+		return
+	}
+	step, ok := toolkit.VerifyTOTP(user.TOTPSecret, form.Code, time.Now())
+	if !ok || models.UseTOTPStep(user.UserId, step) != nil {
+		response.FailWithMsg(c, "invalid code")
+		return
+	}
+	codes, err := toolkit.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		response.FailWithMsg(c, "recovery codes generate failed")
+		return
+	}
+	if err = models.SetRecoveryCodes(user.UserId, codes); err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithData(c, gin.H{"recoveryCodes": codes})
+}
+
+//登录第二步，提交中间令牌和验证码（或恢复码）换取正式令牌
+
+func MFAVerifyHandler(c *gin.Context) {
+	var form mfaVerifyForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		response.FailWithMsg(c, "参数错误")
+		return
+	}
+	claims, err := toolkit.ParseMFAToken(form.MFAToken)
+	if err != nil {
+		response.FailWithMsg(c, "Unauthorized")
+		return
+	}
+	// 验证码同样受登录失败次数限制
+	ip := c.ClientIP()
+	if _, err = security.Default.Check(claims.Username, ip); err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	user, err := toolkit.ClaimsUser(claims)
+	if err != nil || !user.TOTPEnabled {
+		response.FailWithMsg(c, "Unauthorized")
+		return
+	}
+	if err = verifySecondFactor(user, form.Code); err != nil {
+		_ = security.Default.Fail(claims.Username, ip)
+		_ = models.RecordLoginFailure(claims.Username, ip, "mfa: "+err.Error())
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	_ = security.Default.Success(claims.Username)
+	// 中间令牌只能使用一次
+	_ = models.RevokeAccessToken(claims.ID, claims.ExpiresAt.Time)
+	issueTokens(c, user)
+}
+
+// 校验验证码，验证码不匹配时尝试作为恢复码使用
+func verifySecondFactor(user *models.User, code string) error {
+	if step, ok := toolkit.VerifyTOTP(user.TOTPSecret, code, time.Now()); ok {
+		return models.UseTOTPStep(user.UserId, step)
+	}
+	return models.UseRecoveryCode(user, code)
+}
@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"gin_work/models"
+	"gin_work/notify"
+	"gin_work/response"
+	"gin_work/toolkit"
+	"github.com/gin-gonic/gin"
+	"io"
+	"strconv"
+	"time"
+)
+
+// SSE连接的心跳间隔，避免被代理当作空闲连接断开
+const streamHeartbeat = 25 * time.Second
+
+// 通知列表，参数: unread=1 只看未读、limit、page
+func NotificationListHandler(c *gin.Context) {
+	limit, page := pageParams(c)
+	list, total, err := models.GetNotifications(toolkit.CurrentUser(c).UserId, c.Query("unread") == "1", (page-1)*limit, limit)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithPage(c, response.PageData{List: list, Total: total, Limit: limit, Page: page})
+}
+
+// 未读通知数
+func NotificationUnreadHandler(c *gin.Context) {
+	count, err := models.UnreadNotificationCount(toolkit.CurrentUser(c).UserId)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithData(c, gin.H{"unread": count})
+}
+
+// 标记单条通知为已读
+func NotificationReadHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		response.FailWithMsg(c, "id not found")
+		return
+	}
+	if err = models.MarkNotificationsRead(toolkit.CurrentUser(c).UserId, id); err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithMsg(c, "已读")
+}
+
+// 标记全部通知为已读
+func NotificationReadAllHandler(c *gin.Context) {
+	if err := models.MarkNotificationsRead(toolkit.CurrentUser(c).UserId, 0); err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithMsg(c, "已读")
+}
+
+// 签发建立SSE连接的一次性票据，EventSource 通过 ticket 参数传递
+func NotificationTicketHandler(c *gin.Context) {
+	ticket, err := toolkit.GenerateStreamTicket(c.MustGet("Claims").(*toolkit.Claims))
+	if err != nil {
+		response.FailWithMsg(c, "token generate failed")
+		return
+	}
+	response.OkWithData(c, gin.H{"ticket": ticket, "expiresIn": int(toolkit.StreamTicketTTL.Seconds())})
+}
+
+// 通过SSE实时推送新通知
+// 连接建立后先发送 unread 事件，之后每条新通知发送一个 notification 事件；
+// 访问令牌过期或注销时服务端关闭连接，客户端刷新令牌后重新连接
+func NotificationStreamHandler(c *gin.Context) {
+	userId := toolkit.CurrentUser(c).UserId
+	ch, cancel := notify.Default.Subscribe(userId)
+	defer cancel()
+
+	claims := c.MustGet("Claims").(*toolkit.Claims)
+	expire := time.NewTimer(time.Until(claims.SessionExpiresAt()))
+	defer expire.Stop()
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// 关闭nginx的响应缓冲
+	c.Header("X-Accel-Buffering", "no")
+	count, _ := models.UnreadNotificationCount(userId)
+	c.SSEvent("unread", gin.H{"unread": count})
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				return false
+			}
+			c.SSEvent("notification", msg)
+			return true
+		case <-heartbeat.C:
+			// 连接期间访问令牌被注销时不再推送
+			if models.IsTokenRevoked(claims.SessionID()) {
+				c.SSEvent("expired", "token revoked")
+				return false
+			}
+			c.SSEvent("ping", time.Now().Unix())
+			return true
+		case <-expire.C:
+			c.SSEvent("expired", "token expired")
+			return false
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
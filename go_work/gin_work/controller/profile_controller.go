@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"gin_work/models"
+	"gin_work/response"
+	"gin_work/toolkit"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/url"
+	"unicode/utf8"
+)
+
+// 修改资料请求，字段为nil表示不修改
+type profileForm struct {
+	Email       *string `json:"email"`
+	DisplayName *string `json:"displayName"`
+	Bio         *string `json:"bio"`
+	AvatarURL   *string `json:"avatarUrl"`
+	// ModerateComments 他人的评论是否需要审核
+	ModerateComments *bool `json:"moderateComments"`
+}
+
+// 修改密码请求
+type changePasswordForm struct {
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+	NewPassword     string `json:"newPassword" binding:"required"`
+}
+
+// 注销账号请求，需要输入用户名确认
+type deleteAccountForm struct {
+	Password string `json:"password"`
+	Confirm  string `json:"confirm" binding:"required"`
+}
+
+//获取当前用户的账号信息
+
+func GetMeHandler(c *gin.Context) {
+	response.OkWithData(c, toolkit.CurrentUser(c).AccountInfo())
+}
+
+//修改当前用户的资料，修改邮箱后需要重新验证
+
+func UpdateMeHandler(c *gin.Context) {
+	var form profileForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		response.FailWithMsg(c, "参数错误")
+		return
+	}
+	user := toolkit.CurrentUser(c)
+	fields := map[string]interface{}{}
+	emailChanged := false
+	if form.Email != nil && *form.Email != user.EmailAddress() {
+		if *form.Email != "" && !validEmail(*form.Email) {
+			response.FailWithMsg(c, "invalid email")
+			return
+		}
+		if *form.Email == "" {
+			fields["email"] = nil
+		} else {
+			taken, err := models.EmailTaken(*form.Email, user.UserId)
+			if err != nil {
+				response.FailWithMsg(c, err.Error())
+				return
+			}
+			if taken {
+				response.FailWithMsg(c, models.ErrEmailTaken.Error())
+				return
+			}
+			fields["email"] = *form.Email
+		}
+		fields["email_verified"] = false
+		emailChanged = true
+	}
+	if form.DisplayName != nil {
+		if utf8.RuneCountInString(*form.DisplayName) > 64 {
+			response.FailWithMsg(c, "display name too long")
+			return
+		}
+		fields["display_name"] = *form.DisplayName
+	}
+	if form.Bio != nil {
+		if utf8.RuneCountInString(*form.Bio) > 500 {
+			response.FailWithMsg(c, "bio too long")
+			return
+		}
+		fields["bio"] = *form.Bio
+	}
+	if form.AvatarURL != nil {
+		if *form.AvatarURL != "" && !validHTTPURL(*form.AvatarURL) {
+			response.FailWithMsg(c, "invalid avatar url")
+			return
+		}
+		fields["avatar_url"] = *form.AvatarURL
+	}
+	if form.ModerateComments != nil {
+		fields["moderate_comments"] = *form.ModerateComments
+	}
+	if len(fields) > 0 {
+		if err := models.UpdateProfile(user.UserId, fields); err != nil {
+			response.FailWithMsg(c, err.Error())
+			return
+		}
+	}
+	user, err := models.GetUserById(user.UserId)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	if emailChanged && user.Email != nil {
+		if err = sendVerifyEmail(user); err != nil {
+			log.Printf("send verify email to %s failed, err:%v", *user.Email, err)
+		}
+	}
+	response.OkWithData(c, user.AccountInfo())
+}
+
+//修改密码，需要校验当前密码
+//修改成功后其他设备上的登录全部失效，并为当前设备签发新的令牌
+
+func ChangePasswordHandler(c *gin.Context) {
+	var form changePasswordForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		response.FailWithMsg(c, "参数错误")
+		return
+	}
+	user := toolkit.CurrentUser(c)
+	if !models.CheckPassword(user, form.CurrentPassword) {
+		response.FailWithMsg(c, "incorrect password")
+		return
+	}
+	if err := models.SetPassword(user.UserId, form.NewPassword); err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	_ = models.RevokeUserTokens(user.UserName)
+	// 令牌版本已经递增，重新加载用户后为当前设备签发新的令牌
+	user, err := models.GetUserById(user.UserId)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	issueTokens(c, user)
+}
+
+//注销账号，详见 models.DeleteUser
+
+func DeleteMeHandler(c *gin.Context) {
+	var form deleteAccountForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		response.FailWithMsg(c, "参数错误")
+		return
+	}
+	user := toolkit.CurrentUser(c)
+	if form.Confirm != user.UserName {
+		response.FailWithMsg(c, "confirm does not match user name")
+		return
+	}
+	// 只通过钱包登录的用户没有密码
+	if user.Password != "" && !models.CheckPassword(user, form.Password) {
+		response.FailWithMsg(c, "incorrect password")
+		return
+	}
+	if err := models.DeleteUser(user); err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	claims := c.MustGet("Claims").(*toolkit.Claims)
+	_ = models.RevokeAccessToken(claims.ID, claims.ExpiresAt.Time)
+	response.OkWithMsg(c, "account deleted")
+}
+
+//查看用户的公开资料和发布的博客
+
+func GetUserProfileHandler(c *gin.Context) {
+	user, err := models.GetUserByName(c.Param("name"))
+	if err != nil {
+		response.FailWithMsg(c, "user not found")
+		return
+	}
+	blogs, err := models.GetBlogsByUser(user.UserId)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	followers, following := models.FollowCounts(user.UserId)
+	response.OkWithData(c, gin.H{
+		"profile":   user.PublicProfile(),
+		"blogs":     blogs,
+		"followers": followers,
+		"following": following,
+	})
+}
+
+// 只允许http和https地址
+func validHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
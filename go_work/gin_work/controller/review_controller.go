@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"gin_work/models"
+	"gin_work/moderation"
+	"gin_work/response"
+	"gin_work/toolkit"
+	"github.com/gin-gonic/gin"
+	"strconv"
+)
+
+// 对提交的内容执行审核流程，管理员提交的内容不审核
+// 内容被拒绝时已经写入响应，返回false
+func moderate(c *gin.Context, content *moderation.Content) (moderation.Result, bool) {
+	user := toolkit.CurrentUser(c)
+	if user.Role == models.RoleAdmin {
+		return moderation.Result{}, true
+	}
+	content.UserId = user.UserId
+	result := moderation.Default.Check(content)
+	if result.Verdict == moderation.Reject {
+		response.Fail(c, toolkit.CodeContentRejected, gin.H{"reasons": result.Reasons}, "内容未通过审核")
+		return result, false
+	}
+	return result, true
+}
+
+// 内容保存成功后通知审核流程，计入发布频率，管理员的内容没有经过审核
+func moderated(c *gin.Context, content *moderation.Content) {
+	if toolkit.CurrentUser(c).Role == models.RoleAdmin {
+		return
+	}
+	moderation.Default.Commit(content)
+}
+
+// 博客处于公开或将要公开的状态时才需要人工审核，草稿在发布时重新审核
+func holdsBlog(status string) bool {
+	return status == models.BlogPublished || status == models.BlogScheduled || status == models.BlogPending
+}
+
+// 审核队列，参数: status(pending/approved/rejected，默认pending)、kind(blog/comment)、limit、page
+func ReviewListHandler(c *gin.Context) {
+	limit, page := pageParams(c)
+	list, total, err := models.GetReviews(c.Query("status"), c.Query("kind"), (page-1)*limit, limit)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithPage(c, response.PageData{List: list, Total: total, Limit: limit, Page: page})
+}
+
+// 审核通过
+func ReviewApproveHandler(c *gin.Context) {
+	resolveReview(c, true)
+}
+
+// 审核拒绝
+func ReviewRejectHandler(c *gin.Context) {
+	resolveReview(c, false)
+}
+
+// 处理审核请求，note 为可选的备注
+type reviewForm struct {
+	Note string `json:"note" binding:"max=255"`
+}
+
+func resolveReview(c *gin.Context, approve bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.FailWithMsg(c, "参数错误")
+		return
+	}
+	var form reviewForm
+	if c.Request.ContentLength > 0 {
+		if err = c.ShouldBindJSON(&form); err != nil {
+			response.FailWithMsg(c, "参数错误")
+			return
+		}
+	}
+	review, err := models.ResolveReview(id, approve, toolkit.CurrentUser(c), form.Note)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithData(c, review)
+}
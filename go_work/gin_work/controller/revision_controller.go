@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"gin_work/models"
+	"gin_work/moderation"
+	"gin_work/response"
+	"gin_work/toolkit"
+	"github.com/gin-gonic/gin"
+	"strconv"
+)
+
+// 查看博客的历史版本列表
+func ListRevisionsHandler(c *gin.Context) {
+	blog, ok := ownedBlog(c)
+	if !ok {
+		return
+	}
+	revisions, err := models.GetRevisions(blog.BlogId)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithData(c, gin.H{
+		"version":   blog.Version,
+		"revisions": revisions,
+	})
+}
+
+// 查看某个历史版本的完整内容
+func GetRevisionHandler(c *gin.Context) {
+	blog, ok := ownedBlog(c)
+	if !ok {
+		return
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		response.FailWithMsg(c, "version not found")
+		return
+	}
+	revision, err := models.GetRevision(blog.BlogId, version)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithData(c, revision)
+}
+
+// 比较两个历史版本，参数 from、to 为版本号，to 为空时与当前版本比较
+func DiffRevisionsHandler(c *gin.Context) {
+	blog, ok := ownedBlog(c)
+	if !ok {
+		return
+	}
+	from, err := strconv.Atoi(c.Query("from"))
+	if err != nil {
+		response.FailWithMsg(c, "from is required")
+		return
+	}
+	to := blog.Version
+	if s := c.Query("to"); s != "" {
+		if to, err = strconv.Atoi(s); err != nil {
+			response.FailWithMsg(c, "invalid to")
+			return
+		}
+	}
+	a, err := models.GetRevision(blog.BlogId, from)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	b, err := models.GetRevision(blog.BlogId, to)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithData(c, gin.H{
+		"from":    from,
+		"to":      to,
+		"title":   toolkit.DiffLines(a.Title, b.Title),
+		"content": toolkit.DiffLines(a.Content, b.Content),
+	})
+}
+
+// 恢复请求，version 为要恢复的历史版本，currentVersion 为编辑者看到的当前版本
+type restoreForm struct {
+	Version        int `form:"version" json:"version" binding:"required"`
+	CurrentVersion int `form:"currentVersion" json:"currentVersion" binding:"required"`
+}
+
+// 把历史版本恢复为新版本，原有的版本记录全部保留
+func RestoreRevisionHandler(c *gin.Context) {
+	blog, ok := ownedBlog(c)
+	if !ok {
+		return
+	}
+	var form restoreForm
+	if err := c.ShouldBind(&form); err != nil {
+		response.FailWithMsg(c, "参数错误")
+		return
+	}
+	revision, err := models.GetRevision(blog.BlogId, form.Version)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	// 恢复的内容和修改一样需要审核，避免恢复曾经被拒绝的内容
+	result, ok := moderate(c, &moderation.Content{Kind: moderation.KindBlog, Title: revision.Title, Text: revision.Content, Update: true})
+	if !ok {
+		return
+	}
+	var held []string
+	if result.Verdict == moderation.Hold && holdsBlog(blog.Status) {
+		held = result.Reasons
+	}
+	restored, err := models.RestoreRevision(blog.BlogId, form.Version, form.CurrentVersion, toolkit.CurrentUser(c), held)
+	if err == models.ErrVersionConflict {
+		versionConflict(c, blog.BlogId)
+		return
+	}
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithData(c, restored)
+}
+
+// 版本冲突时返回当前版本号，客户端据此重新读取并合并
+func versionConflict(c *gin.Context, blogId int) {
+	data := gin.H{}
+	if blog, err := models.GetABlog(blogId); err == nil {
+		data["currentVersion"] = blog.Version
+	}
+	response.Fail(c, toolkit.CodeVersionConflict, data, "版本冲突")
+}
@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"gin_work/models"
+	"gin_work/response"
+	"gin_work/setting"
+	"gin_work/toolkit"
+	"github.com/gin-gonic/gin"
+	"strings"
+	"time"
+)
+
+// 随机数有效期
+const siweNonceTTL = 10 * time.Minute
+
+// 钱包登录请求
+type siweLoginForm struct {
+	Message   string `json:"message" binding:"required"`
+	Signature string `json:"signature" binding:"required"`
+}
+
+//获取钱包登录的随机数
+
+func SiweNonceHandler(c *gin.Context) {
+	nonce, err := toolkit.GenerateSiweNonce()
+	if err != nil {
+		response.FailWithMsg(c, "nonce generate failed")
+		return
+	}
+	err = models.CreateSiweNonce(nonce, time.Now().Add(siweNonceTTL))
+	if err != nil {
+		response.FailWithMsg(c, "nonce generate failed")
+		return
+	}
+	response.OkWithData(c, gin.H{
+		"nonce":   nonce,
+		"domain":  siweDomain(c),
+		"chainId": setting.Conf.ChainID,
+	})
+}
+
+//钱包登录，校验EIP-4361消息签名后签发令牌
+//请求头中带有有效令牌时，把钱包地址绑定到当前用户
+
+func SiweLoginHandler(c *gin.Context) {
+	var form siweLoginForm
+	err := c.ShouldBindJSON(&form)
+	if err != nil {
+		response.FailWithMsg(c, "参数错误")
+		return
+	}
+	msg, err := toolkit.ParseSiweMessage(form.Message)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	err = msg.Validate(siweDomain(c), setting.Conf.ChainID, time.Now())
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	err = toolkit.VerifySiweSignature(form.Message, form.Signature, msg.Address)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	err = models.ConsumeSiweNonce(msg.Nonce)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+
+	var user *models.User
+	// 已登录时绑定钱包地址，访问令牌本身已经过两步验证
+	if header := c.GetHeader("Authorization"); header != "" {
+		claims, err := toolkit.ParseToken(strings.TrimPrefix(header, "Bearer "))
+		if err != nil {
+			response.FailWithMsg(c, "Unauthorized")
+			return
+		}
+		user, err = toolkit.ClaimsUser(claims)
+		if err == nil {
+			err = models.LinkWalletAddress(user, msg.Address.Hex())
+		}
+	} else {
+		user, err = models.GetOrCreateWalletUser(msg.Address.Hex())
+		// 钱包登录和密码登录一样，开启两步验证后需要提交验证码
+		if err == nil && user.TOTPEnabled {
+			requireSecondFactor(c, user)
+			return
+		}
+	}
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	issueTokens(c, user)
+}
+
+// 消息中要求的域名，未配置时使用请求的Host
+func siweDomain(c *gin.Context) string {
+	if setting.Conf.Domain != "" {
+		return setting.Conf.Domain
+	}
+	return c.Request.Host
+}
@@ -0,0 +1,254 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"gin_work/feed"
+	"gin_work/markdown"
+	"gin_work/models"
+	"gin_work/setting"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 全站RSS订阅
+func RSSHandler(c *gin.Context) {
+	serveFeed(c, "rss", nil)
+}
+
+// 全站Atom订阅
+func AtomHandler(c *gin.Context) {
+	serveFeed(c, "atom", nil)
+}
+
+// 作者的RSS订阅
+func AuthorRSSHandler(c *gin.Context) {
+	serveAuthorFeed(c, "rss")
+}
+
+// 作者的Atom订阅
+func AuthorAtomHandler(c *gin.Context) {
+	serveAuthorFeed(c, "atom")
+}
+
+func serveAuthorFeed(c *gin.Context, format string) {
+	author, err := models.GetUserByName(c.Param("name"))
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	serveFeed(c, format, author)
+}
+
+// 生成订阅源，author 为nil时是全站订阅
+func serveFeed(c *gin.Context, format string, author *models.User) {
+	authorId := 0
+	if author != nil {
+		authorId = author.UserId
+	}
+	blogs, err := models.FeedBlogs(authorId)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	// 订阅内容由博客列表和修改时间决定
+	var lastMod time.Time
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s:%d", format, authorId)
+	for _, blog := range blogs {
+		fmt.Fprintf(hash, "|%d:%d", blog.BlogId, blog.UpdatedAt.UnixNano())
+		if blog.UpdatedAt.After(lastMod) {
+			lastMod = blog.UpdatedAt
+		}
+	}
+	if notModified(c, `"`+hex.EncodeToString(hash.Sum(nil))[:32]+`"`, lastMod) {
+		return
+	}
+
+	site := siteURL()
+	f := &feed.Feed{
+		Title:       setting.Conf.SiteTitle,
+		Link:        site + "/",
+		Description: setting.Conf.SiteDescription,
+		FeedURL:     site + c.Request.URL.Path,
+		Updated:     lastMod,
+		Items:       make([]feed.Item, 0, len(blogs)),
+	}
+	if author != nil {
+		name := author.DisplayName
+		if name == "" {
+			name = author.UserName
+		}
+		f.Title = name + " - " + f.Title
+		f.Description = author.Bio
+	}
+	for _, blog := range blogs {
+		rendered := markdown.Render(blog.Content)
+		published := blog.CreatedAt
+		if blog.PublishAt != nil {
+			published = *blog.PublishAt
+		}
+		link := blogURL(blog.BlogId)
+		f.Items = append(f.Items, feed.Item{
+			ID:        link,
+			Title:     blog.Title,
+			Link:      link,
+			Author:    blog.UserName,
+			Published: published,
+			Updated:   blog.UpdatedAt,
+			Summary:   rendered.Excerpt,
+			Content:   rendered.HTML,
+		})
+	}
+
+	var body []byte
+	contentType := "application/rss+xml; charset=utf-8"
+	if format == "atom" {
+		body, err = feed.Atom(f)
+		contentType = "application/atom+xml; charset=utf-8"
+	} else {
+		body, err = feed.RSS(f)
+	}
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.Data(http.StatusOK, contentType, body)
+}
+
+// 站点地图，博客数量超过单个文件的上限时返回站点地图索引
+func SitemapHandler(c *gin.Context) {
+	total, lastMod, err := models.SitemapStats()
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if notModified(c, fmt.Sprintf(`"sitemap-%d-%d"`, total, lastMod.UnixNano()), lastMod) {
+		return
+	}
+	site := siteURL()
+	var body []byte
+	if pages := sitemapPages(total); pages == 1 {
+		body, err = sitemapPage(site, 1)
+	} else {
+		sitemaps := make([]feed.URL, pages)
+		for i := range sitemaps {
+			sitemaps[i] = feed.URL{Loc: fmt.Sprintf("%s/sitemaps/%d.xml", site, i+1), LastMod: lastMod}
+		}
+		body, err = feed.SitemapIndex(sitemaps)
+	}
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", body)
+}
+
+// 站点地图索引中的一个文件，路径为 /sitemaps/<页码>.xml
+func SitemapPageHandler(c *gin.Context) {
+	page, err := strconv.Atoi(strings.TrimSuffix(c.Param("file"), ".xml"))
+	if err != nil || page < 1 || !strings.HasSuffix(c.Param("file"), ".xml") {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	total, lastMod, err := models.SitemapStats()
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if page > sitemapPages(total) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	if notModified(c, fmt.Sprintf(`"sitemap-%d-%d-%d"`, page, total, lastMod.UnixNano()), lastMod) {
+		return
+	}
+	body, err := sitemapPage(siteURL(), page)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", body)
+}
+
+// 站点地图的文件数，首页占用第一个文件的一个位置
+func sitemapPages(total int) int {
+	urls := total + 1
+	return (urls + feed.MaxSitemapURLs - 1) / feed.MaxSitemapURLs
+}
+
+// 第page页站点地图中博客的偏移和数量，首页占用第一页的一个位置
+func sitemapRange(page int) (offset, limit int) {
+	if page == 1 {
+		return 0, feed.MaxSitemapURLs - 1
+	}
+	return (page-1)*feed.MaxSitemapURLs - 1, feed.MaxSitemapURLs
+}
+
+// 生成第page页的站点地图，第一页包含首页
+func sitemapPage(site string, page int) ([]byte, error) {
+	var urls []feed.URL
+	if page == 1 {
+		urls = append(urls, feed.URL{Loc: site + "/"})
+	}
+	offset, limit := sitemapRange(page)
+	entries, err := models.SitemapBlogs(offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		urls = append(urls, feed.URL{Loc: blogURL(entry.BlogId), LastMod: entry.UpdatedAt})
+	}
+	return feed.Sitemap(urls)
+}
+
+// 处理条件请求，内容未变化时返回304
+func notModified(c *gin.Context, etag string, lastMod time.Time) bool {
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "public, max-age=300")
+	if !lastMod.IsZero() {
+		c.Header("Last-Modified", lastMod.UTC().Format(http.TimeFormat))
+	}
+	// 有 If-None-Match 时忽略 If-Modified-Since
+	if match := c.GetHeader("If-None-Match"); match != "" {
+		for _, tag := range strings.Split(match, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == etag || tag == "*" {
+				c.Status(http.StatusNotModified)
+				return true
+			}
+		}
+		return false
+	}
+	if since, err := http.ParseTime(c.GetHeader("If-Modified-Since")); err == nil && !lastMod.IsZero() {
+		// HTTP时间只精确到秒
+		if !lastMod.Truncate(time.Second).After(since) {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// 站点地址
+func siteURL() string {
+	site := setting.Conf.SiteURL
+	if site == "" {
+		site = setting.Conf.BaseURL
+	}
+	return strings.TrimRight(site, "/")
+}
+
+// 博客页面的地址
+func blogURL(blogId int) string {
+	path := setting.Conf.BlogPath
+	if path == "" {
+		path = "/blog/%d"
+	}
+	return siteURL() + fmt.Sprintf(path, blogId)
+}
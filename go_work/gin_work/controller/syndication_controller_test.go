@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"gin_work/feed"
+	"testing"
+)
+
+// 各页的博客区间首尾相接，覆盖全部博客，每个文件不超过上限
+func TestSitemapRange(t *testing.T) {
+	tests := []struct {
+		total int
+		pages int
+	}{
+		{0, 1},
+		{feed.MaxSitemapURLs - 2, 1},
+		{feed.MaxSitemapURLs - 1, 1}, // 加上首页正好装满一个文件
+		{feed.MaxSitemapURLs, 2},
+		{feed.MaxSitemapURLs + 1, 2},
+		{2*feed.MaxSitemapURLs - 1, 2},
+		{2 * feed.MaxSitemapURLs, 3},
+	}
+	for _, tt := range tests {
+		pages := sitemapPages(tt.total)
+		if pages != tt.pages {
+			t.Fatalf("sitemapPages(%d) = %d, want %d", tt.total, pages, tt.pages)
+		}
+		next := 0
+		for page := 1; page <= pages; page++ {
+			offset, limit := sitemapRange(page)
+			if offset != next {
+				t.Fatalf("total %d page %d offset = %d, want %d", tt.total, page, offset, next)
+			}
+			urls := limit
+			if page == 1 {
+				urls++
+			}
+			if urls != feed.MaxSitemapURLs {
+				t.Fatalf("total %d page %d has room for %d urls", tt.total, page, urls)
+			}
+			// 除第一页外不生成空文件
+			if page > 1 && offset >= tt.total {
+				t.Fatalf("total %d page %d is empty", tt.total, page)
+			}
+			next = offset + limit
+		}
+		if next < tt.total {
+			t.Fatalf("total %d: blogs after %d not in any sitemap", tt.total, next)
+		}
+	}
+}
+
+func TestSitemapRangeOffsets(t *testing.T) {
+	tests := []struct {
+		page, offset, limit int
+	}{
+		{1, 0, 49999},
+		{2, 49999, 50000},
+		{3, 99999, 50000},
+	}
+	for _, tt := range tests {
+		if offset, limit := sitemapRange(tt.page); offset != tt.offset || limit != tt.limit {
+			t.Fatalf("sitemapRange(%d) = (%d, %d), want (%d, %d)", tt.page, offset, limit, tt.offset, tt.limit)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"gin_work/models"
+	"gin_work/response"
+	"github.com/gin-gonic/gin"
+	"strconv"
+)
+
+// 标签云默认和最多返回的标签数
+const (
+	defaultTagCloudSize = 50
+	maxTagCloudSize     = 200
+)
+
+// 标签云，参数: limit
+func TagCloudHandler(c *gin.Context) {
+	limit := defaultTagCloudSize
+	if v := c.Query("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= maxTagCloudSize {
+			limit = n
+		}
+	}
+	tags, err := models.TagCloud(limit)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithData(c, tags)
+}
+
+// 重命名标签请求
+type renameTagForm struct {
+	TagId int    `json:"tagId" binding:"required"`
+	Name  string `json:"name" binding:"required"`
+}
+
+// 重命名标签
+func RenameTagHandler(c *gin.Context) {
+	var form renameTagForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		response.FailWithMsg(c, "参数错误")
+		return
+	}
+	tag, err := models.RenameTag(form.TagId, form.Name)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithData(c, tag)
+}
+
+// 合并标签请求，from 标签下的博客归入 into 标签
+type mergeTagsForm struct {
+	From int `json:"from" binding:"required"`
+	Into int `json:"into" binding:"required"`
+}
+
+// 合并标签
+func MergeTagsHandler(c *gin.Context) {
+	var form mergeTagsForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		response.FailWithMsg(c, "参数错误")
+		return
+	}
+	if err := models.MergeTags(form.From, form.Into); err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithMsg(c, "合并成功")
+}
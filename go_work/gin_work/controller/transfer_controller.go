@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"fmt"
+	"gin_work/models"
+	"gin_work/response"
+	"gin_work/transfer"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 导入文件的最大大小
+const maxImportSize = 512 << 20
+
+// 导出全部用户、博客和评论
+// format=jsonl 时为 JSON Lines，format=markdown 时为Markdown文件的zip包
+func ExportHandler(c *gin.Context) {
+	format := c.DefaultQuery("format", "jsonl")
+	var w transfer.Writer
+	name := "gin_work-" + time.Now().Format("20060102150405")
+	switch format {
+	case "jsonl":
+		name += ".jsonl"
+		c.Header("Content-Type", "application/x-ndjson; charset=utf-8")
+		w = transfer.NewJSONLWriter(c.Writer)
+	case "markdown":
+		name += ".zip"
+		c.Header("Content-Type", "application/zip")
+		w = transfer.NewMarkdownWriter(c.Writer)
+	default:
+		response.FailWithMsg(c, "invalid format")
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	c.Status(http.StatusOK)
+	// 边读边写，开始输出后出错只能中断响应
+	err := models.Export(siteURL(), w.Write)
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		log.Printf("export failed, err:%v", err)
+		c.Abort()
+	}
+}
+
+// 导入导出的数据，表单字段为 file，按扩展名或 format 参数判断格式
+// 新用户默认导入为未验证邮箱的作者，trust_accounts=1 时保留文件中的角色和邮箱验证状态
+// 单条记录出错不会中断导入，返回的报告中列出每条失败记录的原因
+func ImportHandler(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize+1<<20)
+	header, err := c.FormFile("file")
+	if err != nil {
+		response.FailWithMsg(c, "file required")
+		return
+	}
+	format := c.PostForm("format")
+	if format == "" {
+		format = "jsonl"
+		if strings.EqualFold(filepath.Ext(header.Filename), ".zip") {
+			format = "markdown"
+		}
+	}
+	file, err := header.Open()
+	if err != nil {
+		response.FailWithMsg(c, "read file fail")
+		return
+	}
+	defer file.Close()
+
+	importer := models.NewImporter(c.PostForm("trust_accounts") == "1")
+	read := func(ref string, record interface{}, err error) {
+		if err != nil {
+			importer.Report.Fail(ref, "", 0, err)
+			return
+		}
+		importer.Import(ref, record)
+	}
+	switch format {
+	case "jsonl":
+		err = transfer.ReadJSONL(file, read)
+	case "markdown":
+		err = transfer.ReadMarkdownZip(file, header.Size, read)
+	default:
+		response.FailWithMsg(c, "invalid format")
+		return
+	}
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithData(c, importer.Report)
+}
@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"gin_work/models"
+	"gin_work/response"
+	"gin_work/toolkit"
+	"github.com/gin-gonic/gin"
+	"strconv"
+)
+
+// 当前用户的回收站，参数: limit、page
+func TrashListHandler(c *gin.Context) {
+	limit, page := pageParams(c)
+	list, total, err := models.GetTrash(toolkit.CurrentUser(c).UserId, (page-1)*limit, limit)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithPage(c, response.PageData{List: list, Total: total, Limit: limit, Page: page})
+}
+
+// 从回收站恢复博客，只有作者本人或管理员可以恢复
+func RestoreBlogHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.FailWithMsg(c, "id not found")
+		return
+	}
+	blog, err := models.GetTrashedBlog(id)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	if !toolkit.CanModify(c, blog.UserId) {
+		response.FailWithCode(c, toolkit.CodeForbidden)
+		return
+	}
+	if err = models.RestoreBlog(id); err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithMsg(c, "恢复成功")
+}
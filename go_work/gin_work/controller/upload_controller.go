@@ -0,0 +1,195 @@
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"gin_work/models"
+	"gin_work/response"
+	"gin_work/setting"
+	"gin_work/storage"
+	"gin_work/toolkit"
+	"github.com/gin-gonic/gin"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 未配置时的最大上传大小
+const defaultMaxUploadSize = 10 << 20
+
+// 缩略图的最大边长
+const thumbnailSize = 320
+
+// 允许上传的文件类型及保存使用的扩展名，类型根据文件内容判断，不信任请求中的Content-Type
+var uploadTypes = map[string]string{
+	"image/jpeg":                ".jpg",
+	"image/png":                 ".png",
+	"image/gif":                 ".gif",
+	"image/webp":                ".webp",
+	"application/pdf":           ".pdf",
+	"application/zip":           ".zip",
+	"text/plain; charset=utf-8": ".txt",
+}
+
+// 上传文件，表单字段为 file
+// 图片会同时生成缩略图；上传后一段时间内没有被博客引用的文件会被清理
+func UploadHandler(c *gin.Context) {
+	maxSize := setting.Conf.StorageConfig.MaxUploadSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxUploadSize
+	}
+	// 限制整个请求体的大小，多出的1MB留给multipart的其他内容
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+1<<20)
+	header, err := c.FormFile("file")
+	if err != nil {
+		response.FailWithMsg(c, "file required")
+		return
+	}
+	if header.Size > maxSize {
+		response.FailWithMsg(c, fmt.Sprintf("file too large, max %d bytes", maxSize))
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		response.FailWithMsg(c, "read file fail")
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil || int64(len(data)) > maxSize {
+		response.FailWithMsg(c, "read file fail")
+		return
+	}
+	contentType := http.DetectContentType(data)
+	ext, ok := uploadTypes[contentType]
+	if !ok {
+		response.FailWithMsg(c, "unsupported file type")
+		return
+	}
+
+	now := time.Now()
+	key, err := models.NewAttachmentKey(ext, now)
+	if err != nil {
+		response.FailWithMsg(c, "upload fail")
+		return
+	}
+	attachment := &models.Attachment{
+		UserId:      toolkit.CurrentUser(c).UserId,
+		StorageKey:  key,
+		FileName:    filepath.Base(header.Filename),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+	}
+	var thumb []byte
+	var thumbType string
+	if strings.HasPrefix(contentType, "image/") {
+		if attachment.Width, attachment.Height, err = toolkit.ImageSize(data); err != nil {
+			response.FailWithMsg(c, "invalid image")
+			return
+		}
+		if thumb, thumbType, err = toolkit.Thumbnail(data, thumbnailSize); err != nil {
+			response.FailWithMsg(c, "invalid image")
+			return
+		}
+		thumbExt := ".jpg"
+		if thumbType == "image/png" {
+			thumbExt = ".png"
+		}
+		attachment.ThumbKey = "thumbs/" + strings.TrimSuffix(key, ext) + thumbExt
+		attachment.ThumbSize = int64(len(thumb))
+	}
+
+	ctx := c.Request.Context()
+	if err = storage.Default.Put(ctx, key, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		response.FailWithMsg(c, "upload fail")
+		return
+	}
+	if thumb != nil {
+		err = storage.Default.Put(ctx, attachment.ThumbKey, bytes.NewReader(thumb), int64(len(thumb)), thumbType)
+		if err != nil {
+			storage.Default.Delete(ctx, key)
+			response.FailWithMsg(c, "upload fail")
+			return
+		}
+	}
+	if err = models.CreateAttachment(attachment); err != nil {
+		storage.Default.Delete(ctx, key)
+		if thumb != nil {
+			storage.Default.Delete(ctx, attachment.ThumbKey)
+		}
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithData(c, attachment)
+}
+
+// 我上传的附件，参数: limit、page
+func UploadListHandler(c *gin.Context) {
+	limit, page := pageParams(c)
+	list, total, err := models.GetAttachments(toolkit.CurrentUser(c).UserId, (page-1)*limit, limit)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithPage(c, response.PageData{List: list, Total: total, Limit: limit, Page: page})
+}
+
+// 删除附件，只有上传者本人或管理员可以删除
+func UploadDeleteHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.FailWithMsg(c, "id not found")
+		return
+	}
+	attachment, err := models.GetAttachment(id)
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	if !toolkit.CanModify(c, attachment.UserId) {
+		response.FailWithCode(c, toolkit.CodeForbidden)
+		return
+	}
+	if err = models.DelAttachment(attachment); err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	response.OkWithMsg(c, "删除成功")
+}
+
+// 读取附件或缩略图，存储路径是随机生成的，可以长期缓存
+func AttachmentHandler(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	attachment, thumb, err := models.GetAttachmentByKey(key)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	reader, err := storage.Default.Get(c.Request.Context(), key)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	defer reader.Close()
+
+	size, contentType := attachment.Size, attachment.ContentType
+	headers := map[string]string{
+		"Cache-Control":          "public, max-age=31536000, immutable",
+		"X-Content-Type-Options": "nosniff",
+	}
+	if thumb {
+		size = attachment.ThumbSize
+		contentType = "image/jpeg"
+		if strings.HasSuffix(key, ".png") {
+			contentType = "image/png"
+		}
+	} else if !strings.HasPrefix(contentType, "image/") {
+		// 非图片文件一律下载，不在浏览器中打开
+		headers["Content-Disposition"] = mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName})
+	}
+	c.DataFromReader(http.StatusOK, size, contentType, reader, headers)
+}
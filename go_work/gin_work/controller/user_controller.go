@@ -3,8 +3,11 @@ package controller
 import (
 	"gin_work/models"
 	"gin_work/response"
+	"gin_work/security"
 	"gin_work/toolkit"
 	"github.com/gin-gonic/gin"
+	"log"
+	"time"
 )
 
 //用户注册
@@ -16,14 +19,28 @@ func UserRegisterHandler(c *gin.Context) {
 	if err != nil {
 		return
 	}
+	//校验邮箱格式
+	if email := user.EmailAddress(); email != "" && !validEmail(email) {
+		response.FailWithMsg(c, "invalid email")
+		return
+	}
 	//创建用户
 	err = models.CreateUser(&user)
+	if err == models.ErrEmailTaken || err == models.ErrReservedName {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
 	if err != nil {
 		response.FailWithMsg(c, "user already exists")
-	} else {
-		response.OkWithMsg(c, "register successfully")
+		return
+	}
+	//发送验证邮件，发送失败不影响注册，可以稍后重新发送
+	if user.Email != nil {
+		if err = sendVerifyEmail(&user); err != nil {
+			log.Printf("send verify email to %s failed, err:%v", *user.Email, err)
+		}
 	}
-	return
+	response.OkWithMsg(c, "register successfully")
 }
 
 //用户登录
@@ -34,18 +51,126 @@ func UserLoginHandler(c *gin.Context) {
 	if err != nil {
 		return
 	}
+	//账号或IP被锁定时直接拒绝
+	userName, ip := user.UserName, c.ClientIP()
+	if _, err = security.Default.Check(userName, ip); err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
 	//校验用户信息
 	err = models.GetUserBy(&user)
 	if err != nil {
-		response.FailWithMsg(c, "user not found")
+		if err := security.Default.Fail(userName, ip); err != nil {
+			log.Printf("record login failure failed, err:%v", err)
+		}
+		_ = models.RecordLoginFailure(userName, ip, err.Error())
+		// 不区分用户不存在和密码错误
+		response.FailWithMsg(c, "invalid username or password")
 		return
 	}
+	//开启两步验证的用户先返回中间令牌，提交验证码后再签发正式令牌
+	//失败计数在两步验证通过后才清零，避免用正确密码重置验证码的尝试次数
+	if user.TOTPEnabled {
+		requireSecondFactor(c, &user)
+		return
+	}
+	_ = security.Default.Success(userName)
 
 	//生成JWT
-	token, err := toolkit.GenerateToken(user.UserName)
+	issueTokens(c, &user)
+}
+
+// 返回两步验证的中间令牌，密码登录和钱包登录共用
+func requireSecondFactor(c *gin.Context, user *models.User) {
+	mfaToken, err := toolkit.GenerateMFAToken(user)
+	if err != nil {
+		response.FailWithMsg(c, "token generate failed")
+		return
+	}
+	response.OkWithData(c, gin.H{
+		"mfaRequired": true,
+		"mfaToken":    mfaToken,
+	})
+}
+
+// 刷新令牌请求
+type refreshForm struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+//刷新令牌，旧的刷新令牌会被作废
+
+func UserRefreshHandler(c *gin.Context) {
+	var form refreshForm
+	err := c.ShouldBindJSON(&form)
+	if err != nil {
+		response.FailWithMsg(c, "refresh token required")
+		return
+	}
+	refreshToken, err := toolkit.GenerateRefreshToken()
+	if err != nil {
+		response.FailWithMsg(c, "token generate failed")
+		return
+	}
+	userName, err := models.RotateRefreshToken(form.RefreshToken, refreshToken, time.Now().Add(toolkit.RefreshTokenTTL))
+	if err != nil {
+		response.FailWithMsg(c, err.Error())
+		return
+	}
+	user, err := models.GetUserByName(userName)
+	if err != nil {
+		response.FailWithMsg(c, "Unauthorized")
+		return
+	}
+	accessToken, err := toolkit.GenerateToken(user)
 	if err != nil {
 		response.FailWithMsg(c, "token generate failed")
 		return
 	}
-	response.OkWithData(c, token)
+	response.OkWithData(c, tokenPair(accessToken, refreshToken))
+}
+
+//用户注销，吊销当前的访问令牌和提交的刷新令牌
+
+func UserLogoutHandler(c *gin.Context) {
+	claims := c.MustGet("Claims").(*toolkit.Claims)
+	err := models.RevokeAccessToken(claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		response.FailWithMsg(c, "logout failed")
+		return
+	}
+	var form refreshForm
+	if c.ShouldBindJSON(&form) == nil {
+		_ = models.RevokeRefreshToken(form.RefreshToken)
+	}
+	response.OkWithMsg(c, "logout successfully")
+}
+
+// 签发访问令牌和刷新令牌
+func issueTokens(c *gin.Context, user *models.User) {
+	accessToken, err := toolkit.GenerateToken(user)
+	if err != nil {
+		response.FailWithMsg(c, "token generate failed")
+		return
+	}
+	refreshToken, err := toolkit.GenerateRefreshToken()
+	if err != nil {
+		response.FailWithMsg(c, "token generate failed")
+		return
+	}
+	err = models.CreateRefreshToken(user.UserName, refreshToken, time.Now().Add(toolkit.RefreshTokenTTL))
+	if err != nil {
+		response.FailWithMsg(c, "token generate failed")
+		return
+	}
+	response.OkWithData(c, tokenPair(accessToken, refreshToken))
+}
+
+// 令牌对的返回格式
+func tokenPair(accessToken, refreshToken string) gin.H {
+	return gin.H{
+		"accessToken":  accessToken,
+		"refreshToken": refreshToken,
+		"expiresIn":    int(toolkit.AccessTokenTTL.Seconds()),
+	}
 }
@@ -0,0 +1,130 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 检查XML格式正确，并返回所有文本内容
+func parseXML(t *testing.T, body []byte) string {
+	t.Helper()
+	if !bytes.HasPrefix(body, []byte(xml.Header)) {
+		t.Fatalf("missing xml header: %.60q", body)
+	}
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	var text strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return text.String()
+		}
+		if err != nil {
+			t.Fatalf("malformed xml: %v\n%s", err, body)
+		}
+		if data, ok := tok.(xml.CharData); ok {
+			text.Write(data)
+		}
+	}
+}
+
+func testFeed() *Feed {
+	published := time.Date(2024, 5, 1, 8, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	return &Feed{
+		Title:       "Tom & Jerry's <blog>",
+		Link:        "https://example.com/",
+		Description: "说明 & \"引号\"",
+		FeedURL:     "https://example.com/rss.xml?a=1&b=2",
+		Updated:     published,
+		Items: []Item{{
+			ID:        "https://example.com/blog/1",
+			Title:     "a < b && c > d",
+			Link:      "https://example.com/blog/1",
+			Author:    "alice",
+			Published: published,
+			Updated:   published,
+			Summary:   "<p>summary</p>",
+			Content:   "<pre><code>if a[b[0]]>1 {}</code></pre> x]]>y ]]]]><![CDATA[ end",
+		}},
+	}
+}
+
+func TestFeedWellFormed(t *testing.T) {
+	f := testFeed()
+	for name, render := range map[string]func(*Feed) ([]byte, error){"rss": RSS, "atom": Atom} {
+		t.Run(name, func(t *testing.T) {
+			body, err := render(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			text := parseXML(t, body)
+			// 解析后的文本与原文相同，]]> 没有提前结束CDATA
+			for _, want := range []string{f.Title, f.Items[0].Title, f.Items[0].Content} {
+				if !strings.Contains(text, want) {
+					t.Fatalf("%q lost after round trip", want)
+				}
+			}
+		})
+	}
+}
+
+// 内容中XML不允许的控制字符被替换，不影响解析
+func TestFeedControlCharacters(t *testing.T) {
+	f := testFeed()
+	f.Items[0].Title = "tab\tvt\x0bnul\x00"
+	f.Items[0].Content = "<p>vt\x0b nul\x00 esc\x1b</p>"
+	for name, render := range map[string]func(*Feed) ([]byte, error){"rss": RSS, "atom": Atom} {
+		t.Run(name, func(t *testing.T) {
+			body, err := render(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			text := parseXML(t, body)
+			if !strings.Contains(text, "<p>vt\uFFFD nul\uFFFD esc\uFFFD</p>") {
+				t.Fatalf("control characters not replaced:\n%s", body)
+			}
+		})
+	}
+}
+
+func TestRSSDates(t *testing.T) {
+	body, err := RSS(testFeed())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Channel struct {
+			LastBuildDate string `xml:"lastBuildDate"`
+			Items         []struct {
+				PubDate string `xml:"pubDate"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	if err = xml.Unmarshal(body, &doc); err != nil {
+		t.Fatal(err)
+	}
+	const want = "Wed, 01 May 2024 00:00:00 +0000"
+	if doc.Channel.LastBuildDate != want || len(doc.Channel.Items) != 1 || doc.Channel.Items[0].PubDate != want {
+		t.Fatalf("dates = %+v, want %s", doc.Channel, want)
+	}
+}
+
+func TestSitemapWellFormed(t *testing.T) {
+	lastMod := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	urls := []URL{{Loc: "https://example.com/"}, {Loc: "https://example.com/blog/1?a=1&b=2", LastMod: lastMod}}
+	for name, render := range map[string]func([]URL) ([]byte, error){"sitemap": Sitemap, "index": SitemapIndex} {
+		t.Run(name, func(t *testing.T) {
+			body, err := render(urls)
+			if err != nil {
+				t.Fatal(err)
+			}
+			text := parseXML(t, body)
+			if !strings.Contains(text, urls[1].Loc) || !strings.Contains(text, "2024-05-01T00:00:00Z") {
+				t.Fatalf("unexpected sitemap:\n%s", body)
+			}
+		})
+	}
+}
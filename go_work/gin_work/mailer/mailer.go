@@ -0,0 +1,124 @@
+package mailer
+
+import (
+	"fmt"
+	"gin_work/setting"
+	"log"
+	"mime"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message 一封邮件
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer 邮件发送接口
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Default 全局使用的邮件发送器，默认只打印日志
+var Default Mailer = LogMailer{}
+
+// Init 根据配置选择邮件发送器
+// log 和 file 方式会把验证和重置密码的令牌写入日志或磁盘，release 模式下只能使用 smtp
+func Init(cfg *setting.MailConfig, release bool) error {
+	if cfg == nil {
+		return nil
+	}
+	if release && cfg.Driver != "smtp" {
+		return fmt.Errorf("mail driver %q is only for development, use smtp in release mode", cfg.Driver)
+	}
+	switch cfg.Driver {
+	case "", "log":
+		Default = LogMailer{}
+	case "file":
+		Default = &FileMailer{Dir: cfg.Dir}
+	case "smtp":
+		Default = &SMTPMailer{
+			Addr:     fmt.Sprintf("%s:%d", cfg.SMTPHost, cfg.SMTPPort),
+			Host:     cfg.SMTPHost,
+			Username: cfg.SMTPUser,
+			Password: cfg.SMTPPassword,
+			From:     cfg.From,
+		}
+	default:
+		return fmt.Errorf("unsupported mail driver %q", cfg.Driver)
+	}
+	return nil
+}
+
+// Send 使用全局邮件发送器发送
+func Send(msg Message) error {
+	return Default.Send(msg)
+}
+
+// LogMailer 把邮件打印到日志，用于开发环境
+type LogMailer struct{}
+
+func (LogMailer) Send(msg Message) error {
+	log.Printf("[mail] to=%s subject=%s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileMailer 每封邮件写成目录中的一个.eml文件，测试时可以直接读取检查
+type FileMailer struct {
+	Dir string
+	mu  sync.Mutex
+	seq int
+}
+
+func (m *FileMailer) Send(msg Message) error {
+	m.mu.Lock()
+	m.seq++
+	name := fmt.Sprintf("%d-%04d.eml", time.Now().UnixNano(), m.seq)
+	m.mu.Unlock()
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(m.Dir, name), []byte(format("", msg)), 0o644)
+}
+
+// SMTPMailer 通过SMTP服务器发送邮件
+type SMTPMailer struct {
+	Addr     string
+	Host     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	return smtp.SendMail(m.Addr, auth, m.From, []string{msg.To}, []byte(format(m.From, msg)))
+}
+
+// 生成邮件原文
+func format(from string, msg Message) string {
+	var b strings.Builder
+	if from != "" {
+		b.WriteString("From: " + from + "\r\n")
+	}
+	b.WriteString("To: " + headerValue(msg.To) + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("UTF-8", headerValue(msg.Subject)) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(msg.Body)
+	return b.String()
+}
+
+// 去掉换行，防止邮件头注入
+func headerValue(v string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(v)
+}
@@ -0,0 +1,151 @@
+package mailer
+
+import (
+	"gin_work/setting"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 读取目录中的全部邮件
+func readMails(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mails []string
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mails = append(mails, string(data))
+	}
+	return mails
+}
+
+// 邮件头
+func mailHeader(mail, key string) string {
+	head, _, _ := strings.Cut(mail, "\r\n\r\n")
+	for _, line := range strings.Split(head, "\r\n") {
+		if k, v, ok := strings.Cut(line, ": "); ok && k == key {
+			return v
+		}
+	}
+	return ""
+}
+
+func TestFileMailer(t *testing.T) {
+	tests := []struct {
+		name        string
+		msg         Message
+		wantTo      string
+		wantSubject string
+		wantBody    string
+	}{
+		{
+			name:        "reset password",
+			msg:         Message{To: "alice@example.com", Subject: "重置密码", Body: "token: abc\n"},
+			wantTo:      "alice@example.com",
+			wantSubject: "重置密码",
+			wantBody:    "token: abc\n",
+		},
+		{
+			name:        "header injection",
+			msg:         Message{To: "bob@example.com\r\nBcc: eve@example.com", Subject: "hi\nBcc: eve@example.com", Body: "body"},
+			wantTo:      "bob@example.comBcc: eve@example.com",
+			wantSubject: "hiBcc: eve@example.com",
+			wantBody:    "body",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &FileMailer{Dir: filepath.Join(t.TempDir(), "mail")}
+			if err := m.Send(tt.msg); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			mails := readMails(t, m.Dir)
+			if len(mails) != 1 {
+				t.Fatalf("got %d mails, want 1", len(mails))
+			}
+			mail := mails[0]
+			if got := mailHeader(mail, "To"); got != tt.wantTo {
+				t.Errorf("To = %q, want %q", got, tt.wantTo)
+			}
+			subject, err := new(mime.WordDecoder).DecodeHeader(mailHeader(mail, "Subject"))
+			if err != nil || subject != tt.wantSubject {
+				t.Errorf("Subject = %q (%v), want %q", subject, err, tt.wantSubject)
+			}
+			if mailHeader(mail, "Bcc") != "" {
+				t.Errorf("injected header in %q", mail)
+			}
+			if _, body, _ := strings.Cut(mail, "\r\n\r\n"); body != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestFileMailerKeepsEveryMail(t *testing.T) {
+	m := &FileMailer{Dir: t.TempDir()}
+	for i := 0; i < 5; i++ {
+		if err := m.Send(Message{To: "alice@example.com", Subject: "verify", Body: "link"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := len(readMails(t, m.Dir)); got != 5 {
+		t.Fatalf("got %d mails, want 5", got)
+	}
+}
+
+func TestInit(t *testing.T) {
+	defer func(m Mailer) { Default = m }(Default)
+	tests := []struct {
+		name    string
+		driver  string
+		release bool
+		want    Mailer
+		wantErr bool
+	}{
+		{"default is log", "", false, LogMailer{}, false},
+		{"log", "log", false, LogMailer{}, false},
+		{"file", "file", false, &FileMailer{Dir: "./mail"}, false},
+		{"smtp", "smtp", false, &SMTPMailer{}, false},
+		{"unknown", "sendmail", false, nil, true},
+		{"log in release", "log", true, nil, true},
+		{"file in release", "file", true, nil, true},
+		{"smtp in release", "smtp", true, &SMTPMailer{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Default = nil
+			err := Init(&setting.MailConfig{Driver: tt.driver, Dir: "./mail", SMTPHost: "smtp.example.com", SMTPPort: 587}, tt.release)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Init() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if Default != nil {
+					t.Fatalf("mailer %T set on error", Default)
+				}
+				return
+			}
+			switch want := tt.want.(type) {
+			case LogMailer:
+				if _, ok := Default.(LogMailer); !ok {
+					t.Fatalf("got %T, want LogMailer", Default)
+				}
+			case *FileMailer:
+				if m, ok := Default.(*FileMailer); !ok || m.Dir != want.Dir {
+					t.Fatalf("got %#v, want FileMailer in %s", Default, want.Dir)
+				}
+			case *SMTPMailer:
+				if m, ok := Default.(*SMTPMailer); !ok || m.Addr != "smtp.example.com:587" {
+					t.Fatalf("got %#v, want SMTPMailer", Default)
+				}
+			}
+		})
+	}
+}
@@ -3,11 +3,18 @@ package main
 import (
 	"fmt"
 	"gin_work/dao"
+	"gin_work/mailer"
 	"gin_work/models"
+	"gin_work/moderation"
 	"gin_work/routers"
+	"gin_work/search"
+	"gin_work/security"
 	"gin_work/setting"
+	"gin_work/storage"
+	"gin_work/toolkit"
 	"net/http"
 	"os"
+	"time"
 )
 
 func HelloHandler(w http.ResponseWriter, r *http.Request) {
@@ -33,6 +40,16 @@ func main() {
 		fmt.Printf("load config from file failed, err:%v\n", err)
 		return
 	}
+	// 加载JWT密钥
+	if err := toolkit.InitKeys(setting.Conf.JWTConfig); err != nil {
+		fmt.Printf("load jwt keys failed, err:%v\n", err)
+		return
+	}
+	// 初始化邮件发送
+	if err := mailer.Init(setting.Conf.MailConfig, setting.Conf.Release); err != nil {
+		fmt.Printf("init mailer failed, err:%v\n", err)
+		return
+	}
 	// 连接数据库
 	err := dao.InitMySQL(setting.Conf.MySQLConfig)
 	if err != nil {
@@ -40,8 +57,76 @@ func main() {
 		return
 	}
 	defer dao.Close() // 程序退出关闭数据库连接
+	// 登录失败限制
+	var store security.Store = security.NewMemoryStore()
+	if setting.Conf.LoginConfig.Store == "db" {
+		store = models.LoginAttemptStore{}
+	}
+	security.Default = security.NewGuard(store, setting.Conf.LoginConfig)
 	// 根据模型创建数据库表项
-	dao.DB.AutoMigrate(&models.User{}, &models.Blog{}, &models.Comment{})
+	if err := models.Migrate(); err != nil {
+		fmt.Printf("migrate failed,err:%v\n", err)
+		return
+	}
+	// 创建第一个管理员，用户还没有注册时注册后重启即可
+	if name := setting.Conf.BootstrapAdmin; name != "" {
+		promoted, err := models.BootstrapAdmin(name)
+		if err != nil {
+			fmt.Printf("bootstrap admin %s failed,err:%v\n", name, err)
+		} else if promoted {
+			fmt.Printf("user %s is now admin\n", name)
+		}
+	}
+
+	// 加载关注时间线配置
+	if err := models.StartFeed(setting.Conf.FeedConfig); err != nil {
+		fmt.Printf("init feed failed,err:%v\n", err)
+		return
+	}
+
+	// 初始化搜索引擎，进程内索引需要从数据库重建
+	if err := search.Init(setting.Conf.SearchConfig); err != nil {
+		fmt.Printf("init search failed,err:%v\n", err)
+		return
+	}
+	if search.NeedsRebuild() {
+		if err := models.RebuildSearchIndex(); err != nil {
+			fmt.Printf("rebuild search index failed,err:%v\n", err)
+			return
+		}
+	}
+
+	// 启动定时发布的后台协程
+	models.StartPublisher(30 * time.Second)
+	// 启动定期写入浏览数的后台协程
+	models.StartViewFlusher(10 * time.Second)
+
+	// 初始化上传文件的存储，并定期清理没有被博客引用的附件
+	if err := storage.Init(setting.Conf.StorageConfig); err != nil {
+		fmt.Printf("init storage failed,err:%v\n", err)
+		return
+	}
+	grace := setting.Conf.StorageConfig.OrphanGrace
+	if grace <= 0 {
+		grace = 24 * time.Hour
+	}
+	models.StartAttachmentGC(time.Hour, grace)
+
+	// 定期彻底删除回收站中超过保留时长的博客
+	retention, interval := setting.Conf.TrashConfig.TrashRetention, setting.Conf.TrashConfig.PurgeInterval
+	if retention <= 0 {
+		retention = 30 * 24 * time.Hour
+	}
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	models.StartTrashPurger(interval, retention)
+
+	// 加载内容审核规则
+	if err := moderation.Init(setting.Conf.ModerationConfig); err != nil {
+		fmt.Printf("init moderation failed,err:%v\n", err)
+		return
+	}
 
 	// 启动gin服务
 	r := routers.SetupRouter()
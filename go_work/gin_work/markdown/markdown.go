@@ -0,0 +1,253 @@
+package markdown
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// Heading 目录中的一个标题
+type Heading struct {
+	Level int    `json:"level"`
+	ID    string `json:"id"`
+	Text  string `json:"text"`
+}
+
+// Rendered 渲染结果
+type Rendered struct {
+	// HTML 经过白名单过滤的HTML
+	HTML string `json:"html"`
+	// TOC 标题目录，ID 与HTML中标题的id一致
+	TOC []Heading `json:"toc"`
+	// ReadingMinutes 预计阅读分钟数
+	ReadingMinutes int `json:"readingMinutes"`
+	// Excerpt 纯文本摘要
+	Excerpt string `json:"excerpt"`
+}
+
+// 阅读速度：中日韩文字每分钟300字，其他语言每分钟200词
+const (
+	cjkPerMinute  = 300
+	wordPerMinute = 200
+	// 摘要的最大字数
+	excerptLength = 160
+	// 缓存的渲染结果数量
+	cacheSize = 512
+)
+
+// 不开启 html.WithUnsafe，Markdown中的原始HTML不会输出
+var md = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+)
+
+// 渲染后再按白名单过滤一次，链接只允许安全的协议并加上 rel="nofollow"
+var policy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("id").Matching(regexp.MustCompile(`^[\p{L}\p{N}_-]+$`)).OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#-]+$`)).OnElements("code")
+	p.AllowAttrs("type", "checked", "disabled").OnElements("input")
+	return p
+}()
+
+// Render 把Markdown渲染为安全的HTML，结果按内容哈希缓存
+// 返回的结果在多个请求之间共享，调用者不能修改
+func Render(source string) *Rendered {
+	sum := sha256.Sum256([]byte(source))
+	key := hex.EncodeToString(sum[:])
+	if r, ok := cache.get(key); ok {
+		return r
+	}
+	r := render([]byte(source))
+	cache.put(key, r)
+	return r
+}
+
+func render(source []byte) *Rendered {
+	ctx := parser.NewContext(parser.WithIDs(newHeadingIDs()))
+	doc := md.Parser().Parse(text.NewReader(source), parser.WithContext(ctx))
+
+	r := &Rendered{TOC: []Heading{}}
+	var plain strings.Builder
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := n.(type) {
+		case *ast.Heading:
+			id, _ := node.AttributeString("id")
+			idBytes, _ := id.([]byte)
+			r.TOC = append(r.TOC, Heading{Level: node.Level, ID: string(idBytes), Text: nodeText(node, source)})
+		case *ast.Paragraph:
+			plain.WriteString(nodeText(node, source))
+			plain.WriteByte(' ')
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+
+	var buf bytes.Buffer
+	if err := md.Renderer().Render(&buf, source, doc); err != nil {
+		buf.Reset()
+	}
+	r.HTML = policy.Sanitize(buf.String())
+	r.Excerpt = excerpt(plain.String())
+	r.ReadingMinutes = readingMinutes(nodeText(doc, source))
+	return r
+}
+
+// 节点内的纯文本
+func nodeText(n ast.Node, source []byte) string {
+	var b strings.Builder
+	_ = ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := c.(type) {
+		case *ast.Text:
+			b.Write(node.Segment.Value(source))
+			if node.SoftLineBreak() || node.HardLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(node.Value)
+		case *ast.CodeBlock, *ast.FencedCodeBlock:
+			lines := node.Lines()
+			for i := 0; i < lines.Len(); i++ {
+				seg := lines.At(i)
+				b.Write(seg.Value(source))
+			}
+		case *ast.Heading, *ast.Paragraph, *ast.ListItem:
+			if b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.TrimSpace(b.String())
+}
+
+// 截取摘要，合并空白
+func excerpt(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= excerptLength {
+		return s
+	}
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:excerptLength])) + "…"
+}
+
+// 估算阅读时间，至少1分钟
+func readingMinutes(s string) int {
+	cjk, words := 0, 0
+	inWord := false
+	for _, r := range s {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			cjk++
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if !inWord {
+				words++
+			}
+			inWord = true
+		default:
+			inWord = false
+		}
+	}
+	seconds := cjk*60/cjkPerMinute + words*60/wordPerMinute
+	minutes := (seconds + 59) / 60
+	if minutes < 1 {
+		minutes = 1
+	}
+	return minutes
+}
+
+// 标题id生成，保留中文等非ASCII字符，goldmark默认会把它们丢掉
+type headingIDs struct {
+	used map[string]bool
+}
+
+func newHeadingIDs() *headingIDs {
+	return &headingIDs{used: map[string]bool{}}
+}
+
+func (s *headingIDs) Generate(value []byte, kind ast.NodeKind) []byte {
+	var b strings.Builder
+	for _, r := range strings.TrimSpace(string(value)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(unicode.ToLower(r))
+		case unicode.IsSpace(r) || r == '-' || r == '_':
+			b.WriteByte('-')
+		}
+	}
+	id := b.String()
+	if id == "" {
+		id = "heading"
+	}
+	candidate := id
+	for i := 1; s.used[candidate]; i++ {
+		candidate = id + "-" + strconv.Itoa(i)
+	}
+	s.used[candidate] = true
+	return []byte(candidate)
+}
+
+func (s *headingIDs) Put(value []byte) {
+	s.used[string(value)] = true
+}
+
+// 按内容哈希缓存渲染结果，超过容量时淘汰最久未使用的
+type lru struct {
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type lruEntry struct {
+	key   string
+	value *Rendered
+}
+
+var cache = &lru{ll: list.New(), items: map[string]*list.Element{}}
+
+func (c *lru) get(key string) (*Rendered, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		return e.Value.(*lruEntry).value, true
+	}
+	return nil, false
+}
+
+func (c *lru) put(key string, value *Rendered) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		e.Value.(*lruEntry).value = value
+		return
+	}
+	c.items[key] = c.ll.PushFront(&lruEntry{key: key, value: value})
+	if c.ll.Len() > cacheSize {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry).key)
+	}
+}
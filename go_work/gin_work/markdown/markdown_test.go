@@ -0,0 +1,199 @@
+package markdown
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// 检查渲染结果中的标签，文本中的 "javascript:" 等内容已被转义，不会执行
+var (
+	tagPattern  = regexp.MustCompile(`<(/?)([A-Za-z0-9]+)([^>]*)>`)
+	attrPattern = regexp.MustCompile(`\s([A-Za-z-]+)(="[^"]*")?`)
+)
+
+// 不允许出现的元素
+var forbiddenTags = map[string]bool{"script": true, "iframe": true, "object": true, "embed": true,
+	"svg": true, "math": true, "style": true, "form": true, "base": true, "meta": true, "link": true}
+
+// 返回HTML中第一个不安全的标签或属性
+func unsafeMarkup(html string) string {
+	for _, m := range tagPattern.FindAllStringSubmatch(html, -1) {
+		if forbiddenTags[strings.ToLower(m[2])] {
+			return m[0]
+		}
+		// 属性都应带引号，去掉合法的属性后不应剩下其他内容
+		if rest := strings.TrimSpace(strings.TrimSuffix(attrPattern.ReplaceAllString(m[3], ""), "/")); rest != "" {
+			return m[0]
+		}
+		for _, a := range attrPattern.FindAllStringSubmatch(m[3], -1) {
+			name := strings.ToLower(a[1])
+			value := strings.ToLower(strings.Trim(strings.TrimPrefix(a[2], "="), `"`))
+			if strings.HasPrefix(name, "on") || name == "style" || name == "srcdoc" || name == "action" || name == "formaction" {
+				return m[0]
+			}
+			if name == "href" || name == "src" {
+				scheme := strings.Map(func(r rune) rune {
+					if r <= ' ' {
+						return -1
+					}
+					return r
+				}, value)
+				for _, bad := range []string{"javascript:", "vbscript:", "data:", "&#"} {
+					if strings.HasPrefix(scheme, bad) {
+						return m[0]
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// 检查函数本身能识别不安全的HTML，避免下面的测试空跑
+func TestUnsafeMarkup(t *testing.T) {
+	tests := []struct {
+		html   string
+		unsafe bool
+	}{
+		{`<p>javascript:alert(1)</p>`, false},
+		{`<a href="https://example.com" rel="nofollow">x</a>`, false},
+		{`<h1 id="title-onclick">t</h1>`, false},
+		{`<input checked="" disabled="" type="checkbox"/>`, false},
+		{`<script>alert(1)</script>`, true},
+		{`<img src="x" onerror="alert(1)">`, true},
+		{`<img src=x>`, true},
+		{`<a href="javascript:alert(1)">x</a>`, true},
+		{"<a href=\" java\tscript:alert(1)\">x</a>", true},
+		{`<p style="color:red">x</p>`, true},
+	}
+	for _, tt := range tests {
+		if got := unsafeMarkup(tt.html) != ""; got != tt.unsafe {
+			t.Errorf("unsafeMarkup(%q) = %v, want %v", tt.html, got, tt.unsafe)
+		}
+	}
+}
+
+func TestRenderSanitizesXSS(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{"raw script", "<script>alert(1)</script>"},
+		{"inline script", "hello <script>alert(1)</script> world"},
+		{"img onerror", `<img src=x onerror="alert(1)">`},
+		{"svg onload", `<svg onload=alert(1)>`},
+		{"iframe", `<iframe src="https://evil.example"></iframe>`},
+		{"iframe srcdoc", `<iframe srcdoc="<script>alert(1)</script>"></iframe>`},
+		{"object", `<object data="javascript:alert(1)"></object>`},
+		{"style tag", `<style>body{display:none}</style>`},
+		{"style attribute", `<p style="background:url(javascript:alert(1))">x</p>`},
+		{"form", `<form action="https://evil.example"><input name=a></form>`},
+		{"meta refresh", `<meta http-equiv="refresh" content="0;url=javascript:alert(1)">`},
+		{"markdown link", "[click](javascript:alert(1))"},
+		{"uppercase scheme", "[click](JaVaScRiPt:alert(1))"},
+		{"entity encoded scheme", "[click](&#106;avascript:alert(1))"},
+		{"tab in scheme", "[click](java\tscript:alert(1))"},
+		{"vbscript link", "[click](vbscript:msgbox(1))"},
+		{"data link", "[click](data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==)"},
+		{"image javascript", "![x](javascript:alert(1))"},
+		{"autolink", "<javascript:alert(1)>"},
+		{"raw anchor", `<a href="javascript:alert(1)">x</a>`},
+		{"anchor event", `<a href="https://example.com" onclick="alert(1)">x</a>`},
+		{"reference link", "[x][1]\n\n[1]: javascript:alert(1)"},
+		{"link title breakout", `[x](https://example.com "a\" onmouseover=\"alert(1))`},
+		{"heading attribute", "# title {onclick=alert(1)}"},
+		{"code class injection", "```\" onmouseover=\"alert(1)\nx\n```"},
+		{"html in table", "| a |\n|---|\n| <img src=x onerror=alert(1)> |"},
+		{"nested tags", "<<script>script>alert(1)<</script>/script>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html := render([]byte(tt.source)).HTML
+			if bad := unsafeMarkup(html); bad != "" {
+				t.Fatalf("render(%q) contains %s: %s", tt.source, bad, html)
+			}
+		})
+	}
+}
+
+func TestRenderKeepsSafeMarkup(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{"link gets nofollow", "[blog](https://example.com/a)", []string{`<a href="https://example.com/a" rel="nofollow">blog</a>`}},
+		{"heading id", "# Hello World", []string{`<h1 id="hello-world">Hello World</h1>`}},
+		{"chinese heading id", "## 安装 指南", []string{`<h2 id="安装-指南">安装 指南</h2>`}},
+		{"code language", "```go\nfmt.Println()\n```", []string{`<code class="language-go">`}},
+		{"task list", "- [x] done\n- [ ] todo", []string{`<input checked="" disabled="" type="checkbox"`, `<input disabled="" type="checkbox"`}},
+		{"table", "| a | b |\n|---|---|\n| 1 | 2 |", []string{"<table>", "<td>1</td>"}},
+		{"strikethrough", "~~old~~", []string{"<del>old</del>"}},
+		{"image", "![logo](https://example.com/logo.png)", []string{`<img src="https://example.com/logo.png" alt="logo"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html := render([]byte(tt.source)).HTML
+			for _, w := range tt.want {
+				if !strings.Contains(html, w) {
+					t.Fatalf("render(%q) = %s, want %s", tt.source, html, w)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderTOC(t *testing.T) {
+	r := Render("# Intro\n\ntext\n\n## Setup\n\n## Setup\n\n### 配置 文件\n")
+	want := []Heading{
+		{Level: 1, ID: "intro", Text: "Intro"},
+		{Level: 2, ID: "setup", Text: "Setup"},
+		{Level: 2, ID: "setup-1", Text: "Setup"},
+		{Level: 3, ID: "配置-文件", Text: "配置 文件"},
+	}
+	if !reflect.DeepEqual(r.TOC, want) {
+		t.Fatalf("TOC = %+v, want %+v", r.TOC, want)
+	}
+	for _, h := range want {
+		if !strings.Contains(r.HTML, `id="`+h.ID+`"`) {
+			t.Fatalf("html has no heading %q: %s", h.ID, r.HTML)
+		}
+	}
+}
+
+func TestRenderExcerptAndReadingTime(t *testing.T) {
+	tests := []struct {
+		name        string
+		source      string
+		wantExcerpt string
+		wantMinutes int
+	}{
+		{"empty", "", "", 1},
+		{"paragraphs only", "# Title\n\nFirst   line\nsecond line.\n\n```\ncode\n```\n\nLast.", "First line second line. Last.", 1},
+		{"english words", strings.Repeat("word ", 450), strings.Repeat("word ", 32)[:159] + "…", 3},
+		{"chinese characters", strings.Repeat("字", 900), strings.Repeat("字", 160) + "…", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := render([]byte(tt.source))
+			if r.Excerpt != tt.wantExcerpt {
+				t.Errorf("Excerpt = %q, want %q", r.Excerpt, tt.wantExcerpt)
+			}
+			if r.ReadingMinutes != tt.wantMinutes {
+				t.Errorf("ReadingMinutes = %d, want %d", r.ReadingMinutes, tt.wantMinutes)
+			}
+		})
+	}
+}
+
+func TestRenderCache(t *testing.T) {
+	a := Render("cached *content*")
+	if b := Render("cached *content*"); a != b {
+		t.Fatal("expected cached result")
+	}
+	if c := Render("other content"); c == a {
+		t.Fatal("different content shares a result")
+	}
+}
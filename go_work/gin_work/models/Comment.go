@@ -2,46 +2,239 @@ package models
 
 import (
 	"errors"
-	"fmt"
 	"gin_work/dao"
+	"github.com/jinzhu/gorm"
 	"time"
 )
 
 type Comment struct {
 	CommentId int       `json:"commentId" gorm:"PRIMARY_KEY;AUTO_INCREMENT"`
-	Blog      Blog      `json:"blog" gorm:"foreignKey:BlogID"`
 	BlogID    int       `json:"blogId" gorm:"index"` // 为BlogID创建索引，优化查询性能
-	User      User      `json:"user" gorm:"foreignKey:UserId"`
-	UserName  string    `json:"userName"` // 用于存储User的外键
+	UserId    int       `json:"userId" gorm:"index"` // 评论者ID，由登录令牌确定
+	UserName  string    `json:"userName"`            // 评论者用户名，冗余存储用于展示
 	Content   string    `json:"content" gorm:"type:text"`
 	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	// ParentId 回复的评论ID，0表示直接评论博客
+	ParentId int `json:"parentId" gorm:"index"`
+	// Status 审核状态，只有审核通过的评论对所有人可见
+	Status string `json:"status" gorm:"type:varchar(16);default:'approved';index"`
+	// EditedAt 作者最后一次修改的时间
+	EditedAt *time.Time `json:"editedAt,omitempty"`
+	// RemovedAt 删除时间，删除后保留在楼中楼里，只显示"评论已删除"
+	RemovedAt *time.Time `json:"removedAt,omitempty"`
+	// Replies 树形列表中的回复
+	Replies []*Comment `json:"replies,omitempty" gorm:"-"`
+	// HeldReasons 内容审核要求人工审核的原因，不为空时保存后进入审核队列
+	HeldReasons []string `json:"-" gorm:"-"`
 }
 
-// 新增评论
+// 评论审核状态
+const (
+	CommentPending  = "pending"  // 待审核
+	CommentApproved = "approved" // 已通过
+	CommentRejected = "rejected" // 已拒绝
+)
+
+// CommentRemovedText 已删除评论显示的内容
+const CommentRemovedText = "评论已删除"
+
+// 是否已被删除
+func (c *Comment) IsRemoved() bool {
+	return c.RemovedAt != nil
+}
+
+// 已删除的评论隐藏内容和评论者
+func (c *Comment) mask() {
+	if c.IsRemoved() {
+		c.Content = CommentRemovedText
+		c.UserId = 0
+		c.UserName = ""
+	}
+}
+
+// 新增评论，回复时父评论必须属于同一篇博客且已公开
 func CreateComment(comment *Comment) (err error) {
-	err = dao.DB.Debug().Create(&comment).Error
+	if comment.ParentId != 0 {
+		parent, err := GetAComment(comment.ParentId)
+		if err != nil || parent.BlogID != comment.BlogID {
+			return errors.New("parent comment not found")
+		}
+		if parent.IsRemoved() || parent.Status != CommentApproved {
+			return errors.New("cannot reply to this comment")
+		}
+	}
+	tx := dao.DB.Begin()
+	err = tx.Debug().Create(&comment).Error
 	if err != nil {
+		tx.Rollback()
+		return errors.New("create comment error")
+	}
+	if len(comment.HeldReasons) > 0 {
+		if err = holdForReview(tx, commentReview(comment, comment.HeldReasons)); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err = tx.Commit().Error; err != nil {
 		return errors.New("create comment error")
 	}
 	return nil
 }
 
-// 获取评论列表
-func GetComment(id int, commentList *[]Comment) (err error) {
-	err = dao.DB.Debug().Where("blog_id=?", id).Find(&commentList).Error
+// CommentQuery 评论列表的查询条件
+type CommentQuery struct {
+	BlogId int
+	// ViewerId 查询者，未审核的评论只有评论者本人能看到
+	ViewerId int
+	// Moderator 查询者是博客作者或管理员，可以看到所有未审核的评论
+	Moderator bool
+	// Limit 大于0时分页查询
+	Limit int
+	Page  int
+}
+
+// 按可见性过滤评论
+func commentScope(q CommentQuery) *gorm.DB {
+	db := dao.DB.Model(&Comment{}).Where("blog_id = ?", q.BlogId)
+	if !q.Moderator {
+		db = db.Where("status = ? OR user_id = ?", CommentApproved, q.ViewerId)
+	}
+	return db
+}
+
+// 获取评论列表，按时间先后排序
+// Limit 为0时返回全部评论，total 为符合条件的评论总数
+func GetComment(q CommentQuery) (commentList []Comment, total int, err error) {
+	db := commentScope(q)
+	if err = db.Count(&total).Error; err != nil {
+		return nil, 0, errors.New("get comment error")
+	}
+	if q.Limit > 0 {
+		if q.Page < 1 {
+			q.Page = 1
+		}
+		db = db.Offset((q.Page - 1) * q.Limit).Limit(q.Limit)
+	}
+	err = db.Order("created_at, comment_id").Find(&commentList).Error
+	if err != nil {
+		return nil, 0, errors.New("get comment error")
+	}
+	for i := range commentList {
+		commentList[i].mask()
+	}
+	return commentList, total, nil
+}
+
+// 把评论列表组装成树，父评论不可见的回复提升为顶级评论
+func CommentTree(commentList []Comment) []*Comment {
+	nodes := make(map[int]*Comment, len(commentList))
+	for i := range commentList {
+		nodes[commentList[i].CommentId] = &commentList[i]
+	}
+	roots := []*Comment{}
+	for i := range commentList {
+		comment := &commentList[i]
+		if parent, ok := nodes[comment.ParentId]; ok && comment.ParentId != 0 {
+			parent.Replies = append(parent.Replies, comment)
+		} else {
+			roots = append(roots, comment)
+		}
+	}
+	return roots
+}
+
+// 获取单个评论
+func GetAComment(commentId int) (comment *Comment, err error) {
+	comment = new(Comment)
+	err = dao.DB.Where("comment_id=?", commentId).First(comment).Error
+	if err != nil {
+		return nil, errors.New("get comment error")
+	}
+	return comment, nil
+}
+
+// 修改评论内容，status 为修改后的审核状态，heldReasons 不为空时进入内容审核队列
+func EditComment(comment *Comment, content string, status string, heldReasons []string) (err error) {
+	now := time.Now()
+	tx := dao.DB.Begin()
+	err = tx.Model(&Comment{}).Where("comment_id = ? AND removed_at IS NULL", comment.CommentId).Updates(map[string]interface{}{
+		"content":   content,
+		"status":    status,
+		"edited_at": now,
+	}).Error
 	if err != nil {
-		return errors.New("get comment error")
+		tx.Rollback()
+		return errors.New("edit comment error")
+	}
+	if len(heldReasons) > 0 {
+		edited := *comment
+		edited.Content = content
+		if err = holdForReview(tx, commentReview(&edited, heldReasons)); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err = tx.Commit().Error; err != nil {
+		return errors.New("edit comment error")
 	}
 	return nil
 }
 
-// 删除评论
+// 删除评论，只清空内容并标记删除，回复仍然保留
 func DelComment(idiot int) (err error) {
-	fmt.Printf("id = %v", idiot)
-	err = dao.DB.Debug().Where("comment_id=?", idiot).Delete(&Comment{}).Error
+	err = dao.DB.Debug().Model(&Comment{}).Where("comment_id=? AND removed_at IS NULL", idiot).Updates(map[string]interface{}{
+		"content":    "",
+		"removed_at": time.Now(),
+	}).Error
 	if err != nil {
 		return errors.New("delete comment error")
 	}
 	return nil
 }
+
+// 审核评论
+func SetCommentStatus(commentId int, status string) (err error) {
+	if status != CommentApproved && status != CommentRejected && status != CommentPending {
+		return errors.New("invalid status")
+	}
+	// 被内容审核拦截的评论只能在审核队列中通过
+	if status == CommentApproved {
+		held, err := HasPendingReview(ReviewComment, commentId)
+		if err != nil {
+			return err
+		}
+		if held {
+			return ErrUnderReview
+		}
+	}
+	err = dao.DB.Model(&Comment{}).Where("comment_id = ?", commentId).UpdateColumn("status", status).Error
+	if err != nil {
+		return errors.New("update comment error")
+	}
+	if status == CommentRejected {
+		if err = rejectCommentReviews(commentId); err != nil {
+			return errors.New("update comment error")
+		}
+	}
+	return nil
+}
+
+// 待审核评论列表，ownerId 为0时返回所有博客的待审核评论
+func PendingComments(ownerId, offset, limit int) (commentList []Comment, total int, err error) {
+	db := dao.DB.Model(&Comment{}).
+		Joins("JOIN blogs ON blogs.blog_id = comments.blog_id").
+		Where("comments.status = ? AND comments.removed_at IS NULL AND blogs.deleted_at IS NULL", CommentPending)
+	if ownerId != 0 {
+		db = db.Where("blogs.user_id = ?", ownerId)
+	}
+	if err = db.Count(&total).Error; err != nil {
+		return nil, 0, errors.New("get comment error")
+	}
+	err = db.Select("comments.*").Order("comments.created_at, comments.comment_id").
+		Offset(offset).Limit(limit).Find(&commentList).Error
+	if err != nil {
+		return nil, 0, errors.New("get comment error")
+	}
+	return commentList, total, nil
+}
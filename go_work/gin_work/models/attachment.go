@@ -0,0 +1,201 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"gin_work/dao"
+	"gin_work/storage"
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// AttachmentURLPrefix 附件的访问路径前缀，博客内容通过该路径引用附件
+const AttachmentURLPrefix = "/attachment/"
+
+// 博客内容中对附件的引用，如 /attachment/2026/10/<32位十六进制>.png
+var attachmentRef = regexp.MustCompile(regexp.QuoteMeta(AttachmentURLPrefix) + `((?:thumbs/)?\d{4}/\d{2}/[0-9a-f]{32}\.[a-z0-9]+)`)
+
+// Attachment 上传的附件
+type Attachment struct {
+	AttachmentId int    `json:"attachmentId" gorm:"PRIMARY_KEY;AUTO_INCREMENT"`
+	UserId       int    `json:"userId" gorm:"index"` // 上传者
+	StorageKey   string `json:"-" gorm:"type:varchar(128);UNIQUE"`
+	// ThumbKey 缩略图，只有图片才有
+	ThumbKey    string    `json:"-" gorm:"type:varchar(128);index"`
+	ThumbSize   int64     `json:"-"`
+	FileName    string    `json:"fileName" gorm:"type:varchar(255)"`
+	ContentType string    `json:"contentType" gorm:"type:varchar(64)"`
+	Size        int64     `json:"size"`
+	Width       int       `json:"width,omitempty"`
+	Height      int       `json:"height,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	// 访问地址
+	URL      string `json:"url" gorm:"-"`
+	ThumbURL string `json:"thumbUrl,omitempty" gorm:"-"`
+}
+
+// BlogAttachment 博客对附件的引用，没有被任何博客引用的附件会被清理
+type BlogAttachment struct {
+	BlogId       int `gorm:"PRIMARY_KEY;AUTO_INCREMENT:false"`
+	AttachmentId int `gorm:"PRIMARY_KEY;AUTO_INCREMENT:false;index"`
+}
+
+// 填充访问地址
+func (a *Attachment) fillURL() {
+	a.URL = AttachmentURLPrefix + a.StorageKey
+	if a.ThumbKey != "" {
+		a.ThumbURL = AttachmentURLPrefix + a.ThumbKey
+	}
+}
+
+// 生成随机的存储路径，ext 为带"."的扩展名
+func NewAttachmentKey(ext string, now time.Time) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return now.Format("2006/01/") + hex.EncodeToString(b) + ext, nil
+}
+
+// 保存附件记录
+func CreateAttachment(a *Attachment) (err error) {
+	if err = dao.DB.Create(a).Error; err != nil {
+		return errors.New("create attachment error")
+	}
+	a.fillURL()
+	return nil
+}
+
+// 获取附件
+func GetAttachment(attachmentId int) (a *Attachment, err error) {
+	a = new(Attachment)
+	if err = dao.DB.Where("attachment_id = ?", attachmentId).First(a).Error; err != nil {
+		return nil, errors.New("attachment not found")
+	}
+	a.fillURL()
+	return a, nil
+}
+
+// 根据存储路径获取附件，thumb 表示路径是缩略图
+func GetAttachmentByKey(key string) (a *Attachment, thumb bool, err error) {
+	a = new(Attachment)
+	if err = dao.DB.Where("storage_key = ? OR thumb_key = ?", key, key).First(a).Error; err != nil {
+		return nil, false, errors.New("attachment not found")
+	}
+	a.fillURL()
+	return a, a.ThumbKey == key, nil
+}
+
+// 用户上传的附件，按上传时间倒序
+func GetAttachments(userId, offset, limit int) (list []Attachment, total int, err error) {
+	db := dao.DB.Model(&Attachment{}).Where("user_id = ?", userId)
+	if err = db.Count(&total).Error; err != nil {
+		return nil, 0, errors.New("read attachment error")
+	}
+	if err = db.Order("attachment_id DESC").Offset(offset).Limit(limit).Find(&list).Error; err != nil {
+		return nil, 0, errors.New("read attachment error")
+	}
+	for i := range list {
+		list[i].fillURL()
+	}
+	return list, total, nil
+}
+
+// 删除附件及其文件
+func DelAttachment(a *Attachment) (err error) {
+	tx := dao.DB.Begin()
+	if err = tx.Where("attachment_id = ?", a.AttachmentId).Delete(&BlogAttachment{}).Error; err != nil {
+		tx.Rollback()
+		return errors.New("delete attachment error")
+	}
+	if err = tx.Where("attachment_id = ?", a.AttachmentId).Delete(&Attachment{}).Error; err != nil {
+		tx.Rollback()
+		return errors.New("delete attachment error")
+	}
+	if err = tx.Commit().Error; err != nil {
+		return errors.New("delete attachment error")
+	}
+	// 记录删除之后再删文件，文件删除失败只会留下无人引用的文件
+	deleteAttachmentBlobs(a)
+	return nil
+}
+
+func deleteAttachmentBlobs(a *Attachment) {
+	ctx := context.Background()
+	for _, key := range []string{a.StorageKey, a.ThumbKey} {
+		if key == "" {
+			continue
+		}
+		if err := storage.Default.Delete(ctx, key); err != nil {
+			log.Printf("delete blob %s failed, err:%v", key, err)
+		}
+	}
+}
+
+// 根据博客内容重新记录引用的附件，只能引用博客作者自己上传的附件
+func linkBlogAttachments(tx *gorm.DB, blogId int, content string) error {
+	if err := tx.Where("blog_id = ?", blogId).Delete(&BlogAttachment{}).Error; err != nil {
+		return errors.New("save attachment error")
+	}
+	matches := attachmentRef.FindAllStringSubmatch(content, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(matches))
+	for _, m := range matches {
+		keys = append(keys, m[1])
+	}
+	err := tx.Exec("INSERT IGNORE INTO blog_attachments (blog_id, attachment_id) "+
+		"SELECT ?, attachments.attachment_id FROM attachments JOIN blogs ON blogs.user_id = attachments.user_id "+
+		"WHERE blogs.blog_id = ? AND (attachments.storage_key IN (?) OR attachments.thumb_key IN (?))",
+		blogId, blogId, keys, keys).Error
+	if err != nil {
+		return errors.New("save attachment error")
+	}
+	return nil
+}
+
+// 清理上传时间早于before且没有被任何博客引用的附件，返回清理的数量
+func CollectOrphanAttachments(before time.Time) (n int, err error) {
+	var orphans []Attachment
+	err = dao.DB.Where("created_at < ? AND attachment_id NOT IN (SELECT attachment_id FROM blog_attachments)", before).
+		Limit(500).Find(&orphans).Error
+	if err != nil {
+		return 0, errors.New("read attachment error")
+	}
+	for i := range orphans {
+		// 查询之后可能刚被博客引用，删除时再确认一次
+		res := dao.DB.Exec("DELETE FROM attachments WHERE attachment_id = ? AND NOT EXISTS "+
+			"(SELECT 1 FROM blog_attachments WHERE blog_attachments.attachment_id = attachments.attachment_id)",
+			orphans[i].AttachmentId)
+		if res.Error != nil {
+			return n, errors.New("delete attachment error")
+		}
+		if res.RowsAffected == 1 {
+			deleteAttachmentBlobs(&orphans[i])
+			n++
+		}
+	}
+	return n, nil
+}
+
+// 启动后台协程，定期清理上传后超过grace仍未被引用的附件
+func StartAttachmentGC(interval, grace time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			n, err := CollectOrphanAttachments(now.Add(-grace))
+			if err != nil {
+				log.Printf("collect orphan attachments failed, err:%v", err)
+			} else if n > 0 {
+				log.Printf("collected %d orphan attachments", n)
+			}
+		}
+	}()
+}
@@ -3,6 +3,10 @@ package models
 import (
 	"errors"
 	"gin_work/dao"
+	"gin_work/markdown"
+	"gin_work/search"
+	"github.com/jinzhu/gorm"
+	"log"
 	"time"
 )
 
@@ -11,50 +15,169 @@ type Blog struct {
 	BlogId    int       `form:"blogId" gorm:"PRIMARY_KEY;AUTO_INCREMENT"`
 	Title     string    `form:"title" gorm:"type:varchar(255)"`
 	Content   string    `form:"content" gorm:"type:text"`
-	User      User      `form:"user" gorm:"foreignKey:UserId"` // 注意调整字段名和外键
-	UserName  string    `form:"userName"`                      // 用于存储User的外键
+	UserId    int       `form:"-" gorm:"index"` // 作者ID，由登录令牌确定
+	UserName  string    `form:"-"`              // 作者用户名，冗余存储用于展示
 	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	// Status 发布状态，只有已发布的博客对作者以外的人可见
+	Status string `form:"status" gorm:"type:varchar(16);default:'published';index"`
+	// PublishAt 发布时间，定时发布时为将来的时间
+	PublishAt *time.Time `form:"publishAt" time_format:"2006-01-02T15:04:05Z07:00"`
+	// Version 版本号，每次修改加1，修改时需要提交读取时的版本号
+	Version int `form:"version" gorm:"default:1"`
+	// CategoryId 所属分类，为空表示未分类
+	CategoryId *int `form:"categoryId" json:"categoryId" gorm:"index"`
+	// Tags 博客的标签，保存在 blog_tags 中，只用于返回
+	Tags []Tag `form:"-" json:"tagList" gorm:"-"`
+	// TagNames 创建和修改时提交的标签名，字段为tags，修改时为nil表示不改变标签
+	TagNames []string `form:"tags" json:"tags,omitempty" gorm:"-"`
+	// LikeCount 点赞数，随点赞和取消点赞更新
+	LikeCount int `form:"-" json:"likeCount"`
+	// ViewCount 浏览数，定期从内存批量写入
+	ViewCount int `form:"-" json:"viewCount"`
+	// Rendered Content 按Markdown渲染的结果，只在查看单个博客时返回
+	Rendered *markdown.Rendered `form:"-" json:"rendered,omitempty" gorm:"-"`
+	// DeletedAt 移入回收站的时间，不为空时普通查询不会返回该博客，超过保留时长后彻底删除
+	DeletedAt *time.Time `form:"-" json:"deletedAt,omitempty" gorm:"index"`
+	// HeldReasons 内容审核要求人工审核的原因，不为空时保存后进入审核队列
+	HeldReasons []string `form:"-" json:"-" gorm:"-"`
+}
+
+// 博客状态
+const (
+	BlogDraft     = "draft"     // 草稿
+	BlogScheduled = "scheduled" // 定时发布
+	BlogPublished = "published" // 已发布
+	BlogArchived  = "archived"  // 已归档
+	BlogPending   = "pending"   // 内容待人工审核
+)
+
+// 是否对所有人可见
+func (b *Blog) IsPublic() bool {
+	return b.Status == BlogPublished
 }
 
 func CreateBlog(blog *Blog) (err error) {
-	// 根据blog中的内容新建信息
-	err = dao.DB.Create(&blog).Error
+	// 根据blog中的内容新建信息，同时保存第一个历史版本
+	blog.Version = 1
+	tx := dao.DB.Begin()
+	err = tx.Create(&blog).Error
 	if err != nil {
+		tx.Rollback()
+		return errors.New("create blog error")
+	}
+	err = tx.Create(&BlogRevision{
+		BlogId:     blog.BlogId,
+		Version:    blog.Version,
+		Title:      blog.Title,
+		Content:    blog.Content,
+		AuthorId:   blog.UserId,
+		AuthorName: blog.UserName,
+	}).Error
+	if err != nil {
+		tx.Rollback()
+		return errors.New("create blog error")
+	}
+	if err = saveBlogTaxonomy(tx, blog.BlogId, blog); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = linkBlogAttachments(tx, blog.BlogId, blog.Content); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if blog.Status == BlogPending {
+		if err = holdForReview(tx, blogReview(blog.BlogId, blog, &User{UserId: blog.UserId, UserName: blog.UserName})); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err = tx.Commit().Error; err != nil {
 		return errors.New("create blog error")
 	}
+	indexBlog(blog)
+	if blog.IsPublic() {
+		blogPublished(blog)
+	}
 	return nil
 }
 
 // 修改博客
-func UpdateBlog(blogId int, blog *Blog) (err error) {
-	// 根据ID更新
-	err = dao.DB.Debug().Model(&blog).Where("blog_id=?", blogId).Updates(map[string]interface{}{
-		"Title":   blog.Title,
-		"Content": blog.Content,
+// blog.Version 为编辑者读取博客时的版本号，与当前版本不一致时返回 ErrVersionConflict
+// 每次修改都会保存一个新的历史版本
+func UpdateBlog(blogId int, blog *Blog, editor *User) (err error) {
+	return updateBlog(blogId, blog, editor, 0)
+}
+
+func updateBlog(blogId int, blog *Blog, editor *User, restoredFrom int) (err error) {
+	fields := map[string]interface{}{
+		"title":   blog.Title,
+		"content": blog.Content,
+		"version": gorm.Expr("version + 1"),
+	}
+	// 修改后的内容需要人工审核时，审核通过前不公开
+	if len(blog.HeldReasons) > 0 {
+		fields["status"] = BlogPending
+	}
+	tx := dao.DB.Begin()
+	// 根据ID和版本号更新，版本号不一致说明已经被其他人修改
+	res := tx.Model(&Blog{}).Where("blog_id=? AND version=?", blogId, blog.Version).Updates(fields)
+	if res.Error != nil {
+		tx.Rollback()
+		return errors.New("update blog error")
+	}
+	if res.RowsAffected == 0 {
+		tx.Rollback()
+		return ErrVersionConflict
+	}
+	blog.Version++
+	err = tx.Create(&BlogRevision{
+		BlogId:       blogId,
+		Version:      blog.Version,
+		Title:        blog.Title,
+		Content:      blog.Content,
+		AuthorId:     editor.UserId,
+		AuthorName:   editor.UserName,
+		RestoredFrom: restoredFrom,
 	}).Error
 	if err != nil {
+		tx.Rollback()
+		return errors.New("save revision error")
+	}
+	if err = saveBlogTaxonomy(tx, blogId, blog); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = linkBlogAttachments(tx, blogId, blog.Content); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if len(blog.HeldReasons) > 0 {
+		if err = holdForReview(tx, blogReview(blogId, blog, editor)); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err = tx.Commit().Error; err != nil {
 		return errors.New("update blog error")
 	}
+	if updated, err := GetABlog(blogId); err == nil {
+		indexBlog(updated)
+	}
 	return nil
 }
 
 // 删除博客
+// 博客移入回收站，评论、历史版本等关联数据保留到彻底删除时，见 PurgeTrash
 func DelBlog(blogId int) (err error) {
-	// 根据blog中的内容删除blog
+	// 有 DeletedAt 字段时 Delete 只设置删除时间
 	err = dao.DB.Debug().Where("blog_id=?", blogId).Delete(&Blog{}).Error
 	if err != nil {
 		return errors.New("delete blog error")
 	}
-	return nil
-}
-
-// 获取所有博客
-func GetAllBlog(blogList *[]Blog) (err error) {
-	// 从数据库中读取所有的blog
-	err = dao.DB.Debug().Find(&blogList).Error
-	if err != nil {
-		return errors.New("read blog error")
+	dao.DB.Where("blog_id=?", blogId).Delete(&TimelineEntry{})
+	if err = search.Default.Delete(blogId); err != nil {
+		log.Printf("remove blog %d from search index failed, err:%v", blogId, err)
 	}
 	return nil
 }
@@ -67,15 +190,99 @@ func GetABlog(blogId int) (blog *Blog, err error) {
 	if err != nil {
 		return nil, errors.New("read blog error")
 	}
-	return
+	blogs := []Blog{*blog}
+	if err = loadBlogTags(blogs); err != nil {
+		return nil, err
+	}
+	return &blogs[0], nil
 }
 
-// 搜索博客
-func SearchBlog(query string) (blogList []Blog, err error) {
-	// 查询包含指定关键词的博客
-	err = dao.DB.Debug().Where("content LIKE ? OR title LIKE ?", "%"+query+"%", "%"+query+"%").Find(&blogList).Error
+// 保存博客的分类和标签，CategoryId 和 TagNames 为nil时保持不变
+func saveBlogTaxonomy(tx *gorm.DB, blogId int, blog *Blog) error {
+	if blog.CategoryId != nil {
+		// 0表示取消分类
+		var categoryId interface{}
+		if *blog.CategoryId != 0 {
+			if _, err := GetCategory(*blog.CategoryId); err != nil {
+				return err
+			}
+			categoryId = *blog.CategoryId
+		}
+		err := tx.Model(&Blog{}).Where("blog_id = ?", blogId).UpdateColumn("category_id", categoryId).Error
+		if err != nil {
+			return errors.New("save category error")
+		}
+	}
+	if blog.TagNames != nil {
+		return replaceBlogTags(tx, blogId, blog.TagNames)
+	}
+	return nil
+}
+
+// 获取用户已发布的博客
+func GetBlogsByUser(userId int) (blogList []Blog, err error) {
+	err = dao.DB.Where("user_id = ? AND status = ?", userId, BlogPublished).Order("created_at desc").Find(&blogList).Error
 	if err != nil {
-		return nil, err
+		return nil, errors.New("read blog error")
 	}
 	return blogList, nil
 }
+
+// 搜索博客，返回按相关度排序的博客和高亮结果
+// 未发布的博客只有作者本人(viewerId)能搜到
+func SearchBlog(query string, viewerId, offset, limit int) (hits []search.Hit, blogs map[int]*Blog, total int, err error) {
+	result, err := search.Default.Search(search.Query{Text: query, ViewerID: viewerId, Offset: offset, Limit: limit})
+	if err != nil {
+		return nil, nil, 0, errors.New("search blog error")
+	}
+	blogs = map[int]*Blog{}
+	if len(result.Hits) > 0 {
+		ids := make([]int, len(result.Hits))
+		for i, hit := range result.Hits {
+			ids[i] = hit.ID
+		}
+		var blogList []Blog
+		err = dao.DB.Where("blog_id IN (?)", ids).Find(&blogList).Error
+		if err != nil {
+			return nil, nil, 0, errors.New("search blog error")
+		}
+		for i := range blogList {
+			blogs[blogList[i].BlogId] = &blogList[i]
+		}
+	}
+	return result.Hits, blogs, result.Total, nil
+}
+
+// 重建搜索索引，进程内索引在启动时调用
+func RebuildSearchIndex() (err error) {
+	const batch = 500
+	lastId := 0
+	for {
+		var blogList []Blog
+		err = dao.DB.Where("blog_id > ?", lastId).Order("blog_id").Limit(batch).Find(&blogList).Error
+		if err != nil {
+			return errors.New("read blog error")
+		}
+		for i := range blogList {
+			indexBlog(&blogList[i])
+		}
+		if len(blogList) < batch {
+			return nil
+		}
+		lastId = blogList[len(blogList)-1].BlogId
+	}
+}
+
+// 更新博客的搜索索引，索引失败不影响博客本身的保存
+func indexBlog(blog *Blog) {
+	err := search.Default.Index(search.Document{
+		ID:       blog.BlogId,
+		Title:    blog.Title,
+		Content:  blog.Content,
+		AuthorID: blog.UserId,
+		Public:   blog.IsPublic(),
+	})
+	if err != nil {
+		log.Printf("index blog %d failed, err:%v", blog.BlogId, err)
+	}
+}
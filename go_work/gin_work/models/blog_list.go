@@ -0,0 +1,165 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"gin_work/dao"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 每页数量
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// 允许的排序方式，"-"开头表示倒序
+var blogSorts = map[string]string{
+	"-created_at": "created_at DESC, blog_id DESC",
+	"created_at":  "created_at ASC, blog_id ASC",
+	"-updated_at": "updated_at DESC, blog_id DESC",
+	"updated_at":  "updated_at ASC, blog_id ASC",
+	"title":       "title ASC, blog_id ASC",
+	"-title":      "title DESC, blog_id DESC",
+}
+
+// BlogQuery 博客列表的查询条件
+type BlogQuery struct {
+	Limit int
+	// Page 大于0时使用页码分页，否则使用游标分页
+	Page int
+	// Cursor 上一页返回的游标，只支持按创建时间排序
+	Cursor string
+	Sort   string
+	// Author 作者用户名
+	Author string
+	// Tag 标签名
+	Tag string
+	// CategoryId 分类ID，包含子孙分类下的博客
+	CategoryId int
+	// ViewerId 查询者的用户ID，未发布的博客只有作者本人能看到
+	ViewerId int
+	From     time.Time
+	To       time.Time
+}
+
+// BlogPage 一页博客
+type BlogPage struct {
+	List       []Blog
+	Total      int
+	NextCursor string
+}
+
+// 校验排序方式
+func ValidBlogSort(sort string) bool {
+	_, ok := blogSorts[sort]
+	return ok
+}
+
+// 分页查询博客列表
+func ListBlogs(q BlogQuery) (page *BlogPage, err error) {
+	if q.Limit <= 0 {
+		q.Limit = DefaultPageSize
+	}
+	if q.Limit > MaxPageSize {
+		q.Limit = MaxPageSize
+	}
+	if q.Sort == "" {
+		q.Sort = "-created_at"
+	}
+	order, ok := blogSorts[q.Sort]
+	if !ok {
+		return nil, errors.New("invalid sort")
+	}
+
+	db := dao.DB.Model(&Blog{})
+	if q.ViewerId > 0 {
+		db = db.Where("status = ? OR user_id = ?", BlogPublished, q.ViewerId)
+	} else {
+		db = db.Where("status = ?", BlogPublished)
+	}
+	if q.Author != "" {
+		db = db.Where("user_name = ?", q.Author)
+	}
+	if q.Tag != "" {
+		db = db.Where("blog_id IN (SELECT blog_tags.blog_id FROM blog_tags JOIN tags ON tags.tag_id = blog_tags.tag_id WHERE tags.name = ?)", q.Tag)
+	}
+	if q.CategoryId > 0 {
+		ids, err := categoryWithDescendants(q.CategoryId)
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where("category_id IN (?)", ids)
+	}
+	if !q.From.IsZero() {
+		db = db.Where("created_at >= ?", q.From)
+	}
+	if !q.To.IsZero() {
+		db = db.Where("created_at < ?", q.To)
+	}
+
+	page = &BlogPage{}
+	if err = db.Count(&page.Total).Error; err != nil {
+		return nil, errors.New("read blog error")
+	}
+
+	if q.Page > 0 {
+		db = db.Offset((q.Page - 1) * q.Limit)
+	} else if q.Cursor != "" {
+		createdAt, blogId, err := decodeBlogCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		// 游标分页：(created_at, blog_id) 严格位于上一页最后一条之后
+		switch q.Sort {
+		case "-created_at":
+			db = db.Where("created_at < ? OR (created_at = ? AND blog_id < ?)", createdAt, createdAt, blogId)
+		case "created_at":
+			db = db.Where("created_at > ? OR (created_at = ? AND blog_id > ?)", createdAt, createdAt, blogId)
+		default:
+			return nil, errors.New("cursor only supports sorting by created_at")
+		}
+	}
+
+	// 多取一条判断是否还有下一页
+	err = db.Order(order).Limit(q.Limit + 1).Find(&page.List).Error
+	if err != nil {
+		return nil, errors.New("read blog error")
+	}
+	if err = loadBlogTags(page.List); err != nil {
+		return nil, err
+	}
+	if len(page.List) > q.Limit {
+		page.List = page.List[:q.Limit]
+		if q.Page <= 0 && (q.Sort == "-created_at" || q.Sort == "created_at") {
+			last := page.List[len(page.List)-1]
+			page.NextCursor = encodeBlogCursor(last.CreatedAt, last.BlogId)
+		}
+	}
+	return page, nil
+}
+
+// 游标格式: base64(创建时间的纳秒数:博客ID)
+func encodeBlogCursor(createdAt time.Time, blogId int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", createdAt.UnixNano(), blogId)))
+}
+
+func decodeBlogCursor(cursor string) (time.Time, int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, errors.New("invalid cursor")
+	}
+	ts, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return time.Time{}, 0, errors.New("invalid cursor")
+	}
+	nanos, err1 := strconv.ParseInt(ts, 10, 64)
+	blogId, err2 := strconv.Atoi(id)
+	if err1 != nil || err2 != nil {
+		return time.Time{}, 0, errors.New("invalid cursor")
+	}
+	return time.Unix(0, nanos), blogId, nil
+}
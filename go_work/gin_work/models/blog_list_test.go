@@ -0,0 +1,57 @@
+package models
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestBlogCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		createdAt time.Time
+		blogId    int
+	}{
+		{"unix epoch", time.Unix(0, 0), 1},
+		{"nanoseconds", time.Date(2024, 2, 29, 23, 59, 59, 123456789, time.UTC), 42},
+		{"other zone", time.Date(2023, 6, 1, 8, 0, 0, 0, time.FixedZone("CST", 8*3600)), 7},
+		{"large id", time.Date(2030, 1, 1, 0, 0, 0, 1, time.UTC), 1<<31 - 1},
+		{"before epoch", time.Date(1969, 12, 31, 23, 59, 59, 0, time.UTC), 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor := encodeBlogCursor(tt.createdAt, tt.blogId)
+			createdAt, blogId, err := decodeBlogCursor(cursor)
+			if err != nil {
+				t.Fatalf("decode %q: %v", cursor, err)
+			}
+			if !createdAt.Equal(tt.createdAt) || blogId != tt.blogId {
+				t.Fatalf("got (%v, %d), want (%v, %d)", createdAt, blogId, tt.createdAt, tt.blogId)
+			}
+		})
+	}
+}
+
+func TestDecodeBlogCursorInvalid(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"empty", ""},
+		{"not base64", "!!!"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte("12:1"))},
+		{"missing separator", encode("123456")},
+		{"time not a number", encode("abc:1")},
+		{"id not a number", encode("123:abc")},
+		{"extra field", encode("123:1:2")},
+		{"time overflow", encode("99999999999999999999:1")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := decodeBlogCursor(tt.cursor); err == nil {
+				t.Fatalf("decode %q: expected error", tt.cursor)
+			}
+		})
+	}
+}
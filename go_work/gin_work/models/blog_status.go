@@ -0,0 +1,112 @@
+package models
+
+import (
+	"errors"
+	"gin_work/dao"
+	"log"
+	"time"
+)
+
+// 校验并补全新博客的状态
+// 未指定状态时立即发布；发布时间在将来时改为定时发布；草稿不保留发布时间
+func PrepareBlogStatus(blog *Blog, now time.Time) error {
+	switch blog.Status {
+	case "", BlogPublished, BlogScheduled:
+		if blog.PublishAt != nil && blog.PublishAt.After(now) {
+			blog.Status = BlogScheduled
+			return nil
+		}
+		if blog.Status == BlogScheduled {
+			return errors.New("publishAt must be in the future")
+		}
+		blog.Status = BlogPublished
+		blog.PublishAt = &now
+	case BlogDraft, BlogArchived:
+		blog.PublishAt = nil
+	default:
+		return errors.New("invalid status")
+	}
+	return nil
+}
+
+// 发布博客，publishAt为将来的时间时定时发布
+func PublishBlog(blogId int, publishAt *time.Time) (err error) {
+	now := time.Now()
+	status := BlogPublished
+	if publishAt != nil && publishAt.After(now) {
+		status = BlogScheduled
+	} else {
+		publishAt = &now
+	}
+	return setBlogStatus(blogId, map[string]interface{}{"status": status, "publish_at": *publishAt})
+}
+
+// 取消发布，博客回到草稿状态
+func UnpublishBlog(blogId int) (err error) {
+	return setBlogStatus(blogId, map[string]interface{}{"status": BlogDraft, "publish_at": nil})
+}
+
+// 归档博客，归档后不再公开
+func ArchiveBlog(blogId int) (err error) {
+	return setBlogStatus(blogId, map[string]interface{}{"status": BlogArchived})
+}
+
+// 修改状态并更新搜索索引中的可见性
+func setBlogStatus(blogId int, fields map[string]interface{}) (err error) {
+	err = dao.DB.Model(&Blog{}).Where("blog_id = ?", blogId).Updates(fields).Error
+	if err != nil {
+		return errors.New("update blog status error")
+	}
+	if blog, err := GetABlog(blogId); err == nil {
+		indexBlog(blog)
+		if blog.IsPublic() {
+			blogPublished(blog)
+		}
+	}
+	return nil
+}
+
+// 博客公开后的处理
+func blogPublished(blog *Blog) {
+	fanoutBlog(blog)
+}
+
+// 发布所有已到时间的定时博客，返回发布的数量
+func PublishDueBlogs(now time.Time) (n int, err error) {
+	var due []Blog
+	err = dao.DB.Where("status = ? AND publish_at <= ?", BlogScheduled, now).Find(&due).Error
+	if err != nil {
+		return 0, errors.New("read blog error")
+	}
+	for i := range due {
+		// 以状态为条件更新，期间被作者修改过状态的博客不会被覆盖
+		res := dao.DB.Model(&Blog{}).Where("blog_id = ? AND status = ?", due[i].BlogId, BlogScheduled).
+			Update("status", BlogPublished)
+		if res.Error != nil {
+			return n, errors.New("publish blog error")
+		}
+		if res.RowsAffected == 1 {
+			due[i].Status = BlogPublished
+			indexBlog(&due[i])
+			blogPublished(&due[i])
+			n++
+		}
+	}
+	return n, nil
+}
+
+// 启动后台发布协程，定期发布到时间的定时博客
+func StartPublisher(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			n, err := PublishDueBlogs(now)
+			if err != nil {
+				log.Printf("publish scheduled blogs failed, err:%v", err)
+			} else if n > 0 {
+				log.Printf("published %d scheduled blogs", n)
+			}
+		}
+	}()
+}
@@ -0,0 +1,151 @@
+package models
+
+import (
+	"errors"
+	"gin_work/dao"
+	"strings"
+	"time"
+)
+
+// Category 分类，ParentId 为0表示顶级分类
+type Category struct {
+	CategoryId int       `json:"categoryId" gorm:"PRIMARY_KEY;AUTO_INCREMENT"`
+	Name       string    `json:"name" gorm:"type:varchar(64);not null"`
+	ParentId   int       `json:"parentId" gorm:"index"`
+	CreatedAt  time.Time `json:"-"`
+}
+
+// CategoryNode 分类树中的一个节点
+type CategoryNode struct {
+	Category
+	Children []*CategoryNode `json:"children"`
+}
+
+// 读取全部分类，分类数量不多，直接在内存中处理层级
+func allCategories() (categories []Category, err error) {
+	err = dao.DB.Order("name").Find(&categories).Error
+	if err != nil {
+		return nil, errors.New("read category error")
+	}
+	return categories, nil
+}
+
+// 分类树
+func CategoryTree() ([]*CategoryNode, error) {
+	categories, err := allCategories()
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(map[int]*CategoryNode, len(categories))
+	for _, category := range categories {
+		nodes[category.CategoryId] = &CategoryNode{Category: category, Children: []*CategoryNode{}}
+	}
+	roots := []*CategoryNode{}
+	for _, category := range categories {
+		node := nodes[category.CategoryId]
+		if parent, ok := nodes[category.ParentId]; ok {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	return roots, nil
+}
+
+// 分类及其所有子孙分类的ID
+func categoryWithDescendants(categoryId int) ([]int, error) {
+	categories, err := allCategories()
+	if err != nil {
+		return nil, err
+	}
+	children := map[int][]int{}
+	for _, category := range categories {
+		children[category.ParentId] = append(children[category.ParentId], category.CategoryId)
+	}
+	ids := []int{categoryId}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, children[ids[i]]...)
+	}
+	return ids, nil
+}
+
+// 获取分类
+func GetCategory(categoryId int) (category *Category, err error) {
+	category = new(Category)
+	err = dao.DB.Where("category_id = ?", categoryId).First(category).Error
+	if err != nil {
+		return nil, errors.New("category not found")
+	}
+	return category, nil
+}
+
+// 新建分类
+func CreateCategory(name string, parentId int) (category *Category, err error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("name required")
+	}
+	if parentId != 0 {
+		if _, err = GetCategory(parentId); err != nil {
+			return nil, errors.New("parent category not found")
+		}
+	}
+	category = &Category{Name: name, ParentId: parentId}
+	if err = dao.DB.Create(category).Error; err != nil {
+		return nil, errors.New("create category error")
+	}
+	return category, nil
+}
+
+// 修改分类的名字和上级分类，不允许移动到自己的子孙分类下
+func UpdateCategory(categoryId int, name string, parentId int) (category *Category, err error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("name required")
+	}
+	if category, err = GetCategory(categoryId); err != nil {
+		return nil, err
+	}
+	if parentId != 0 {
+		ids, err := categoryWithDescendants(categoryId)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if id == parentId {
+				return nil, errors.New("category cannot be moved under itself")
+			}
+		}
+		if _, err = GetCategory(parentId); err != nil {
+			return nil, errors.New("parent category not found")
+		}
+	}
+	err = dao.DB.Model(category).Updates(map[string]interface{}{"name": name, "parent_id": parentId}).Error
+	if err != nil {
+		return nil, errors.New("update category error")
+	}
+	return category, nil
+}
+
+// 删除分类，有子分类时不能删除，原分类下的博客变为未分类
+func DelCategory(categoryId int) (err error) {
+	var count int
+	dao.DB.Model(&Category{}).Where("parent_id = ?", categoryId).Count(&count)
+	if count > 0 {
+		return errors.New("category has children")
+	}
+	tx := dao.DB.Begin()
+	// 回收站中的博客也取消分类
+	if err = tx.Unscoped().Model(&Blog{}).Where("category_id = ?", categoryId).UpdateColumn("category_id", nil).Error; err != nil {
+		tx.Rollback()
+		return errors.New("delete category error")
+	}
+	if err = tx.Where("category_id = ?", categoryId).Delete(&Category{}).Error; err != nil {
+		tx.Rollback()
+		return errors.New("delete category error")
+	}
+	if err = tx.Commit().Error; err != nil {
+		return errors.New("delete category error")
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"errors"
+	"gin_work/dao"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+// BlogLike 用户点赞
+type BlogLike struct {
+	BlogId    int `gorm:"PRIMARY_KEY;AUTO_INCREMENT:false"`
+	UserId    int `gorm:"PRIMARY_KEY;AUTO_INCREMENT:false;index"`
+	CreatedAt time.Time
+}
+
+// Bookmark 用户收藏
+type Bookmark struct {
+	UserId    int `gorm:"PRIMARY_KEY;AUTO_INCREMENT:false"`
+	BlogId    int `gorm:"PRIMARY_KEY;AUTO_INCREMENT:false;index"`
+	CreatedAt time.Time
+}
+
+// 点赞，重复点赞不会重复计数，返回最新的点赞数
+func LikeBlog(blogId, userId int) (count int, err error) {
+	return toggleLike(blogId, userId, true)
+}
+
+// 取消点赞，返回最新的点赞数
+func UnlikeBlog(blogId, userId int) (count int, err error) {
+	return toggleLike(blogId, userId, false)
+}
+
+func toggleLike(blogId, userId int, like bool) (count int, err error) {
+	tx := dao.DB.Begin()
+	var res *gorm.DB
+	if like {
+		res = tx.Exec("INSERT IGNORE INTO blog_likes (blog_id, user_id, created_at) VALUES (?, ?, ?)", blogId, userId, time.Now())
+	} else {
+		res = tx.Where("blog_id = ? AND user_id = ?", blogId, userId).Delete(&BlogLike{})
+	}
+	if res.Error != nil {
+		tx.Rollback()
+		return 0, errors.New("like blog error")
+	}
+	// 只有点赞状态真正发生变化时才修改计数
+	if res.RowsAffected == 1 {
+		delta := 1
+		if !like {
+			delta = -1
+		}
+		err = tx.Model(&Blog{}).Where("blog_id = ?", blogId).
+			UpdateColumn("like_count", gorm.Expr("like_count + ?", delta)).Error
+		if err != nil {
+			tx.Rollback()
+			return 0, errors.New("like blog error")
+		}
+	}
+	if err = tx.Commit().Error; err != nil {
+		return 0, errors.New("like blog error")
+	}
+	var blog Blog
+	if err = dao.DB.Select("like_count").Where("blog_id = ?", blogId).First(&blog).Error; err != nil {
+		return 0, errors.New("read blog error")
+	}
+	return blog.LikeCount, nil
+}
+
+// 收藏博客，重复收藏不报错
+func AddBookmark(blogId, userId int) (err error) {
+	err = dao.DB.Exec("INSERT IGNORE INTO bookmarks (user_id, blog_id, created_at) VALUES (?, ?, ?)", userId, blogId, time.Now()).Error
+	if err != nil {
+		return errors.New("bookmark error")
+	}
+	return nil
+}
+
+// 取消收藏
+func DelBookmark(blogId, userId int) (err error) {
+	err = dao.DB.Where("user_id = ? AND blog_id = ?", userId, blogId).Delete(&Bookmark{}).Error
+	if err != nil {
+		return errors.New("bookmark error")
+	}
+	return nil
+}
+
+// 用户的收藏列表，按收藏时间倒序；收藏后被作者取消发布的博客不再返回
+func GetBookmarks(userId, offset, limit int) (blogList []Blog, total int, err error) {
+	db := dao.DB.Model(&Blog{}).
+		Joins("JOIN bookmarks ON bookmarks.blog_id = blogs.blog_id").
+		Where("bookmarks.user_id = ?", userId).
+		Where("blogs.status = ? OR blogs.user_id = ?", BlogPublished, userId)
+	if err = db.Count(&total).Error; err != nil {
+		return nil, 0, errors.New("read bookmark error")
+	}
+	err = db.Select("blogs.*").Order("bookmarks.created_at DESC, blogs.blog_id DESC").
+		Offset(offset).Limit(limit).Find(&blogList).Error
+	if err != nil {
+		return nil, 0, errors.New("read bookmark error")
+	}
+	if err = loadBlogTags(blogList); err != nil {
+		return nil, 0, err
+	}
+	return blogList, total, nil
+}
+
+// 热门排行的权重：点赞、评论、浏览对热度的贡献，以及随时间衰减的指数
+const (
+	hotLikeWeight    = 3.0
+	hotCommentWeight = 2.0
+	hotViewWeight    = 0.1
+	hotGravity       = 1.5
+	// 只在最近一段时间发布的博客中排行
+	hotWindow = 30 * 24 * time.Hour
+)
+
+// HotBlog 热门博客及其热度
+type HotBlog struct {
+	Blog
+	Score float64 `json:"score"`
+}
+
+// 热门博客
+// 热度 = (点赞*3 + 评论*2 + 浏览*0.1 + 1) / (发布小时数 + 2)^1.5
+func HotBlogs(now time.Time, offset, limit int) (blogList []HotBlog, err error) {
+	score := "(blogs.like_count * ? + " +
+		"(SELECT COUNT(*) FROM comments WHERE comments.blog_id = blogs.blog_id AND comments.status = ? AND comments.removed_at IS NULL) * ? + " +
+		"blogs.view_count * ? + 1) / POW(GREATEST(TIMESTAMPDIFF(SECOND, blogs.publish_at, ?), 0) / 3600 + 2, ?)"
+	err = dao.DB.Table("blogs").
+		Select("blogs.*, "+score+" AS score",
+			hotLikeWeight, CommentApproved, hotCommentWeight, hotViewWeight, now, hotGravity).
+		Where("blogs.status = ? AND blogs.publish_at >= ? AND blogs.deleted_at IS NULL", BlogPublished, now.Add(-hotWindow)).
+		Order("score DESC, blogs.blog_id DESC").Offset(offset).Limit(limit).Scan(&blogList).Error
+	if err != nil {
+		return nil, errors.New("read blog error")
+	}
+	blogs := make([]Blog, len(blogList))
+	for i := range blogList {
+		blogs[i] = blogList[i].Blog
+	}
+	if err = loadBlogTags(blogs); err != nil {
+		return nil, err
+	}
+	for i := range blogList {
+		blogList[i].Tags = blogs[i].Tags
+	}
+	return blogList, nil
+}
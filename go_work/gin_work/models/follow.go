@@ -0,0 +1,244 @@
+package models
+
+import (
+	"errors"
+	"gin_work/dao"
+	"gin_work/setting"
+	"log"
+	"time"
+)
+
+// Follow 关注关系
+type Follow struct {
+	FollowerId int `gorm:"PRIMARY_KEY;AUTO_INCREMENT:false"`
+	FolloweeId int `gorm:"PRIMARY_KEY;AUTO_INCREMENT:false;index"`
+	CreatedAt  time.Time
+}
+
+// TimelineEntry 写扩散时预先写入粉丝时间线的博客
+type TimelineEntry struct {
+	UserId    int       `gorm:"PRIMARY_KEY;AUTO_INCREMENT:false"`
+	BlogId    int       `gorm:"PRIMARY_KEY;AUTO_INCREMENT:false;index"`
+	AuthorId  int       `gorm:"index"`
+	PublishAt time.Time `gorm:"index"`
+}
+
+// 成为热门作者时，只把最近一段时间的博客写入粉丝的时间线
+const timelineBackfillWindow = 90 * 24 * time.Hour
+
+// 时间线配置，由 StartFeed 设置
+var feedConfig = &setting.FeedConfig{}
+
+// 关注作者，重复关注不报错
+func FollowUser(followerId, followeeId int) (err error) {
+	if followerId == followeeId {
+		return errors.New("cannot follow yourself")
+	}
+	followee, err := GetUserById(followeeId)
+	if err != nil {
+		return errors.New("user not found")
+	}
+	res := dao.DB.Exec("INSERT IGNORE INTO follows (follower_id, followee_id, created_at) VALUES (?, ?, ?)",
+		followerId, followeeId, time.Now())
+	if res.Error != nil {
+		return errors.New("follow error")
+	}
+	// 热门作者的博客不在读取时查询，需要补写到新粉丝的时间线
+	if res.RowsAffected == 1 && followee.FanoutOnWrite {
+		err = dao.DB.Exec("INSERT IGNORE INTO timeline_entries (user_id, blog_id, author_id, publish_at) "+
+			"SELECT ?, blog_id, user_id, publish_at FROM blogs WHERE user_id = ? AND status = ? AND publish_at >= ? AND deleted_at IS NULL",
+			followerId, followeeId, BlogPublished, time.Now().Add(-timelineBackfillWindow)).Error
+		if err != nil {
+			return errors.New("follow error")
+		}
+	}
+	return nil
+}
+
+// 取消关注
+func UnfollowUser(followerId, followeeId int) (err error) {
+	err = dao.DB.Where("follower_id = ? AND followee_id = ?", followerId, followeeId).Delete(&Follow{}).Error
+	if err != nil {
+		return errors.New("unfollow error")
+	}
+	err = dao.DB.Where("user_id = ? AND author_id = ?", followerId, followeeId).Delete(&TimelineEntry{}).Error
+	if err != nil {
+		return errors.New("unfollow error")
+	}
+	return nil
+}
+
+// 是否已关注
+func IsFollowing(followerId, followeeId int) bool {
+	var count int
+	dao.DB.Model(&Follow{}).Where("follower_id = ? AND followee_id = ?", followerId, followeeId).Count(&count)
+	return count > 0
+}
+
+// 粉丝数和关注数
+func FollowCounts(userId int) (followers, following int) {
+	dao.DB.Model(&Follow{}).Where("followee_id = ?", userId).Count(&followers)
+	dao.DB.Model(&Follow{}).Where("follower_id = ?", userId).Count(&following)
+	return followers, following
+}
+
+// 粉丝列表，按关注时间倒序
+func GetFollowers(userId, offset, limit int) ([]PublicProfile, int, error) {
+	return followList("follows.follower_id", "follows.followee_id", userId, offset, limit)
+}
+
+// 关注列表，按关注时间倒序
+func GetFollowing(userId, offset, limit int) ([]PublicProfile, int, error) {
+	return followList("follows.followee_id", "follows.follower_id", userId, offset, limit)
+}
+
+// 查询关注关系中另一方的公开资料，join 为要返回的一方，where 为条件中的一方
+func followList(join, where string, userId, offset, limit int) (profiles []PublicProfile, total int, err error) {
+	db := dao.DB.Model(&User{}).Joins("JOIN follows ON users.user_id = "+join).Where(where+" = ?", userId)
+	if err = db.Count(&total).Error; err != nil {
+		return nil, 0, errors.New("read follow error")
+	}
+	var users []User
+	err = db.Select("users.*").Order("follows.created_at DESC").Offset(offset).Limit(limit).Find(&users).Error
+	if err != nil {
+		return nil, 0, errors.New("read follow error")
+	}
+	profiles = make([]PublicProfile, len(users))
+	for i := range users {
+		profiles[i] = users[i].PublicProfile()
+	}
+	return profiles, total, nil
+}
+
+// 关注时间线，按发布时间倒序，使用游标分页
+// 普通作者的博客在读取时查询(读扩散)；开启写扩散时，热门作者的博客从预先写入的时间线中读取
+func GetFeed(userId int, cursor string, limit int) (blogList []Blog, nextCursor string, err error) {
+	if limit <= 0 || limit > MaxPageSize {
+		limit = DefaultPageSize
+	}
+	db := dao.DB.Model(&Blog{}).Where("status = ?", BlogPublished)
+	if feedConfig.FanoutOnWrite {
+		db = db.Where("user_id IN (SELECT follows.followee_id FROM follows JOIN users ON users.user_id = follows.followee_id "+
+			"WHERE follows.follower_id = ? AND users.fanout_on_write = ?) "+
+			"OR blog_id IN (SELECT blog_id FROM timeline_entries WHERE user_id = ?)", userId, false, userId)
+	} else {
+		db = db.Where("user_id IN (SELECT followee_id FROM follows WHERE follower_id = ?)", userId)
+	}
+	if cursor != "" {
+		publishAt, blogId, err := decodeBlogCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		db = db.Where("publish_at < ? OR (publish_at = ? AND blog_id < ?)", publishAt, publishAt, blogId)
+	}
+	err = db.Order("publish_at DESC, blog_id DESC").Limit(limit + 1).Find(&blogList).Error
+	if err != nil {
+		return nil, "", errors.New("read feed error")
+	}
+	if len(blogList) > limit {
+		blogList = blogList[:limit]
+		last := blogList[len(blogList)-1]
+		if last.PublishAt != nil {
+			nextCursor = encodeBlogCursor(*last.PublishAt, last.BlogId)
+		}
+	}
+	if err = loadBlogTags(blogList); err != nil {
+		return nil, "", err
+	}
+	return blogList, nextCursor, nil
+}
+
+// 热门作者发布博客时写入所有粉丝的时间线
+func fanoutBlog(blog *Blog) {
+	if !feedConfig.FanoutOnWrite || blog.PublishAt == nil {
+		return
+	}
+	author, err := GetUserById(blog.UserId)
+	if err != nil || !author.FanoutOnWrite {
+		return
+	}
+	err = dao.DB.Exec("INSERT IGNORE INTO timeline_entries (user_id, blog_id, author_id, publish_at) "+
+		"SELECT follower_id, ?, ?, ? FROM follows WHERE followee_id = ?",
+		blog.BlogId, blog.UserId, *blog.PublishAt, blog.UserId).Error
+	if err != nil {
+		log.Printf("fanout blog %d failed, err:%v", blog.BlogId, err)
+	}
+}
+
+// 重新统计热门作者
+// 粉丝数达到阈值的作者补写时间线后开启写扩散；粉丝数降到阈值一半以下时关闭，避免在阈值附近反复切换
+func RefreshPopularAuthors() (err error) {
+	threshold := feedConfig.PopularFollowers
+	if threshold <= 0 {
+		return nil
+	}
+	var promote, demote []int
+	err = dao.DB.Table("follows").Joins("JOIN users ON users.user_id = follows.followee_id").
+		Where("users.fanout_on_write = ?", false).Group("follows.followee_id").
+		Having("COUNT(*) >= ?", threshold).Pluck("follows.followee_id", &promote).Error
+	if err != nil {
+		return errors.New("read follow error")
+	}
+	err = dao.DB.Model(&User{}).Where("fanout_on_write = ? AND "+
+		"(SELECT COUNT(*) FROM follows WHERE follows.followee_id = users.user_id) < ?", true, (threshold+1)/2).
+		Pluck("user_id", &demote).Error
+	if err != nil {
+		return errors.New("read follow error")
+	}
+	for _, authorId := range promote {
+		err = dao.DB.Exec("INSERT IGNORE INTO timeline_entries (user_id, blog_id, author_id, publish_at) "+
+			"SELECT follows.follower_id, blogs.blog_id, blogs.user_id, blogs.publish_at FROM follows "+
+			"JOIN blogs ON blogs.user_id = follows.followee_id "+
+			"WHERE follows.followee_id = ? AND blogs.status = ? AND blogs.publish_at >= ? AND blogs.deleted_at IS NULL",
+			authorId, BlogPublished, time.Now().Add(-timelineBackfillWindow)).Error
+		if err != nil {
+			return errors.New("fanout error")
+		}
+		// 时间线写好之后再切换，切换前读取时仍然查询该作者的博客
+		if err = dao.DB.Model(&User{}).Where("user_id = ?", authorId).UpdateColumn("fanout_on_write", true).Error; err != nil {
+			return errors.New("fanout error")
+		}
+	}
+	for _, authorId := range demote {
+		// 先切换回读扩散，再删除预先写入的时间线
+		if err = dao.DB.Model(&User{}).Where("user_id = ?", authorId).UpdateColumn("fanout_on_write", false).Error; err != nil {
+			return errors.New("fanout error")
+		}
+		if err = dao.DB.Where("author_id = ?", authorId).Delete(&TimelineEntry{}).Error; err != nil {
+			return errors.New("fanout error")
+		}
+	}
+	return nil
+}
+
+// 加载时间线配置
+// 开启写扩散时定期统计热门作者；关闭时清除之前写扩散留下的数据，全部改为读扩散
+func StartFeed(cfg *setting.FeedConfig) (err error) {
+	if cfg != nil {
+		feedConfig = cfg
+	}
+	if !feedConfig.FanoutOnWrite {
+		err = dao.DB.Model(&User{}).Where("fanout_on_write = ?", true).UpdateColumn("fanout_on_write", false).Error
+		if err != nil {
+			return err
+		}
+		return dao.DB.Exec("DELETE FROM timeline_entries").Error
+	}
+	if err = RefreshPopularAuthors(); err != nil {
+		return err
+	}
+	interval := feedConfig.RefreshInterval
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := RefreshPopularAuthors(); err != nil {
+				log.Printf("refresh popular authors failed, err:%v", err)
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"errors"
+	"gin_work/dao"
+	"time"
+)
+
+// LoginAttempt 登录失败计数，供多个实例共享
+type LoginAttempt struct {
+	AttemptKey   string    `json:"attemptKey" gorm:"PRIMARY_KEY;type:varchar(191)"`
+	Failures     int       `json:"failures"`
+	FirstFailure time.Time `json:"first_failure"`
+	LockedUntil  time.Time `json:"locked_until"`
+}
+
+// LoginAudit 登录失败的审计记录
+type LoginAudit struct {
+	AuditId   int       `json:"auditId" gorm:"PRIMARY_KEY;AUTO_INCREMENT"`
+	UserName  string    `json:"userName" gorm:"index"`
+	IP        string    `json:"ip" gorm:"type:varchar(64);index"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LoginAttemptStore 基于数据库的登录失败计数，实现 security.Store
+type LoginAttemptStore struct{}
+
+func (LoginAttemptStore) Fail(key string, now time.Time, window time.Duration) (int, error) {
+	// 窗口外的旧记录重新从1开始计数，整个过程在一条语句中完成
+	start := now.Add(-window)
+	err := dao.DB.Exec("INSERT INTO login_attempts (attempt_key, failures, first_failure, locked_until) VALUES (?, 1, ?, ?) "+
+		"ON DUPLICATE KEY UPDATE failures = IF(first_failure < ?, 1, failures + 1), "+
+		"first_failure = IF(first_failure < ?, VALUES(first_failure), first_failure)",
+		key, now, now, start, start).Error
+	if err != nil {
+		return 0, errors.New("record login attempt error")
+	}
+	var attempt LoginAttempt
+	err = dao.DB.Where("attempt_key = ?", key).First(&attempt).Error
+	if err != nil {
+		return 0, errors.New("read login attempt error")
+	}
+	return attempt.Failures, nil
+}
+
+func (LoginAttemptStore) Lock(key string, until time.Time) error {
+	err := dao.DB.Model(&LoginAttempt{}).Where("attempt_key = ?", key).Update("locked_until", until).Error
+	if err != nil {
+		return errors.New("lock login attempt error")
+	}
+	return nil
+}
+
+func (LoginAttemptStore) LockedUntil(key string) (time.Time, error) {
+	var attempt LoginAttempt
+	err := dao.DB.Where("attempt_key = ?", key).First(&attempt).Error
+	if err != nil {
+		// 没有记录表示没有被锁定
+		return time.Time{}, nil
+	}
+	return attempt.LockedUntil, nil
+}
+
+func (LoginAttemptStore) Reset(key string) error {
+	err := dao.DB.Where("attempt_key = ?", key).Delete(&LoginAttempt{}).Error
+	if err != nil {
+		return errors.New("reset login attempt error")
+	}
+	return nil
+}
+
+// 记录登录失败
+func RecordLoginFailure(userName, ip, reason string) (err error) {
+	err = dao.DB.Create(&LoginAudit{UserName: userName, IP: ip, Reason: reason}).Error
+	if err != nil {
+		return errors.New("record login failure error")
+	}
+	return nil
+}
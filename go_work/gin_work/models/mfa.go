@@ -0,0 +1,109 @@
+package models
+
+import (
+	"crypto/subtle"
+	"errors"
+	"gin_work/dao"
+	"strings"
+)
+
+// 保存待确认的两步验证密钥
+func SetTOTPSecret(userId int, secret string) (err error) {
+	err = dao.DB.Model(&User{}).Where("user_id = ? AND totp_enabled = ?", userId, false).
+		Updates(map[string]interface{}{"totp_secret": secret, "totp_last_step": 0}).Error
+	if err != nil {
+		return errors.New("save totp secret error")
+	}
+	return nil
+}
+
+// 确认验证码后开启两步验证，并保存恢复码
+func EnableTOTP(userId int, step int64, recoveryCodes []string) (err error) {
+	err = dao.DB.Model(&User{}).Where("user_id = ?", userId).Updates(map[string]interface{}{
+		"totp_enabled":   true,
+		"totp_last_step": step,
+		"recovery_codes": hashRecoveryCodes(recoveryCodes),
+	}).Error
+	if err != nil {
+		return errors.New("enable totp error")
+	}
+	return nil
+}
+
+// 关闭两步验证，清除密钥和恢复码
+func DisableTOTP(userId int) (err error) {
+	err = dao.DB.Model(&User{}).Where("user_id = ?", userId).Updates(map[string]interface{}{
+		"totp_enabled":   false,
+		"totp_secret":    "",
+		"totp_last_step": 0,
+		"recovery_codes": "",
+	}).Error
+	if err != nil {
+		return errors.New("disable totp error")
+	}
+	return nil
+}
+
+// 重新生成恢复码，旧的恢复码全部失效
+func SetRecoveryCodes(userId int, recoveryCodes []string) (err error) {
+	err = dao.DB.Model(&User{}).Where("user_id = ?", userId).
+		Update("recovery_codes", hashRecoveryCodes(recoveryCodes)).Error
+	if err != nil {
+		return errors.New("save recovery codes error")
+	}
+	return nil
+}
+
+// 记录已使用的时间步，同一时间步及更早的验证码不能再次使用
+func UseTOTPStep(userId int, step int64) (err error) {
+	res := dao.DB.Model(&User{}).Where("user_id = ? AND totp_last_step < ?", userId, step).
+		Update("totp_last_step", step)
+	if res.Error != nil || res.RowsAffected != 1 {
+		return errors.New("code already used")
+	}
+	return nil
+}
+
+// 使用恢复码，每个恢复码只能使用一次
+func UseRecoveryCode(user *User, code string) (err error) {
+	hash := hashToken(normalizeRecoveryCode(code))
+	var rest []string
+	found := false
+	for _, h := range strings.Split(user.RecoveryCodes, ",") {
+		if h == "" {
+			continue
+		}
+		if !found && subtle.ConstantTimeCompare([]byte(h), []byte(hash)) == 1 {
+			found = true
+			continue
+		}
+		rest = append(rest, h)
+	}
+	if !found {
+		return errors.New("invalid code")
+	}
+	// 以旧值为条件更新，防止并发请求重复使用同一个恢复码
+	remaining := strings.Join(rest, ",")
+	res := dao.DB.Model(&User{}).Where("user_id = ? AND recovery_codes = ?", user.UserId, user.RecoveryCodes).
+		Update("recovery_codes", remaining)
+	if res.Error != nil || res.RowsAffected != 1 {
+		return errors.New("invalid code")
+	}
+	user.RecoveryCodes = remaining
+	return nil
+}
+
+// 计算恢复码的哈希，恢复码是高熵随机数，使用SHA-256即可
+func hashRecoveryCodes(codes []string) string {
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = hashToken(normalizeRecoveryCode(code))
+	}
+	return strings.Join(hashes, ",")
+}
+
+// 忽略大小写、空格和连字符
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(strings.ReplaceAll(code, "-", ""), " ", "")
+}
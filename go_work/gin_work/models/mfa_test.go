@@ -0,0 +1,73 @@
+package models
+
+import (
+	"gin_work/dao"
+	"testing"
+)
+
+func setupMFAUser(t *testing.T, codes []string) *User {
+	t.Helper()
+	setupTestDB(t, &User{})
+	user := &User{UserName: "alice", Password: "x"}
+	if err := dao.DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := EnableTOTP(user.UserId, 100, codes); err != nil {
+		t.Fatal(err)
+	}
+	return reloadUser(t, user.UserId)
+}
+
+func reloadUser(t *testing.T, userId int) *User {
+	t.Helper()
+	var user User
+	if err := dao.DB.First(&user, userId).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &user
+}
+
+func TestUseRecoveryCodeOnce(t *testing.T) {
+	user := setupMFAUser(t, []string{"abcde-fghij", "klmno-pqrst"})
+	// 另一个请求在使用前加载的用户
+	stale := reloadUser(t, user.UserId)
+
+	if err := UseRecoveryCode(user, "ABCDE FGHIJ"); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if err := UseRecoveryCode(user, "abcde-fghij"); err == nil {
+		t.Fatal("code used twice")
+	}
+	if err := UseRecoveryCode(reloadUser(t, user.UserId), "abcdefghij"); err == nil {
+		t.Fatal("code used twice after reload")
+	}
+	// 并发请求持有旧的恢复码列表，以旧值为条件的更新不会成功
+	if err := UseRecoveryCode(stale, "abcde-fghij"); err == nil {
+		t.Fatal("code reused through stale user")
+	}
+	if err := UseRecoveryCode(user, "zzzzz-zzzzz"); err == nil {
+		t.Fatal("unknown code accepted")
+	}
+	if err := UseRecoveryCode(reloadUser(t, user.UserId), "klmno-pqrst"); err != nil {
+		t.Fatalf("other code rejected: %v", err)
+	}
+	if remaining := reloadUser(t, user.UserId).RecoveryCodes; remaining != "" {
+		t.Fatalf("codes left: %q", remaining)
+	}
+}
+
+// 同一时间步或更早的验证码不能重复使用
+func TestUseTOTPStep(t *testing.T) {
+	user := setupMFAUser(t, nil)
+	if err := UseTOTPStep(user.UserId, 100); err == nil {
+		t.Fatal("step used at enable accepted")
+	}
+	if err := UseTOTPStep(user.UserId, 101); err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range []int64{101, 99} {
+		if err := UseTOTPStep(user.UserId, step); err == nil {
+			t.Fatalf("step %d accepted after 101", step)
+		}
+	}
+}
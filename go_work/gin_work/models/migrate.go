@@ -0,0 +1,77 @@
+package models
+
+import (
+	"gin_work/dao"
+)
+
+// 根据模型创建数据库表项，并执行数据迁移
+func Migrate() (err error) {
+	if dao.DB.HasTable(&User{}) {
+		if err = dedupeUserEmails(); err != nil {
+			return err
+		}
+	}
+	err = dao.DB.AutoMigrate(&User{}, &Blog{}, &Comment{},
+		&RefreshToken{}, &RevokedToken{}, &SiweNonce{}, &UserToken{},
+		&LoginAttempt{}, &LoginAudit{}, &BlogRevision{},
+		&Tag{}, &BlogTag{}, &Category{}, &BlogLike{}, &Bookmark{},
+		&Follow{}, &TimelineEntry{}, &Notification{},
+		&Attachment{}, &BlogAttachment{}, &ImportedRecord{}, &Review{}).Error
+	if err != nil {
+		return err
+	}
+	// 博客列表的游标分页按 (created_at, blog_id) 排序
+	err = dao.DB.Model(&Blog{}).AddIndex("idx_blogs_created_at_blog_id", "created_at", "blog_id").Error
+	if err != nil {
+		return err
+	}
+	if err = backfillUserId(); err != nil {
+		return err
+	}
+	// 旧博客创建即发布，以创建时间作为发布时间
+	err = dao.DB.Exec("UPDATE blogs SET publish_at = created_at WHERE status = ? AND publish_at IS NULL", BlogPublished).Error
+	if err != nil {
+		return err
+	}
+	return backfillRevisions()
+}
+
+// 建立邮箱唯一索引前整理旧数据：空邮箱改为NULL；
+// 重复的邮箱只保留在已验证的账号上，都未验证时保留在最早注册的账号上，其余账号需要重新填写邮箱
+// 在 AutoMigrate 之前执行，从没有邮箱验证的旧版本升级时 email_verified 列还不存在，只按注册顺序保留
+func dedupeUserEmails() error {
+	err := dao.DB.Exec("UPDATE users SET email = NULL WHERE email = ''").Error
+	if err != nil {
+		return err
+	}
+	if !dao.DB.Dialect().HasColumn("users", "email_verified") {
+		return dao.DB.Exec("UPDATE users u JOIN users k ON k.email = u.email AND k.user_id < u.user_id " +
+			"SET u.email = NULL").Error
+	}
+	return dao.DB.Exec("UPDATE users u JOIN users k ON k.email = u.email AND k.user_id <> u.user_id " +
+		"AND (k.email_verified > u.email_verified OR (k.email_verified = u.email_verified AND k.user_id < u.user_id)) " +
+		"SET u.email = NULL, u.email_verified = false").Error
+}
+
+// 为没有历史版本的旧博客保存当前内容作为第一个版本
+func backfillRevisions() error {
+	err := dao.DB.Model(&BlogRevision{}).AddUniqueIndex("idx_blog_revisions_blog_version", "blog_id", "version").Error
+	if err != nil {
+		return err
+	}
+	return dao.DB.Exec("INSERT INTO blog_revisions (blog_id, version, title, content, author_id, author_name, restored_from, created_at) " +
+		"SELECT b.blog_id, b.version, b.title, b.content, b.user_id, b.user_name, 0, b.updated_at FROM blogs b " +
+		"WHERE NOT EXISTS (SELECT 1 FROM blog_revisions r WHERE r.blog_id = b.blog_id)").Error
+}
+
+// 旧数据只保存了用户名，根据用户名回填 user_id
+func backfillUserId() (err error) {
+	for _, table := range []string{"blogs", "comments"} {
+		err = dao.DB.Exec("UPDATE " + table + " t JOIN users u ON u.user_name = t.user_name " +
+			"SET t.user_id = u.user_id WHERE t.user_id IS NULL OR t.user_id = 0").Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
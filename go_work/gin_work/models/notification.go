@@ -0,0 +1,121 @@
+package models
+
+import (
+	"errors"
+	"gin_work/dao"
+	"gin_work/notify"
+	"log"
+	"time"
+)
+
+// 通知类型
+const (
+	NotifyComment = "comment" // 博客收到评论
+	NotifyReply   = "reply"   // 评论收到回复
+)
+
+// Notification 站内通知
+type Notification struct {
+	NotificationId int    `json:"notificationId" gorm:"PRIMARY_KEY;AUTO_INCREMENT"`
+	UserId         int    `json:"-" gorm:"index"` // 接收者
+	Type           string `json:"type" gorm:"type:varchar(32)"`
+	// 触发通知的用户
+	ActorId   int    `json:"actorId"`
+	ActorName string `json:"actorName"`
+	BlogId    int    `json:"blogId"`
+	CommentId int    `json:"commentId"`
+	// Message 通知摘要
+	Message   string     `json:"message" gorm:"type:varchar(255)"`
+	ReadAt    *time.Time `json:"readAt"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// 通知摘要最多保留的字数
+const notificationExcerpt = 80
+
+// 保存通知并推送给在线的客户端
+func CreateNotification(n *Notification) (err error) {
+	if err = dao.DB.Create(n).Error; err != nil {
+		return errors.New("create notification error")
+	}
+	notify.Default.Publish(n.UserId, n)
+	return nil
+}
+
+// 新评论通知博客作者；已公开的回复同时通知被回复的评论者
+func NotifyNewComment(blog *Blog, comment *Comment) {
+	if blog.UserId != comment.UserId {
+		notifyComment(NotifyComment, blog.UserId, blog, comment)
+	}
+	if comment.Status == CommentApproved {
+		NotifyCommentReply(blog, comment)
+	}
+}
+
+// 通知被回复的评论者，审核通过的回复也在通过时调用
+func NotifyCommentReply(blog *Blog, comment *Comment) {
+	if comment.ParentId == 0 {
+		return
+	}
+	parent, err := GetAComment(comment.ParentId)
+	if err != nil || parent.UserId == 0 || parent.UserId == comment.UserId || parent.UserId == blog.UserId {
+		return
+	}
+	notifyComment(NotifyReply, parent.UserId, blog, comment)
+}
+
+func notifyComment(kind string, userId int, blog *Blog, comment *Comment) {
+	message := []rune(comment.Content)
+	if len(message) > notificationExcerpt {
+		message = append(message[:notificationExcerpt], '…')
+	}
+	err := CreateNotification(&Notification{
+		UserId:    userId,
+		Type:      kind,
+		ActorId:   comment.UserId,
+		ActorName: comment.UserName,
+		BlogId:    blog.BlogId,
+		CommentId: comment.CommentId,
+		Message:   string(message),
+	})
+	if err != nil {
+		log.Printf("notify user %d failed, err:%v", userId, err)
+	}
+}
+
+// 通知列表，按时间倒序，unread 为true时只返回未读通知
+func GetNotifications(userId int, unread bool, offset, limit int) (list []Notification, total int, err error) {
+	db := dao.DB.Model(&Notification{}).Where("user_id = ?", userId)
+	if unread {
+		db = db.Where("read_at IS NULL")
+	}
+	if err = db.Count(&total).Error; err != nil {
+		return nil, 0, errors.New("read notification error")
+	}
+	err = db.Order("notification_id DESC").Offset(offset).Limit(limit).Find(&list).Error
+	if err != nil {
+		return nil, 0, errors.New("read notification error")
+	}
+	return list, total, nil
+}
+
+// 未读通知数
+func UnreadNotificationCount(userId int) (count int, err error) {
+	err = dao.DB.Model(&Notification{}).Where("user_id = ? AND read_at IS NULL", userId).Count(&count).Error
+	if err != nil {
+		return 0, errors.New("read notification error")
+	}
+	return count, nil
+}
+
+// 标记通知为已读，notificationId 为0时标记全部
+func MarkNotificationsRead(userId, notificationId int) (err error) {
+	db := dao.DB.Model(&Notification{}).Where("user_id = ? AND read_at IS NULL", userId)
+	if notificationId != 0 {
+		db = db.Where("notification_id = ?", notificationId)
+	}
+	if err = db.UpdateColumn("read_at", time.Now()).Error; err != nil {
+		return errors.New("update notification error")
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// argon2id 参数，修改后旧哈希会在下次登录时自动升级
+const (
+	argon2Version        = argon2.Version
+	argon2Time    uint32 = 1
+	argon2Memory  uint32 = 64 * 1024
+	argon2Threads uint8  = 4
+	argon2KeyLen  uint32 = 32
+	argon2SaltLen        = 16
+)
+
+// 校验存储的哈希时允许的参数范围，超出范围的哈希视为无效，避免异常数据导致崩溃或耗尽内存
+const (
+	argon2MaxMemory  uint32 = 1024 * 1024
+	argon2MaxTime    uint32 = 16
+	argon2MinSaltLen        = 8
+	argon2MinKeyLen         = 16
+	argon2MaxKeyLen         = 64
+)
+
+// legacyPepper 旧版SHA-256哈希使用的固定pepper，仅用于校验历史密码
+const legacyPepper = "MyFixedSalt123！"
+
+// 加密密码
+// 输出格式: $argon2id$v=19$m=65536,t=1,p=4$<salt>$<hash>
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// 校验密码
+// ok 表示密码正确，rehash 表示存储的哈希是旧算法或旧参数，需要重新加密
+func verifyPassword(password, encoded string) (ok bool, rehash bool) {
+	if !strings.HasPrefix(encoded, "$") {
+		// 旧版本: sha256(password + pepper) 的十六进制字符串
+		return subtle.ConstantTimeCompare([]byte(legacyHash(password)), []byte(encoded)) == 1, true
+	}
+	p, salt, key, err := decodeArgon2(encoded)
+	if err != nil {
+		return false, false
+	}
+	other := argon2.IDKey([]byte(password), salt, p.time, p.memory, p.threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return false, false
+	}
+	rehash = p.version != argon2Version || p.time != argon2Time || p.memory != argon2Memory ||
+		p.threads != argon2Threads || uint32(len(key)) != argon2KeyLen
+	return true, rehash
+}
+
+type argon2Params struct {
+	version int
+	memory  uint32
+	time    uint32
+	threads uint8
+}
+
+// 解析 argon2id 哈希字符串
+func decodeArgon2(encoded string) (p argon2Params, salt, key []byte, err error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return p, nil, nil, errors.New("unsupported password hash")
+	}
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &p.version); err != nil {
+		return p, nil, nil, err
+	}
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.threads); err != nil {
+		return p, nil, nil, err
+	}
+	// Sscanf 会忽略多余的内容，重新格式化后比较
+	if parts[2] != fmt.Sprintf("v=%d", p.version) ||
+		parts[3] != fmt.Sprintf("m=%d,t=%d,p=%d", p.memory, p.time, p.threads) {
+		return p, nil, nil, errors.New("invalid password hash params")
+	}
+	if p.time < 1 || p.time > argon2MaxTime || p.threads < 1 ||
+		p.memory < 8*uint32(p.threads) || p.memory > argon2MaxMemory {
+		return p, nil, nil, errors.New("invalid password hash params")
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return p, nil, nil, err
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return p, nil, nil, err
+	}
+	if len(salt) < argon2MinSaltLen || len(key) < argon2MinKeyLen || len(key) > argon2MaxKeyLen {
+		return p, nil, nil, errors.New("invalid password hash length")
+	}
+	return p, salt, key, nil
+}
+
+// 旧版本的密码哈希
+func legacyHash(password string) string {
+	hash := sha256.Sum256([]byte(password + legacyPepper))
+	return hex.EncodeToString(hash[:])
+}
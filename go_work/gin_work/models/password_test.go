@@ -0,0 +1,121 @@
+package models
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// 按指定参数生成 argon2id 哈希字符串
+func encodeArgon2(password string, version int, memory, time uint32, threads uint8, salt []byte, keyLen uint32) string {
+	key := argon2.IDKey([]byte(password), salt, time, memory, threads, keyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", version, memory, time, threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+func TestHashPassword(t *testing.T) {
+	encoded, err := hashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=65536,t=1,p=4$") {
+		t.Fatalf("unexpected format %q", encoded)
+	}
+	if ok, rehash := verifyPassword("correct horse", encoded); !ok || rehash {
+		t.Fatalf("verify = (%v, %v), want (true, false)", ok, rehash)
+	}
+	if ok, _ := verifyPassword("correct horse ", encoded); ok {
+		t.Fatal("wrong password accepted")
+	}
+	// 每次使用不同的盐
+	if other, _ := hashPassword("correct horse"); other == encoded {
+		t.Fatal("same hash for two calls")
+	}
+}
+
+func TestVerifyLegacyPassword(t *testing.T) {
+	// sha256("secret" + legacyPepper)，与旧版本注册时保存的值相同
+	const stored = "c155067985a6bb29614e6628d9a00d44a6db2747a5b33e5354947f71e05e7cb7"
+	if ok, rehash := verifyPassword("secret", stored); !ok || !rehash {
+		t.Fatalf("verify legacy = (%v, %v), want (true, true)", ok, rehash)
+	}
+	if ok, _ := verifyPassword("Secret", stored); ok {
+		t.Fatal("wrong password accepted")
+	}
+	if ok, _ := verifyPassword("", ""); ok {
+		t.Fatal("empty hash accepted")
+	}
+}
+
+func TestVerifyPasswordRehash(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{"time", encodeArgon2("pw", argon2.Version, argon2Memory, 2, argon2Threads, salt, argon2KeyLen)},
+		{"memory", encodeArgon2("pw", argon2.Version, 32*1024, argon2Time, argon2Threads, salt, argon2KeyLen)},
+		{"threads", encodeArgon2("pw", argon2.Version, argon2Memory, argon2Time, 2, salt, argon2KeyLen)},
+		{"key length", encodeArgon2("pw", argon2.Version, argon2Memory, argon2Time, argon2Threads, salt, 16)},
+		{"version", encodeArgon2("pw", 16, argon2Memory, argon2Time, argon2Threads, salt, argon2KeyLen)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ok, rehash := verifyPassword("pw", tt.encoded); !ok || !rehash {
+				t.Fatalf("verify = (%v, %v), want (true, true)", ok, rehash)
+			}
+			if ok, _ := verifyPassword("other", tt.encoded); ok {
+				t.Fatal("wrong password accepted")
+			}
+		})
+	}
+	current := encodeArgon2("pw", argon2.Version, argon2Memory, argon2Time, argon2Threads, salt, argon2KeyLen)
+	if ok, rehash := verifyPassword("pw", current); !ok || rehash {
+		t.Fatalf("verify current = (%v, %v), want (true, false)", ok, rehash)
+	}
+}
+
+func TestVerifyPasswordMalformed(t *testing.T) {
+	valid, err := hashPassword("pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(valid, "$")
+	salt, key := parts[4], parts[5]
+	build := func(head, params, salt, key string) string {
+		return "$argon2id$" + head + "$" + params + "$" + salt + "$" + key
+	}
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{"empty", "$"},
+		{"truncated", valid[:len(valid)-10]},
+		{"missing key", strings.Join(parts[:5], "$")},
+		{"extra field", valid + "$x"},
+		{"other algorithm", strings.Replace(valid, "argon2id", "argon2i", 1)},
+		{"bad version", build("v=x", parts[3], salt, key)},
+		{"bad params", build(parts[2], "m=65536,t=1", salt, key)},
+		{"trailing params", build(parts[2], parts[3]+",x=1", salt, key)},
+		{"negative memory", build(parts[2], "m=-1,t=1,p=4", salt, key)},
+		{"zero time", build(parts[2], "m=65536,t=0,p=4", salt, key)},
+		{"zero threads", build(parts[2], "m=65536,t=1,p=0", salt, key)},
+		{"threads overflow", build(parts[2], "m=65536,t=1,p=256", salt, key)},
+		{"memory too large", build(parts[2], "m=4294967295,t=1,p=4", salt, key)},
+		{"memory too small", build(parts[2], "m=16,t=1,p=4", salt, key)},
+		{"bad salt", build(parts[2], parts[3], "!!", key)},
+		{"padded key", build(parts[2], parts[3], salt, key+"=")},
+		{"empty key", build(parts[2], parts[3], salt, "")},
+		{"short salt", build(parts[2], parts[3], "c2FsdA", key)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ok, rehash := verifyPassword("pw", tt.encoded); ok || rehash {
+				t.Fatalf("verify %q = (%v, %v), want (false, false)", tt.encoded, ok, rehash)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package models
+
+import (
+	"errors"
+	"gin_work/dao"
+	"time"
+)
+
+// 注销账号后评论显示的用户名
+const DeletedUserName = "已注销用户"
+
+// PublicProfile 公开的用户资料
+type PublicProfile struct {
+	UserId      int       `json:"userId"`
+	UserName    string    `json:"userName"`
+	DisplayName string    `json:"displayName"`
+	Bio         string    `json:"bio"`
+	AvatarURL   string    `json:"avatarUrl"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// AccountInfo 用户本人可见的账号信息，不包含密码、密钥等敏感字段
+type AccountInfo struct {
+	PublicProfile
+	Email         string  `json:"email"`
+	EmailVerified bool    `json:"emailVerified"`
+	Role          string  `json:"role"`
+	Address       *string `json:"address"`
+	TOTPEnabled   bool    `json:"totpEnabled"`
+	// ModerateComments 为null时使用站点的默认设置
+	ModerateComments *bool `json:"moderateComments"`
+}
+
+// 公开资料
+func (u *User) PublicProfile() PublicProfile {
+	return PublicProfile{
+		UserId:      u.UserId,
+		UserName:    u.UserName,
+		DisplayName: u.DisplayName,
+		Bio:         u.Bio,
+		AvatarURL:   u.AvatarURL,
+		CreatedAt:   u.CreatedAt,
+	}
+}
+
+// 账号信息
+func (u *User) AccountInfo() AccountInfo {
+	return AccountInfo{
+		PublicProfile:    u.PublicProfile(),
+		Email:            u.EmailAddress(),
+		EmailVerified:    u.EmailVerified,
+		Role:             u.Role,
+		Address:          u.Address,
+		TOTPEnabled:      u.TOTPEnabled,
+		ModerateComments: u.ModerateComments,
+	}
+}
+
+// 修改个人资料，fields的键为数据库列名
+func UpdateProfile(userId int, fields map[string]interface{}) (err error) {
+	err = dao.DB.Model(&User{}).Where("user_id = ?", userId).Updates(fields).Error
+	if err != nil {
+		return errors.New("update profile error")
+	}
+	return nil
+}
+
+// 校验密码，用于修改密码、注销账号等敏感操作
+func CheckPassword(user *User, password string) bool {
+	ok, _ := verifyPassword(password, user.Password)
+	return ok
+}
+
+// 注销账号
+// 用户发布的博客连同其下的评论一起删除；用户在他人博客下的评论保留，
+// 但不再关联到用户，显示为"已注销用户"，避免破坏他人的讨论内容
+func DeleteUser(user *User) (err error) {
+	tx := dao.DB.Begin()
+	steps := []func() error{
+		func() error {
+			return tx.Exec("DELETE FROM comments WHERE blog_id IN (SELECT blog_id FROM blogs WHERE user_id = ?)", user.UserId).Error
+		},
+		func() error {
+			return tx.Exec("DELETE FROM blog_revisions WHERE blog_id IN (SELECT blog_id FROM blogs WHERE user_id = ?)", user.UserId).Error
+		},
+		func() error {
+			return tx.Exec("DELETE FROM blog_tags WHERE blog_id IN (SELECT blog_id FROM blogs WHERE user_id = ?)", user.UserId).Error
+		},
+		func() error {
+			return tx.Exec("DELETE FROM blog_likes WHERE blog_id IN (SELECT blog_id FROM blogs WHERE user_id = ?)", user.UserId).Error
+		},
+		func() error {
+			return tx.Exec("DELETE FROM bookmarks WHERE blog_id IN (SELECT blog_id FROM blogs WHERE user_id = ?)", user.UserId).Error
+		},
+		func() error {
+			return tx.Exec("DELETE FROM blog_attachments WHERE blog_id IN (SELECT blog_id FROM blogs WHERE user_id = ?)", user.UserId).Error
+		},
+		func() error {
+			return tx.Exec("DELETE FROM reviews WHERE user_id = ? OR blog_id IN (SELECT blog_id FROM blogs WHERE user_id = ?)",
+				user.UserId, user.UserId).Error
+		},
+		// 包括回收站中的博客，直接彻底删除
+		func() error { return tx.Unscoped().Where("user_id = ?", user.UserId).Delete(&Blog{}).Error },
+		// 用户在他人博客上的点赞同时撤销
+		func() error {
+			return tx.Exec("UPDATE blogs JOIN blog_likes ON blog_likes.blog_id = blogs.blog_id "+
+				"SET blogs.like_count = blogs.like_count - 1 WHERE blog_likes.user_id = ?", user.UserId).Error
+		},
+		func() error { return tx.Where("user_id = ?", user.UserId).Delete(&BlogLike{}).Error },
+		func() error { return tx.Where("user_id = ?", user.UserId).Delete(&Bookmark{}).Error },
+		func() error {
+			return tx.Where("follower_id = ? OR followee_id = ?", user.UserId, user.UserId).Delete(&Follow{}).Error
+		},
+		func() error {
+			return tx.Where("user_id = ? OR author_id = ?", user.UserId, user.UserId).Delete(&TimelineEntry{}).Error
+		},
+		func() error {
+			return tx.Model(&Comment{}).Where("user_id = ?", user.UserId).
+				Updates(map[string]interface{}{"user_id": 0, "user_name": DeletedUserName}).Error
+		},
+		func() error { return tx.Where("user_id = ?", user.UserId).Delete(&Notification{}).Error },
+		func() error { return tx.Where("user_id = ?", user.UserId).Delete(&UserToken{}).Error },
+		func() error { return tx.Where("user_name = ?", user.UserName).Delete(&RefreshToken{}).Error },
+		func() error { return tx.Where("user_id = ?", user.UserId).Delete(&User{}).Error },
+	}
+	for _, step := range steps {
+		if err = step(); err != nil {
+			tx.Rollback()
+			return errors.New("delete user error")
+		}
+	}
+	if err = tx.Commit().Error; err != nil {
+		return errors.New("delete user error")
+	}
+	return nil
+}
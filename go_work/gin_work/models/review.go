@@ -0,0 +1,291 @@
+package models
+
+import (
+	"errors"
+	"gin_work/dao"
+	"github.com/jinzhu/gorm"
+	"strings"
+	"time"
+)
+
+// 审核的内容类型
+const (
+	ReviewBlog    = "blog"
+	ReviewComment = "comment"
+)
+
+// 人工审核状态
+const (
+	ReviewPending  = "pending"  // 待审核
+	ReviewApproved = "approved" // 已通过
+	ReviewRejected = "rejected" // 已拒绝
+)
+
+// 审核队列中保留的内容摘要字数
+const reviewExcerpt = 200
+
+// Review 内容审核时需要人工审核的博客或评论
+type Review struct {
+	ReviewId int    `json:"reviewId" gorm:"PRIMARY_KEY;AUTO_INCREMENT"`
+	Kind     string `json:"kind" gorm:"type:varchar(16);index:idx_reviews_target"`
+	TargetId int    `json:"targetId" gorm:"index:idx_reviews_target"`
+	// BlogId 博客本身或评论所在的博客，彻底删除博客时一起删除
+	BlogId int `json:"blogId" gorm:"index"`
+	// 提交内容的用户
+	UserId   int    `json:"userId" gorm:"index"`
+	UserName string `json:"userName"`
+	// 提交审核时的标题和内容摘要
+	Title   string `json:"title" gorm:"type:varchar(255)"`
+	Excerpt string `json:"excerpt" gorm:"type:varchar(1000)"`
+	// Reasons 审核规则给出的原因，多条用换行分隔
+	Reasons    string     `json:"reasons" gorm:"type:varchar(1000)"`
+	Status     string     `json:"status" gorm:"type:varchar(16);index"`
+	ReviewerId int        `json:"reviewerId"`
+	Note       string     `json:"note" gorm:"type:varchar(255)"`
+	ReviewedAt *time.Time `json:"reviewedAt"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// 新建待审核记录，同一内容已有待审核记录时更新为最新的内容和原因
+func holdForReview(tx *gorm.DB, review *Review) error {
+	review.Excerpt = truncateRunes(review.Excerpt, reviewExcerpt)
+	review.Reasons = truncateRunes(review.Reasons, 1000)
+	review.Status = ReviewPending
+	res := tx.Model(&Review{}).Where("kind = ? AND target_id = ? AND status = ?", review.Kind, review.TargetId, ReviewPending).
+		Updates(map[string]interface{}{
+			"user_id":    review.UserId,
+			"user_name":  review.UserName,
+			"title":      review.Title,
+			"excerpt":    review.Excerpt,
+			"reasons":    review.Reasons,
+			"updated_at": time.Now(),
+		})
+	if res.Error != nil {
+		return errors.New("save review error")
+	}
+	if res.RowsAffected > 0 {
+		return nil
+	}
+	if err := tx.Create(review).Error; err != nil {
+		return errors.New("save review error")
+	}
+	return nil
+}
+
+// 博客的待审核记录
+func blogReview(blogId int, blog *Blog, submitter *User) *Review {
+	return &Review{
+		Kind:     ReviewBlog,
+		TargetId: blogId,
+		BlogId:   blogId,
+		UserId:   submitter.UserId,
+		UserName: submitter.UserName,
+		Title:    blog.Title,
+		Excerpt:  blog.Content,
+		Reasons:  strings.Join(blog.HeldReasons, "\n"),
+	}
+}
+
+// 评论的待审核记录
+func commentReview(comment *Comment, reasons []string) *Review {
+	return &Review{
+		Kind:     ReviewComment,
+		TargetId: comment.CommentId,
+		BlogId:   comment.BlogID,
+		UserId:   comment.UserId,
+		UserName: comment.UserName,
+		Excerpt:  comment.Content,
+		Reasons:  strings.Join(reasons, "\n"),
+	}
+}
+
+// 审核队列，status 为空时返回待审核的记录，kind 为空时不限类型
+// 待审核的按提交时间正序，已处理的按处理时间倒序
+func GetReviews(status, kind string, offset, limit int) (reviews []Review, total int, err error) {
+	if status == "" {
+		status = ReviewPending
+	}
+	db := dao.DB.Model(&Review{}).Where("status = ?", status)
+	if kind != "" {
+		db = db.Where("kind = ?", kind)
+	}
+	if err = db.Count(&total).Error; err != nil {
+		return nil, 0, errors.New("read review error")
+	}
+	order := "reviewed_at DESC, review_id DESC"
+	if status == ReviewPending {
+		order = "created_at, review_id"
+	}
+	err = db.Order(order).Offset(offset).Limit(limit).Find(&reviews).Error
+	if err != nil {
+		return nil, 0, errors.New("read review error")
+	}
+	return reviews, total, nil
+}
+
+// 获取审核记录
+func GetReview(reviewId int) (review *Review, err error) {
+	review = new(Review)
+	err = dao.DB.Where("review_id = ?", reviewId).First(review).Error
+	if err != nil {
+		return nil, errors.New("review not found")
+	}
+	return review, nil
+}
+
+// 处理审核记录：通过时公开内容，拒绝时博客退回草稿、评论标记为已拒绝
+// 期间作者已经取消发布或删除的内容只关闭审核记录
+func ResolveReview(reviewId int, approve bool, reviewer *User, note string) (review *Review, err error) {
+	review, err = GetReview(reviewId)
+	if err != nil {
+		return nil, err
+	}
+	status := ReviewRejected
+	if approve {
+		status = ReviewApproved
+	}
+	tx := dao.DB.Begin()
+	res := tx.Model(&Review{}).Where("review_id = ? AND status = ?", reviewId, ReviewPending).Updates(map[string]interface{}{
+		"status":      status,
+		"reviewer_id": reviewer.UserId,
+		"note":        note,
+		"reviewed_at": time.Now(),
+	})
+	if res.Error != nil {
+		tx.Rollback()
+		return nil, errors.New("update review error")
+	}
+	if res.RowsAffected == 0 {
+		tx.Rollback()
+		return nil, errors.New("review already resolved")
+	}
+	var changed bool
+	switch review.Kind {
+	case ReviewBlog:
+		changed, err = resolveBlog(tx, review.TargetId, approve)
+	case ReviewComment:
+		commentStatus := CommentRejected
+		if approve {
+			commentStatus = CommentApproved
+		}
+		res = tx.Model(&Comment{}).Where("comment_id = ? AND status = ? AND removed_at IS NULL", review.TargetId, CommentPending).
+			UpdateColumn("status", commentStatus)
+		changed, err = res.RowsAffected == 1, res.Error
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.New("update review error")
+	}
+	if err = tx.Commit().Error; err != nil {
+		return nil, errors.New("update review error")
+	}
+	review.Status = status
+	if changed && approve {
+		reviewApproved(review)
+	}
+	return review, nil
+}
+
+// 修改待审核博客的状态
+// 通过时没有发布时间的立即发布，发布时间在将来的定时发布；拒绝时退回草稿
+func resolveBlog(tx *gorm.DB, blogId int, approve bool) (bool, error) {
+	var blog Blog
+	err := tx.Where("blog_id = ? AND status = ?", blogId, BlogPending).First(&blog).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	fields := map[string]interface{}{"status": BlogDraft, "publish_at": nil}
+	if approve {
+		now := time.Now()
+		fields = map[string]interface{}{"status": BlogPublished}
+		if blog.PublishAt == nil {
+			fields["publish_at"] = now
+		} else if blog.PublishAt.After(now) {
+			fields["status"] = BlogScheduled
+		}
+	}
+	res := tx.Model(&Blog{}).Where("blog_id = ? AND status = ?", blogId, BlogPending).Updates(fields)
+	return res.RowsAffected == 1, res.Error
+}
+
+// 审核通过后更新搜索索引、粉丝的时间线并发送评论通知
+func reviewApproved(review *Review) {
+	blog, err := GetABlog(review.BlogId)
+	if err != nil {
+		return
+	}
+	switch review.Kind {
+	case ReviewBlog:
+		indexBlog(blog)
+		if blog.IsPublic() {
+			blogPublished(blog)
+		}
+	case ReviewComment:
+		if comment, err := GetAComment(review.TargetId); err == nil {
+			NotifyNewComment(blog, comment)
+		}
+	}
+}
+
+// ErrUnderReview 内容在审核队列中等待处理，只能由管理员在审核队列中通过
+var ErrUnderReview = errors.New("under review")
+
+// 内容是否有待处理的审核记录
+func HasPendingReview(kind string, targetId int) (bool, error) {
+	var count int
+	err := dao.DB.Model(&Review{}).Where("kind = ? AND target_id = ? AND status = ?", kind, targetId, ReviewPending).Count(&count).Error
+	if err != nil {
+		return false, errors.New("read review error")
+	}
+	return count > 0, nil
+}
+
+// 博客作者直接拒绝评论时，审核队列中的记录一起关闭
+func rejectCommentReviews(commentId int) error {
+	return dao.DB.Model(&Review{}).Where("kind = ? AND target_id = ? AND status = ?", ReviewComment, commentId, ReviewPending).
+		Updates(map[string]interface{}{"status": ReviewRejected, "reviewed_at": time.Now()}).Error
+}
+
+// 内容需要人工审核时暂不公开博客，publishAt 为将来的时间时审核通过后定时发布
+func HoldBlog(blogId int, publishAt *time.Time, reasons []string, submitter *User) (err error) {
+	blog, err := GetABlog(blogId)
+	if err != nil {
+		return err
+	}
+	if publishAt != nil && !publishAt.After(time.Now()) {
+		publishAt = nil
+	}
+	blog.HeldReasons = reasons
+	tx := dao.DB.Begin()
+	err = tx.Model(&Blog{}).Where("blog_id = ?", blogId).Updates(map[string]interface{}{
+		"status":     BlogPending,
+		"publish_at": publishAt,
+	}).Error
+	if err != nil {
+		tx.Rollback()
+		return errors.New("update blog error")
+	}
+	if err = holdForReview(tx, blogReview(blogId, blog, submitter)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit().Error; err != nil {
+		return errors.New("update blog error")
+	}
+	blog.Status = BlogPending
+	indexBlog(blog)
+	return nil
+}
+
+// 截断到最多n个字符，截断时以省略号结尾
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
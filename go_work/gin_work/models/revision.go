@@ -0,0 +1,57 @@
+package models
+
+import (
+	"errors"
+	"gin_work/dao"
+	"time"
+)
+
+// ErrVersionConflict 博客已经被其他人修改
+var ErrVersionConflict = errors.New("version conflict")
+
+// BlogRevision 博客的历史版本，保存后不再修改
+type BlogRevision struct {
+	RevisionId int    `json:"revisionId" gorm:"PRIMARY_KEY;AUTO_INCREMENT"`
+	BlogId     int    `json:"blogId" gorm:"index"`
+	Version    int    `json:"version"`
+	Title      string `json:"title" gorm:"type:varchar(255)"`
+	Content    string `json:"content,omitempty" gorm:"type:text"`
+	AuthorId   int    `json:"authorId"`
+	AuthorName string `json:"authorName"`
+	// RestoredFrom 由哪个历史版本恢复而来，0表示普通修改
+	RestoredFrom int       `json:"restoredFrom,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// 获取博客的历史版本列表，不包含正文
+func GetRevisions(blogId int) (revisions []BlogRevision, err error) {
+	err = dao.DB.Select("revision_id, blog_id, version, title, author_id, author_name, restored_from, created_at").
+		Where("blog_id = ?", blogId).Order("version desc").Find(&revisions).Error
+	if err != nil {
+		return nil, errors.New("read revision error")
+	}
+	return revisions, nil
+}
+
+// 获取指定的历史版本
+func GetRevision(blogId, version int) (revision *BlogRevision, err error) {
+	revision = new(BlogRevision)
+	err = dao.DB.Where("blog_id = ? AND version = ?", blogId, version).First(revision).Error
+	if err != nil {
+		return nil, errors.New("revision not found")
+	}
+	return revision, nil
+}
+
+// 把历史版本恢复为一个新版本，expectedVersion 为编辑者看到的当前版本号
+func RestoreRevision(blogId, version, expectedVersion int, editor *User, heldReasons []string) (blog *Blog, err error) {
+	revision, err := GetRevision(blogId, version)
+	if err != nil {
+		return nil, err
+	}
+	blog = &Blog{Title: revision.Title, Content: revision.Content, Version: expectedVersion, HeldReasons: heldReasons}
+	if err = updateBlog(blogId, blog, editor, version); err != nil {
+		return nil, err
+	}
+	return GetABlog(blogId)
+}
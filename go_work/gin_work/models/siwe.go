@@ -0,0 +1,67 @@
+package models
+
+import (
+	"errors"
+	"gin_work/dao"
+	"strings"
+	"time"
+)
+
+// SiweNonce 钱包登录使用的一次性随机数
+type SiweNonce struct {
+	Nonce     string    `json:"nonce" gorm:"PRIMARY_KEY;type:varchar(32)"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+}
+
+// 保存随机数
+func CreateSiweNonce(nonce string, expiresAt time.Time) (err error) {
+	// 顺便清理已经过期的随机数
+	dao.DB.Where("expires_at < ?", time.Now()).Delete(&SiweNonce{})
+	err = dao.DB.Create(&SiweNonce{Nonce: nonce, ExpiresAt: expiresAt}).Error
+	if err != nil {
+		return errors.New("create nonce error")
+	}
+	return nil
+}
+
+// 使用随机数，每个随机数只能成功使用一次
+func ConsumeSiweNonce(nonce string) (err error) {
+	res := dao.DB.Where("nonce = ? AND expires_at >= ?", nonce, time.Now()).Delete(&SiweNonce{})
+	if res.Error != nil || res.RowsAffected != 1 {
+		return errors.New("invalid nonce")
+	}
+	return nil
+}
+
+// 通过钱包地址获取用户，不存在时自动创建
+// 新用户以地址作为用户名，没有密码，只能通过钱包登录
+func GetOrCreateWalletUser(address string) (user *User, err error) {
+	address = strings.ToLower(address)
+	user = new(User)
+	err = dao.DB.Where("address = ?", address).First(user).Error
+	if err == nil {
+		return user, nil
+	}
+	user = &User{UserName: address, Address: &address, Role: RoleAuthor}
+	err = dao.DB.Create(user).Error
+	if err != nil {
+		return nil, errors.New("create user error")
+	}
+	return user, nil
+}
+
+// 将钱包地址绑定到已有用户
+func LinkWalletAddress(user *User, address string) (err error) {
+	address = strings.ToLower(address)
+	var owner User
+	dao.DB.Where("address = ?", address).First(&owner)
+	if owner.UserId != 0 && owner.UserId != user.UserId {
+		return errors.New("address already linked")
+	}
+	err = dao.DB.Model(user).Update("address", address).Error
+	if err != nil {
+		return errors.New("link address error")
+	}
+	user.Address = &address
+	return nil
+}
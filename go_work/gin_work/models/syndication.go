@@ -0,0 +1,56 @@
+package models
+
+import (
+	"errors"
+	"gin_work/dao"
+	"time"
+)
+
+// 订阅源中的博客数量
+const FeedSize = 20
+
+// 最近发布的博客，authorId 为0时不限作者
+func FeedBlogs(authorId int) (blogList []Blog, err error) {
+	db := dao.DB.Where("status = ?", BlogPublished)
+	if authorId != 0 {
+		db = db.Where("user_id = ?", authorId)
+	}
+	err = db.Order("publish_at DESC, blog_id DESC").Limit(FeedSize).Find(&blogList).Error
+	if err != nil {
+		return nil, errors.New("read blog error")
+	}
+	return blogList, nil
+}
+
+// SitemapEntry 站点地图中的一篇博客
+type SitemapEntry struct {
+	BlogId    int
+	UpdatedAt time.Time
+}
+
+// 已发布博客的数量和最后修改时间，用于站点地图的分页和缓存校验
+func SitemapStats() (total int, lastMod time.Time, err error) {
+	var row struct {
+		Total   int
+		LastMod *time.Time
+	}
+	err = dao.DB.Model(&Blog{}).Select("COUNT(*) AS total, MAX(updated_at) AS last_mod").
+		Where("status = ?", BlogPublished).Scan(&row).Error
+	if err != nil {
+		return 0, time.Time{}, errors.New("read blog error")
+	}
+	if row.LastMod != nil {
+		lastMod = *row.LastMod
+	}
+	return row.Total, lastMod, nil
+}
+
+// 按博客ID顺序分页读取站点地图
+func SitemapBlogs(offset, limit int) (entries []SitemapEntry, err error) {
+	err = dao.DB.Model(&Blog{}).Select("blog_id, updated_at").Where("status = ?", BlogPublished).
+		Order("blog_id").Offset(offset).Limit(limit).Scan(&entries).Error
+	if err != nil {
+		return nil, errors.New("read blog error")
+	}
+	return entries, nil
+}
@@ -0,0 +1,185 @@
+package models
+
+import (
+	"errors"
+	"gin_work/dao"
+	"github.com/jinzhu/gorm"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 标签限制
+const (
+	MaxTagsPerBlog = 10
+	MaxTagLength   = 32
+)
+
+// Tag 标签，和博客是多对多关系
+type Tag struct {
+	TagId     int       `json:"tagId" gorm:"PRIMARY_KEY;AUTO_INCREMENT"`
+	Name      string    `json:"name" gorm:"type:varchar(32);UNIQUE;not null"`
+	CreatedAt time.Time `json:"-"`
+}
+
+// BlogTag 博客和标签的关联表
+type BlogTag struct {
+	BlogId int `gorm:"PRIMARY_KEY;AUTO_INCREMENT:false"`
+	TagId  int `gorm:"PRIMARY_KEY;AUTO_INCREMENT:false;index"`
+}
+
+// TagCount 标签云中的一项
+type TagCount struct {
+	TagId int    `json:"tagId"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// 整理标签名：去掉首尾空白，逗号分隔的也拆开，忽略大小写去重
+func NormalizeTagNames(names []string) ([]string, error) {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, item := range names {
+		for _, name := range strings.Split(item, ",") {
+			name = strings.Join(strings.Fields(name), " ")
+			if name == "" {
+				continue
+			}
+			if utf8.RuneCountInString(name) > MaxTagLength {
+				return nil, errors.New("tag too long")
+			}
+			key := strings.ToLower(name)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			out = append(out, name)
+		}
+	}
+	if len(out) > MaxTagsPerBlog {
+		return nil, errors.New("too many tags")
+	}
+	return out, nil
+}
+
+// 用提交的标签替换博客原有的标签，不存在的标签自动创建
+func replaceBlogTags(tx *gorm.DB, blogId int, names []string) error {
+	names, err := NormalizeTagNames(names)
+	if err != nil {
+		return err
+	}
+	if err = tx.Where("blog_id = ?", blogId).Delete(&BlogTag{}).Error; err != nil {
+		return errors.New("save tag error")
+	}
+	for _, name := range names {
+		var tag Tag
+		if err = tx.Where(Tag{Name: name}).FirstOrCreate(&tag).Error; err != nil {
+			return errors.New("save tag error")
+		}
+		if err = tx.Create(&BlogTag{BlogId: blogId, TagId: tag.TagId}).Error; err != nil {
+			return errors.New("save tag error")
+		}
+	}
+	return nil
+}
+
+// 为博客列表加载标签
+func loadBlogTags(blogs []Blog) error {
+	if len(blogs) == 0 {
+		return nil
+	}
+	ids := make([]int, len(blogs))
+	for i := range blogs {
+		ids[i] = blogs[i].BlogId
+		blogs[i].Tags = []Tag{}
+	}
+	var rows []struct {
+		BlogId int
+		TagId  int
+		Name   string
+	}
+	err := dao.DB.Table("blog_tags").Select("blog_tags.blog_id, tags.tag_id, tags.name").
+		Joins("JOIN tags ON tags.tag_id = blog_tags.tag_id").
+		Where("blog_tags.blog_id IN (?)", ids).Order("tags.name").Scan(&rows).Error
+	if err != nil {
+		return errors.New("read tag error")
+	}
+	index := make(map[int]int, len(blogs))
+	for i := range blogs {
+		index[blogs[i].BlogId] = i
+	}
+	for _, row := range rows {
+		if i, ok := index[row.BlogId]; ok {
+			blogs[i].Tags = append(blogs[i].Tags, Tag{TagId: row.TagId, Name: row.Name})
+		}
+	}
+	return nil
+}
+
+// 标签云，统计每个标签下已发布博客的数量
+func TagCloud(limit int) (tags []TagCount, err error) {
+	err = dao.DB.Table("tags").Select("tags.tag_id, tags.name, COUNT(*) AS count").
+		Joins("JOIN blog_tags ON blog_tags.tag_id = tags.tag_id").
+		Joins("JOIN blogs ON blogs.blog_id = blog_tags.blog_id").
+		Where("blogs.status = ? AND blogs.deleted_at IS NULL", BlogPublished).
+		Group("tags.tag_id, tags.name").Order("count DESC, tags.name").Limit(limit).Scan(&tags).Error
+	if err != nil {
+		return nil, errors.New("read tag error")
+	}
+	return tags, nil
+}
+
+// 重命名标签，新名字已经存在时应当使用合并
+func RenameTag(tagId int, name string) (tag *Tag, err error) {
+	names, err := NormalizeTagNames([]string{name})
+	if err != nil {
+		return nil, err
+	}
+	if len(names) != 1 {
+		return nil, errors.New("invalid tag name")
+	}
+	tag = new(Tag)
+	if err = dao.DB.Where("tag_id = ?", tagId).First(tag).Error; err != nil {
+		return nil, errors.New("tag not found")
+	}
+	var count int
+	dao.DB.Model(&Tag{}).Where("name = ? AND tag_id <> ?", names[0], tagId).Count(&count)
+	if count > 0 {
+		return nil, errors.New("tag already exists, merge instead")
+	}
+	if err = dao.DB.Model(tag).Update("name", names[0]).Error; err != nil {
+		return nil, errors.New("rename tag error")
+	}
+	return tag, nil
+}
+
+// 把 fromId 标签合并到 intoId 标签，原标签会被删除
+func MergeTags(fromId, intoId int) (err error) {
+	if fromId == intoId {
+		return errors.New("cannot merge a tag into itself")
+	}
+	var count int
+	dao.DB.Model(&Tag{}).Where("tag_id IN (?)", []int{fromId, intoId}).Count(&count)
+	if count != 2 {
+		return errors.New("tag not found")
+	}
+	tx := dao.DB.Begin()
+	steps := []func() error{
+		// 已经同时拥有两个标签的博客忽略重复
+		func() error {
+			return tx.Exec("INSERT IGNORE INTO blog_tags (blog_id, tag_id) SELECT blog_id, ? FROM blog_tags WHERE tag_id = ?", intoId, fromId).Error
+		},
+		func() error { return tx.Where("tag_id = ?", fromId).Delete(&BlogTag{}).Error },
+		func() error { return tx.Where("tag_id = ?", fromId).Delete(&Tag{}).Error },
+	}
+	for _, step := range steps {
+		if err = step(); err != nil {
+			tx.Rollback()
+			return errors.New("merge tag error")
+		}
+	}
+	if err = tx.Commit().Error; err != nil {
+		return errors.New("merge tag error")
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"gin_work/dao"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+// 使用内存中的SQLite代替MySQL，只建立测试需要的表，测试结束后恢复 dao.DB
+func setupTestDB(t *testing.T, models ...interface{}) {
+	t.Helper()
+	db, err := gorm.Open("sqlite3", "file:"+t.Name()+"?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 内存数据库只能在同一个连接中访问
+	db.DB().SetMaxOpenConns(1)
+	if err = db.AutoMigrate(models...).Error; err != nil {
+		t.Fatal(err)
+	}
+	old := dao.DB
+	dao.DB = db
+	t.Cleanup(func() {
+		dao.DB = old
+		_ = db.Close()
+	})
+}
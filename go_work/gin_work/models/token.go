@@ -0,0 +1,127 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"gin_work/dao"
+	"time"
+)
+
+// RefreshToken 刷新令牌，数据库中只保存令牌的哈希
+type RefreshToken struct {
+	TokenId   int        `json:"tokenId" gorm:"PRIMARY_KEY;AUTO_INCREMENT"`
+	UserName  string     `json:"userName" gorm:"index"`
+	TokenHash string     `json:"-" gorm:"type:char(64);UNIQUE"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// RevokedToken 已吊销的访问令牌，以jti为键，过期后即可清理
+type RevokedToken struct {
+	Jti       string    `json:"jti" gorm:"PRIMARY_KEY;type:varchar(64)"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+}
+
+// 保存刷新令牌
+func CreateRefreshToken(userName, token string, expiresAt time.Time) (err error) {
+	err = dao.DB.Create(&RefreshToken{
+		UserName:  userName,
+		TokenHash: hashToken(token),
+		ExpiresAt: expiresAt,
+	}).Error
+	if err != nil {
+		return errors.New("create refresh token error")
+	}
+	return nil
+}
+
+// 轮换刷新令牌：旧令牌作废并保存新令牌
+// 如果旧令牌已经被使用过，视为令牌泄露，吊销该用户的所有刷新令牌
+func RotateRefreshToken(oldToken, newToken string, expiresAt time.Time) (userName string, err error) {
+	var rt RefreshToken
+	err = dao.DB.Where("token_hash = ?", hashToken(oldToken)).First(&rt).Error
+	if err != nil {
+		return "", errors.New("invalid refresh token")
+	}
+	if rt.RevokedAt != nil {
+		_ = RevokeUserTokens(rt.UserName)
+		return "", errors.New("refresh token reused")
+	}
+	if time.Now().After(rt.ExpiresAt) {
+		return "", errors.New("refresh token expired")
+	}
+
+	tx := dao.DB.Begin()
+	// 只有未被吊销的令牌才能被更新，防止并发请求重复使用同一个令牌
+	res := tx.Model(&RefreshToken{}).Where("token_id = ? AND revoked_at IS NULL", rt.TokenId).
+		Update("revoked_at", time.Now())
+	if res.Error != nil || res.RowsAffected != 1 {
+		tx.Rollback()
+		return "", errors.New("invalid refresh token")
+	}
+	err = tx.Create(&RefreshToken{
+		UserName:  rt.UserName,
+		TokenHash: hashToken(newToken),
+		ExpiresAt: expiresAt,
+	}).Error
+	if err != nil {
+		tx.Rollback()
+		return "", errors.New("create refresh token error")
+	}
+	if err = tx.Commit().Error; err != nil {
+		return "", errors.New("rotate refresh token error")
+	}
+	return rt.UserName, nil
+}
+
+// 吊销单个刷新令牌
+func RevokeRefreshToken(token string) (err error) {
+	err = dao.DB.Model(&RefreshToken{}).
+		Where("token_hash = ? AND revoked_at IS NULL", hashToken(token)).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return errors.New("revoke refresh token error")
+	}
+	return nil
+}
+
+// 吊销用户的所有刷新令牌，用于修改密码或检测到令牌泄露
+func RevokeUserTokens(userName string) (err error) {
+	err = dao.DB.Model(&RefreshToken{}).
+		Where("user_name = ? AND revoked_at IS NULL", userName).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return errors.New("revoke user tokens error")
+	}
+	return nil
+}
+
+// 吊销访问令牌，令牌过期前中间件都会拒绝它
+func RevokeAccessToken(jti string, expiresAt time.Time) (err error) {
+	// 顺便清理已经过期的记录
+	dao.DB.Where("expires_at < ?", time.Now()).Delete(&RevokedToken{})
+	err = dao.DB.Create(&RevokedToken{Jti: jti, ExpiresAt: expiresAt}).Error
+	if err != nil {
+		return errors.New("revoke access token error")
+	}
+	return nil
+}
+
+// 判断访问令牌是否已被吊销
+func IsTokenRevoked(jti string) bool {
+	var count int
+	err := dao.DB.Model(&RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	if err != nil {
+		// 查询失败时按已吊销处理
+		return true
+	}
+	return count > 0
+}
+
+// 计算令牌的哈希
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
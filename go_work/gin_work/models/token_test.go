@@ -0,0 +1,109 @@
+package models
+
+import (
+	"gin_work/dao"
+	"testing"
+	"time"
+)
+
+func TestRotateRefreshToken(t *testing.T) {
+	setupTestDB(t, &RefreshToken{})
+	expires := time.Now().Add(time.Hour)
+	if err := CreateRefreshToken("alice", "r1", expires); err != nil {
+		t.Fatal(err)
+	}
+
+	userName, err := RotateRefreshToken("r1", "r2", expires)
+	if err != nil || userName != "alice" {
+		t.Fatalf("rotate r1 = (%q, %v)", userName, err)
+	}
+	if userName, err = RotateRefreshToken("r2", "r3", expires); err != nil || userName != "alice" {
+		t.Fatalf("rotate r2 = (%q, %v)", userName, err)
+	}
+	// 只保存哈希
+	var stored RefreshToken
+	if err = dao.DB.Where("token_hash = ?", hashToken("r3")).First(&stored).Error; err != nil || stored.RevokedAt != nil {
+		t.Fatalf("r3 not stored as active: %v", err)
+	}
+	if dao.DB.Where("token_hash = ?", "r3").First(&RefreshToken{}).Error == nil {
+		t.Fatal("raw token stored")
+	}
+
+	if _, err = RotateRefreshToken("unknown", "x", expires); err == nil {
+		t.Fatal("unknown token rotated")
+	}
+}
+
+// 已轮换的令牌再次使用时吊销该用户的全部刷新令牌，其他用户不受影响
+func TestRotateRefreshTokenReuse(t *testing.T) {
+	setupTestDB(t, &RefreshToken{})
+	expires := time.Now().Add(time.Hour)
+	for _, tk := range []struct{ user, token string }{{"alice", "a1"}, {"alice", "other-device"}, {"bob", "b1"}} {
+		if err := CreateRefreshToken(tk.user, tk.token, expires); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := RotateRefreshToken("a1", "a2", expires); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := RotateRefreshToken("a1", "stolen", expires); err == nil || err.Error() != "refresh token reused" {
+		t.Fatalf("reuse err = %v", err)
+	}
+	for _, token := range []string{"a2", "other-device"} {
+		if _, err := RotateRefreshToken(token, token+"-next", expires); err == nil {
+			t.Fatalf("%s still valid after reuse", token)
+		}
+	}
+	var active int
+	dao.DB.Model(&RefreshToken{}).Where("user_name = ? AND revoked_at IS NULL", "alice").Count(&active)
+	if active != 0 {
+		t.Fatalf("alice has %d active tokens", active)
+	}
+	if _, err := RotateRefreshToken("b1", "b2", expires); err != nil {
+		t.Fatalf("bob's token revoked: %v", err)
+	}
+}
+
+func TestRotateRefreshTokenExpiredOrRevoked(t *testing.T) {
+	setupTestDB(t, &RefreshToken{})
+	if err := CreateRefreshToken("alice", "old", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RotateRefreshToken("old", "new", time.Now().Add(time.Hour)); err == nil || err.Error() != "refresh token expired" {
+		t.Fatalf("expired err = %v", err)
+	}
+
+	if err := CreateRefreshToken("alice", "logout", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := RevokeRefreshToken("logout"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RotateRefreshToken("logout", "new", time.Now().Add(time.Hour)); err == nil {
+		t.Fatal("revoked token rotated")
+	}
+}
+
+func TestRevokeAccessToken(t *testing.T) {
+	setupTestDB(t, &RevokedToken{})
+	if IsTokenRevoked("jti-1") {
+		t.Fatal("jti-1 revoked before logout")
+	}
+	if err := RevokeAccessToken("jti-1", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if !IsTokenRevoked("jti-1") || IsTokenRevoked("jti-2") {
+		t.Fatal("unexpected revocation state")
+	}
+	// 吊销新令牌时清理已过期的记录
+	if err := RevokeAccessToken("expired", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := RevokeAccessToken("jti-2", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if IsTokenRevoked("expired") {
+		t.Fatal("expired record not cleaned up")
+	}
+}
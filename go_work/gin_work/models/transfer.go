@@ -0,0 +1,565 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gin_work/dao"
+	"github.com/jinzhu/gorm"
+	"strings"
+	"time"
+)
+
+// 导出记录的类型
+const (
+	RecordMeta    = "meta"
+	RecordUser    = "user"
+	RecordBlog    = "blog"
+	RecordComment = "comment"
+)
+
+// 导出格式版本
+const ExportVersion = 1
+
+// 每批读取的记录数
+const exportBatch = 500
+
+// 导入报告中最多保留的错误数
+const maxImportErrors = 1000
+
+// ExportMeta 导出文件的头部，Origin 用于导入时区分数据来源
+type ExportMeta struct {
+	Origin     string    `json:"origin" yaml:"origin"`
+	Version    int       `json:"version" yaml:"version"`
+	ExportedAt time.Time `json:"exportedAt" yaml:"exportedAt"`
+}
+
+// ExportUser 导出的用户，不包含密码哈希和两步验证密钥
+type ExportUser struct {
+	Id            int       `json:"id" yaml:"id"`
+	UserName      string    `json:"userName" yaml:"userName"`
+	Email         string    `json:"email" yaml:"email"`
+	Role          string    `json:"role" yaml:"role"`
+	EmailVerified bool      `json:"emailVerified" yaml:"emailVerified"`
+	DisplayName   string    `json:"displayName" yaml:"displayName"`
+	AvatarURL     string    `json:"avatarUrl" yaml:"avatarUrl"`
+	CreatedAt     time.Time `json:"createdAt" yaml:"createdAt"`
+	// Bio 在Markdown格式中作为正文
+	Bio string `json:"bio" yaml:"-"`
+}
+
+// ExportBlog 导出的博客，作者和分类按名称导出
+type ExportBlog struct {
+	Id        int        `json:"id" yaml:"id"`
+	Author    string     `json:"author" yaml:"author"`
+	Title     string     `json:"title" yaml:"title"`
+	Status    string     `json:"status" yaml:"status"`
+	PublishAt *time.Time `json:"publishAt,omitempty" yaml:"publishAt,omitempty"`
+	// Category 从根分类到所属分类的名称
+	Category  []string  `json:"category,omitempty" yaml:"category,omitempty"`
+	Tags      []string  `json:"tags,omitempty" yaml:"tags,omitempty"`
+	CreatedAt time.Time `json:"createdAt" yaml:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt" yaml:"updatedAt"`
+	Content   string    `json:"content" yaml:"-"`
+}
+
+// ExportComment 导出的评论，Author 为空表示评论者已注销
+type ExportComment struct {
+	Id        int        `json:"id" yaml:"id"`
+	BlogId    int        `json:"blogId" yaml:"blogId"`
+	ParentId  int        `json:"parentId,omitempty" yaml:"parentId,omitempty"`
+	Author    string     `json:"author" yaml:"author"`
+	Status    string     `json:"status" yaml:"status"`
+	CreatedAt time.Time  `json:"createdAt" yaml:"createdAt"`
+	EditedAt  *time.Time `json:"editedAt,omitempty" yaml:"editedAt,omitempty"`
+	RemovedAt *time.Time `json:"removedAt,omitempty" yaml:"removedAt,omitempty"`
+	Content   string     `json:"content" yaml:"-"`
+}
+
+// ImportedRecord 导入的记录与本地记录的对应关系，重复导入时跳过已导入的记录
+type ImportedRecord struct {
+	Id       int    `gorm:"PRIMARY_KEY;AUTO_INCREMENT"`
+	Origin   string `gorm:"type:varchar(191);unique_index:idx_imported_records_source"`
+	Kind     string `gorm:"type:varchar(16);unique_index:idx_imported_records_source"`
+	SourceId int    `gorm:"unique_index:idx_imported_records_source"`
+	LocalId  int
+}
+
+// 依次导出全部用户、博客和评论，博客在评论之前，父评论在回复之前
+func Export(origin string, visit func(kind string, record interface{}) error) error {
+	err := visit(RecordMeta, &ExportMeta{Origin: origin, Version: ExportVersion, ExportedAt: time.Now()})
+	if err != nil {
+		return err
+	}
+	if err = exportUsers(visit); err != nil {
+		return err
+	}
+	if err = exportBlogs(visit); err != nil {
+		return err
+	}
+	return exportComments(visit)
+}
+
+func exportUsers(visit func(string, interface{}) error) error {
+	for lastId := 0; ; {
+		var users []User
+		err := dao.DB.Where("user_id > ?", lastId).Order("user_id").Limit(exportBatch).Find(&users).Error
+		if err != nil {
+			return errors.New("read user error")
+		}
+		for _, user := range users {
+			err = visit(RecordUser, &ExportUser{
+				Id:            user.UserId,
+				UserName:      user.UserName,
+				Email:         user.EmailAddress(),
+				Role:          user.Role,
+				EmailVerified: user.EmailVerified,
+				DisplayName:   user.DisplayName,
+				AvatarURL:     user.AvatarURL,
+				CreatedAt:     user.CreatedAt,
+				Bio:           user.Bio,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if len(users) < exportBatch {
+			return nil
+		}
+		lastId = users[len(users)-1].UserId
+	}
+}
+
+func exportBlogs(visit func(string, interface{}) error) error {
+	categories, err := allCategories()
+	if err != nil {
+		return err
+	}
+	byId := make(map[int]Category, len(categories))
+	for _, category := range categories {
+		byId[category.CategoryId] = category
+	}
+	for lastId := 0; ; {
+		var blogs []Blog
+		err = dao.DB.Where("blog_id > ?", lastId).Order("blog_id").Limit(exportBatch).Find(&blogs).Error
+		if err != nil {
+			return errors.New("read blog error")
+		}
+		if err = loadBlogTags(blogs); err != nil {
+			return err
+		}
+		for _, blog := range blogs {
+			record := &ExportBlog{
+				Id:        blog.BlogId,
+				Author:    blog.UserName,
+				Title:     blog.Title,
+				Status:    blog.Status,
+				PublishAt: blog.PublishAt,
+				CreatedAt: blog.CreatedAt,
+				UpdatedAt: blog.UpdatedAt,
+				Content:   blog.Content,
+			}
+			if blog.CategoryId != nil {
+				record.Category = categoryPath(byId, *blog.CategoryId)
+			}
+			for _, tag := range blog.Tags {
+				record.Tags = append(record.Tags, tag.Name)
+			}
+			if err = visit(RecordBlog, record); err != nil {
+				return err
+			}
+		}
+		if len(blogs) < exportBatch {
+			return nil
+		}
+		lastId = blogs[len(blogs)-1].BlogId
+	}
+}
+
+func exportComments(visit func(string, interface{}) error) error {
+	for lastId := 0; ; {
+		var comments []Comment
+		// 回收站中博客的评论不导出
+		err := dao.DB.Where("comment_id > ? AND blog_id IN (SELECT blog_id FROM blogs WHERE deleted_at IS NULL)", lastId).
+			Order("comment_id").Limit(exportBatch).Find(&comments).Error
+		if err != nil {
+			return errors.New("read comment error")
+		}
+		for _, comment := range comments {
+			record := &ExportComment{
+				Id:        comment.CommentId,
+				BlogId:    comment.BlogID,
+				ParentId:  comment.ParentId,
+				Status:    comment.Status,
+				CreatedAt: comment.CreatedAt,
+				EditedAt:  comment.EditedAt,
+				RemovedAt: comment.RemovedAt,
+				Content:   comment.Content,
+			}
+			// 已注销用户的评论不再关联用户
+			if comment.UserId != 0 {
+				record.Author = comment.UserName
+			}
+			// 已删除评论的内容不导出
+			if comment.IsRemoved() {
+				record.Content = ""
+			}
+			if err = visit(RecordComment, record); err != nil {
+				return err
+			}
+		}
+		if len(comments) < exportBatch {
+			return nil
+		}
+		lastId = comments[len(comments)-1].CommentId
+	}
+}
+
+// 从根分类到指定分类的名称
+func categoryPath(byId map[int]Category, categoryId int) []string {
+	var path []string
+	// 限制层数，防止数据异常时出现环
+	for id := categoryId; id != 0 && len(path) < len(byId); {
+		category, ok := byId[id]
+		if !ok {
+			break
+		}
+		path = append([]string{category.Name}, path...)
+		id = category.ParentId
+	}
+	return path
+}
+
+// ImportError 导入失败的一条记录
+type ImportError struct {
+	// Ref 记录的位置，JSON Lines 为行号，Markdown 为文件名
+	Ref   string `json:"ref"`
+	Type  string `json:"type,omitempty"`
+	Id    int    `json:"id,omitempty"`
+	Error string `json:"error"`
+}
+
+// ImportReport 导入结果
+type ImportReport struct {
+	Created int           `json:"created"`
+	Skipped int           `json:"skipped"`
+	Failed  int           `json:"failed"`
+	Errors  []ImportError `json:"errors"`
+}
+
+// 记录一条导入失败，超过上限后只计数
+func (r *ImportReport) Fail(ref, kind string, id int, err error) {
+	r.Failed++
+	if len(r.Errors) < maxImportErrors {
+		r.Errors = append(r.Errors, ImportError{Ref: ref, Type: kind, Id: id, Error: err.Error()})
+	}
+}
+
+// Importer 导入导出的数据
+// 用户按用户名对应，已存在的用户直接使用；博客和评论按来源和原ID记录在 imported_records 中，
+// 重复导入同一份数据时跳过已导入的记录，导入后在本地删除的记录也不会重新导入
+type Importer struct {
+	Report ImportReport
+	// TrustAccounts 为true时保留导入用户的角色和邮箱验证状态，否则一律导入为未验证邮箱的作者
+	TrustAccounts bool
+	origin        string
+	// 用户名到用户ID的缓存
+	users map[string]int
+}
+
+func NewImporter(trustAccounts bool) *Importer {
+	return &Importer{Report: ImportReport{Errors: []ImportError{}}, TrustAccounts: trustAccounts, users: map[string]int{}}
+}
+
+// 导入一条记录，失败时记录到报告中，不影响后续记录
+func (im *Importer) Import(ref string, record interface{}) {
+	var (
+		kind    string
+		id      int
+		created bool
+		err     error
+	)
+	switch r := record.(type) {
+	case *ExportMeta:
+		im.origin = r.Origin
+		return
+	case *ExportUser:
+		kind, id = RecordUser, r.Id
+		created, err = im.importUser(r)
+	case *ExportBlog:
+		kind, id = RecordBlog, r.Id
+		created, err = im.importBlog(r)
+	case *ExportComment:
+		kind, id = RecordComment, r.Id
+		created, err = im.importComment(r)
+	default:
+		err = errors.New("unknown record type")
+	}
+	switch {
+	case err != nil:
+		im.Report.Fail(ref, kind, id, err)
+	case created:
+		im.Report.Created++
+	default:
+		im.Report.Skipped++
+	}
+}
+
+// 用户名已存在时跳过；新用户使用随机密码，需要通过找回密码设置
+func (im *Importer) importUser(r *ExportUser) (bool, error) {
+	if r.UserName == "" {
+		return false, errors.New("userName is required")
+	}
+	if _, err := im.userId(r.UserName); err == nil {
+		return false, nil
+	}
+	// 钱包用户只能由钱包登录创建，导入时没有绑定地址
+	if ReservedUserName(r.UserName) {
+		return false, ErrReservedName
+	}
+	// 导入文件可能被篡改，默认不信任其中的管理员角色和邮箱验证状态
+	role, verified := RoleAuthor, false
+	if im.TrustAccounts {
+		if ValidRole(r.Role) {
+			role = r.Role
+		}
+		verified = r.EmailVerified
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return false, err
+	}
+	password, err := hashPassword(hex.EncodeToString(b))
+	if err != nil {
+		return false, errors.New("hash password error")
+	}
+	email := normalizeEmail(&r.Email)
+	if email != nil {
+		taken, err := EmailTaken(*email, 0)
+		if err != nil {
+			return false, err
+		}
+		if taken {
+			return false, ErrEmailTaken
+		}
+	}
+	user := &User{
+		UserName:      r.UserName,
+		Password:      password,
+		Email:         email,
+		Role:          role,
+		EmailVerified: verified,
+		DisplayName:   r.DisplayName,
+		Bio:           r.Bio,
+		AvatarURL:     r.AvatarURL,
+		CreatedAt:     r.CreatedAt,
+	}
+	if err = dao.DB.Create(user).Error; err != nil {
+		return false, errors.New("create user error")
+	}
+	im.users[user.UserName] = user.UserId
+	return true, nil
+}
+
+func (im *Importer) importBlog(r *ExportBlog) (bool, error) {
+	if _, ok, err := im.localId(RecordBlog, r.Id); err != nil || ok {
+		return false, err
+	}
+	switch r.Status {
+	case BlogDraft, BlogScheduled, BlogPublished, BlogArchived:
+	case BlogPending:
+		// 审核记录不导出，待审核的博客导入为草稿
+		r.Status, r.PublishAt = BlogDraft, nil
+	default:
+		return false, errors.New("invalid status")
+	}
+	if r.Status == BlogScheduled && r.PublishAt == nil {
+		return false, errors.New("publishAt is required for scheduled blog")
+	}
+	userId, err := im.userId(r.Author)
+	if err != nil {
+		return false, fmt.Errorf("author %q not found", r.Author)
+	}
+	blog := &Blog{
+		Title:     r.Title,
+		Content:   r.Content,
+		UserId:    userId,
+		UserName:  r.Author,
+		Status:    r.Status,
+		PublishAt: r.PublishAt,
+		Version:   1,
+		CreatedAt: r.CreatedAt,
+		UpdatedAt: r.UpdatedAt,
+		TagNames:  r.Tags,
+	}
+	if blog.TagNames == nil {
+		blog.TagNames = []string{}
+	}
+
+	tx := dao.DB.Begin()
+	if len(r.Category) > 0 {
+		categoryId, err := ensureCategoryPath(tx, r.Category)
+		if err != nil {
+			tx.Rollback()
+			return false, err
+		}
+		blog.CategoryId = &categoryId
+	}
+	if err = tx.Create(blog).Error; err != nil {
+		tx.Rollback()
+		return false, errors.New("create blog error")
+	}
+	err = tx.Create(&BlogRevision{
+		BlogId:     blog.BlogId,
+		Version:    blog.Version,
+		Title:      blog.Title,
+		Content:    blog.Content,
+		AuthorId:   blog.UserId,
+		AuthorName: blog.UserName,
+	}).Error
+	if err != nil {
+		tx.Rollback()
+		return false, errors.New("create blog error")
+	}
+	if err = replaceBlogTags(tx, blog.BlogId, blog.TagNames); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if err = linkBlogAttachments(tx, blog.BlogId, blog.Content); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if err = im.saveLocalId(tx, RecordBlog, r.Id, blog.BlogId); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if err = tx.Commit().Error; err != nil {
+		return false, errors.New("create blog error")
+	}
+	// 导入的是历史博客，不推送到粉丝的时间线
+	indexBlog(blog)
+	return true, nil
+}
+
+func (im *Importer) importComment(r *ExportComment) (bool, error) {
+	if _, ok, err := im.localId(RecordComment, r.Id); err != nil || ok {
+		return false, err
+	}
+	switch r.Status {
+	case CommentPending, CommentApproved, CommentRejected:
+	default:
+		return false, errors.New("invalid status")
+	}
+	blogId, ok, err := im.localId(RecordBlog, r.BlogId)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, fmt.Errorf("blog %d not imported", r.BlogId)
+	}
+	// 导入后在本地删除的博客不再导入评论
+	var count int
+	if err = dao.DB.Model(&Blog{}).Where("blog_id = ?", blogId).Count(&count).Error; err != nil {
+		return false, errors.New("read blog error")
+	}
+	if count == 0 {
+		return false, fmt.Errorf("blog %d was deleted", r.BlogId)
+	}
+	comment := &Comment{
+		BlogID:    blogId,
+		UserName:  DeletedUserName,
+		Content:   r.Content,
+		Status:    r.Status,
+		CreatedAt: r.CreatedAt,
+		EditedAt:  r.EditedAt,
+		RemovedAt: r.RemovedAt,
+	}
+	if r.ParentId != 0 {
+		parentId, ok, err := im.localId(RecordComment, r.ParentId)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			return false, fmt.Errorf("parent comment %d not imported", r.ParentId)
+		}
+		comment.ParentId = parentId
+	}
+	if r.Author != "" {
+		if comment.UserId, err = im.userId(r.Author); err != nil {
+			return false, fmt.Errorf("author %q not found", r.Author)
+		}
+		comment.UserName = r.Author
+	}
+
+	tx := dao.DB.Begin()
+	if err = tx.Create(comment).Error; err != nil {
+		tx.Rollback()
+		return false, errors.New("create comment error")
+	}
+	if err = im.saveLocalId(tx, RecordComment, r.Id, comment.CommentId); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if err = tx.Commit().Error; err != nil {
+		return false, errors.New("create comment error")
+	}
+	return true, nil
+}
+
+// 根据用户名查找用户ID
+func (im *Importer) userId(userName string) (int, error) {
+	if id, ok := im.users[userName]; ok {
+		return id, nil
+	}
+	user, err := GetUserByName(userName)
+	if err != nil {
+		return 0, err
+	}
+	im.users[userName] = user.UserId
+	return user.UserId, nil
+}
+
+// 查找已导入记录在本地的ID
+func (im *Importer) localId(kind string, sourceId int) (int, bool, error) {
+	var record ImportedRecord
+	err := dao.DB.Where("origin = ? AND kind = ? AND source_id = ?", im.origin, kind, sourceId).First(&record).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, errors.New("read imported record error")
+	}
+	return record.LocalId, true, nil
+}
+
+// 与导入的记录在同一事务中保存对应关系，并发导入同一条记录时唯一索引保证只有一次成功
+func (im *Importer) saveLocalId(tx *gorm.DB, kind string, sourceId, localId int) error {
+	err := tx.Create(&ImportedRecord{Origin: im.origin, Kind: kind, SourceId: sourceId, LocalId: localId}).Error
+	if err != nil {
+		return errors.New("save imported record error")
+	}
+	return nil
+}
+
+// 按名称逐级查找分类，不存在时创建
+func ensureCategoryPath(tx *gorm.DB, path []string) (int, error) {
+	parentId := 0
+	for _, name := range path {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return 0, errors.New("invalid category name")
+		}
+		var category Category
+		err := tx.Where("name = ? AND parent_id = ?", name, parentId).First(&category).Error
+		if gorm.IsRecordNotFoundError(err) {
+			category = Category{Name: name, ParentId: parentId}
+			err = tx.Create(&category).Error
+		}
+		if err != nil {
+			return 0, errors.New("save category error")
+		}
+		parentId = category.CategoryId
+	}
+	return parentId, nil
+}
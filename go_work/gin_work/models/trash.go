@@ -0,0 +1,125 @@
+package models
+
+import (
+	"errors"
+	"gin_work/dao"
+	"gin_work/search"
+	"log"
+	"time"
+)
+
+// 每次彻底删除的博客数量
+const purgeBatch = 100
+
+// 用户回收站中的博客，按删除时间倒序
+func GetTrash(userId, offset, limit int) (blogList []Blog, total int, err error) {
+	db := dao.DB.Unscoped().Model(&Blog{}).Where("user_id = ? AND deleted_at IS NOT NULL", userId)
+	if err = db.Count(&total).Error; err != nil {
+		return nil, 0, errors.New("read trash error")
+	}
+	err = db.Order("deleted_at DESC, blog_id DESC").Offset(offset).Limit(limit).Find(&blogList).Error
+	if err != nil {
+		return nil, 0, errors.New("read trash error")
+	}
+	return blogList, total, nil
+}
+
+// 获取回收站中的博客
+func GetTrashedBlog(blogId int) (blog *Blog, err error) {
+	blog = new(Blog)
+	err = dao.DB.Unscoped().Where("blog_id = ? AND deleted_at IS NOT NULL", blogId).First(blog).Error
+	if err != nil {
+		return nil, errors.New("blog not in trash")
+	}
+	return blog, nil
+}
+
+// 从回收站恢复博客
+func RestoreBlog(blogId int) (err error) {
+	res := dao.DB.Unscoped().Model(&Blog{}).Where("blog_id = ? AND deleted_at IS NOT NULL", blogId).
+		UpdateColumn("deleted_at", nil)
+	if res.Error != nil {
+		return errors.New("restore blog error")
+	}
+	if res.RowsAffected == 0 {
+		return errors.New("blog not in trash")
+	}
+	// 删除时移出了搜索索引和粉丝的时间线
+	if blog, err := GetABlog(blogId); err == nil {
+		indexBlog(blog)
+		if blog.IsPublic() {
+			blogPublished(blog)
+		}
+	}
+	return nil
+}
+
+// 彻底删除在before之前移入回收站的博客，返回删除的数量
+func PurgeTrash(before time.Time) (n int, err error) {
+	for {
+		var ids []int
+		err = dao.DB.Unscoped().Model(&Blog{}).Where("deleted_at < ?", before).
+			Order("deleted_at").Limit(purgeBatch).Pluck("blog_id", &ids).Error
+		if err != nil {
+			return n, errors.New("read trash error")
+		}
+		for _, id := range ids {
+			purged, err := purgeBlog(id, before)
+			if err != nil {
+				return n, err
+			}
+			if purged {
+				n++
+			}
+		}
+		if len(ids) < purgeBatch {
+			return n, nil
+		}
+	}
+}
+
+// 在一个事务中删除博客及其评论等关联数据
+// 先以删除时间为条件删除博客，期间被恢复的博客不会被删除
+func purgeBlog(blogId int, before time.Time) (bool, error) {
+	tx := dao.DB.Begin()
+	res := tx.Unscoped().Where("blog_id = ? AND deleted_at < ?", blogId, before).Delete(&Blog{})
+	if res.Error != nil {
+		tx.Rollback()
+		return false, errors.New("purge blog error")
+	}
+	if res.RowsAffected == 0 {
+		tx.Rollback()
+		return false, nil
+	}
+	for _, model := range []interface{}{&Comment{}, &Notification{}, &BlogRevision{}, &BlogTag{},
+		&BlogLike{}, &Bookmark{}, &TimelineEntry{}, &BlogAttachment{}, &Review{}} {
+		// 不再被引用的附件由后台任务清理
+		if err := tx.Where("blog_id = ?", blogId).Delete(model).Error; err != nil {
+			tx.Rollback()
+			return false, errors.New("purge blog error")
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return false, errors.New("purge blog error")
+	}
+	if err := search.Default.Delete(blogId); err != nil {
+		log.Printf("remove blog %d from search index failed, err:%v", blogId, err)
+	}
+	return true, nil
+}
+
+// 启动后台协程，定期彻底删除回收站中超过retention的博客
+func StartTrashPurger(interval, retention time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			n, err := PurgeTrash(now.Add(-retention))
+			if err != nil {
+				log.Printf("purge trash failed, err:%v", err)
+			} else if n > 0 {
+				log.Printf("purged %d blogs from trash", n)
+			}
+		}
+	}()
+}
@@ -1,22 +1,112 @@
 package models
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
 	"errors"
 	"gin_work/dao"
+	"github.com/jinzhu/gorm"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 用户角色
+const (
+	RoleAdmin  = "admin"  // 管理员，可以修改和删除所有内容
+	RoleAuthor = "author" // 作者，可以发布博客
+	RoleReader = "reader" // 读者，只能浏览和评论
 )
 
 type User struct {
 	UserId   int    `json:"userId" gorm:"PRIMARY_KEY;AUTO_INCREMENT"`
 	UserName string `json:"userName" gorm:"UNIQUE"`
 	Password string `json:"password"`
-	Email    string `json:"email"`
+	// Email 邮箱，未填写时为NULL，同一邮箱只能属于一个用户
+	Email *string `json:"email" gorm:"type:varchar(255);unique_index"`
+	Role  string  `json:"role" gorm:"type:varchar(16);default:'author'"`
+	// EmailVerified 邮箱是否已验证
+	EmailVerified bool `json:"emailVerified"`
+	// Address 绑定的以太坊钱包地址，未绑定时为NULL
+	Address *string `json:"address" gorm:"type:varchar(42);UNIQUE"`
+	// TOTPEnabled 是否开启两步验证
+	TOTPEnabled bool `json:"totpEnabled"`
+	// TOTPSecret 两步验证密钥，开启前为待确认的密钥
+	TOTPSecret string `json:"-" gorm:"type:varchar(64)"`
+	// TOTPLastStep 最近一次使用的时间步，防止验证码重放
+	TOTPLastStep int64 `json:"-"`
+	// RecoveryCodes 恢复码的哈希，逗号分隔
+	RecoveryCodes string `json:"-" gorm:"type:text"`
+	// 个人资料
+	DisplayName string    `json:"displayName" gorm:"type:varchar(64)"`
+	Bio         string    `json:"bio" gorm:"type:varchar(1000)"`
+	AvatarURL   string    `json:"avatarUrl" gorm:"type:varchar(255)"`
+	CreatedAt   time.Time `json:"created_at"`
+	// FanoutOnWrite 热门作者，发布的博客会预先写入粉丝的时间线
+	FanoutOnWrite bool `json:"-"`
+	// ModerateComments 他人在该用户博客下的评论是否需要审核，为NULL时使用站点的默认设置
+	ModerateComments *bool `json:"moderateComments"`
+	// TokenVersion 修改密码时递增，之前签发的访问令牌随之失效
+	TokenVersion int `json:"-"`
+}
+
+// 他人的评论是否需要该用户审核，siteDefault 为站点的默认设置
+func (u *User) ModeratesComments(siteDefault bool) bool {
+	if u.ModerateComments != nil {
+		return *u.ModerateComments
+	}
+	return siteDefault
+}
+
+// ErrEmailTaken 邮箱已被其他用户使用
+var ErrEmailTaken = errors.New("email already in use")
+
+// 邮箱地址，未填写时为空字符串
+func (u *User) EmailAddress() string {
+	if u.Email == nil {
+		return ""
+	}
+	return *u.Email
+}
+
+// 空邮箱保存为NULL，唯一索引不限制NULL
+func normalizeEmail(email *string) *string {
+	if email == nil || *email == "" {
+		return nil
+	}
+	return email
+}
+
+// 邮箱是否已被其他用户使用，exceptUserId 为修改邮箱的用户
+func EmailTaken(email string, exceptUserId int) (bool, error) {
+	var count int
+	err := dao.DB.Model(&User{}).Where("email = ? AND user_id <> ?", email, exceptUserId).Count(&count).Error
+	if err != nil {
+		return false, errors.New("read user error")
+	}
+	return count > 0, nil
+}
+
+// 判断角色是否合法
+func ValidRole(role string) bool {
+	return role == RoleAdmin || role == RoleAuthor || role == RoleReader
+}
+
+// ErrReservedName 用户名为钱包地址的格式，只能由钱包登录自动创建
+var ErrReservedName = errors.New("user name is reserved")
+
+// 钱包地址格式的用户名
+var addressNamePattern = regexp.MustCompile(`(?i)^0x[0-9a-f]{40}$`)
+
+// 用户名是否保留给钱包用户，避免提前注册地址阻止该钱包登录
+func ReservedUserName(name string) bool {
+	return addressNamePattern.MatchString(strings.TrimSpace(name))
 }
 
 // 新建用户
 func CreateUser(user *User) (err error) {
 
+	if ReservedUserName(user.UserName) {
+		return ErrReservedName
+	}
 	//根据userName判断当前用户是否存在。如果存在则不能创建
 
 	err = dao.DB.Where("user_name = ?", user.UserName).First(user).Error
@@ -24,7 +114,30 @@ func CreateUser(user *User) (err error) {
 		return errors.New("user exists")
 	}
 	user.Password, err = hashPassword(user.Password)
+	if err != nil {
+		return errors.New("hash password error")
+	}
+	user.Email = normalizeEmail(user.Email)
+	if user.Email != nil {
+		taken, err := EmailTaken(*user.Email, 0)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrEmailTaken
+		}
+	}
+	// 注册用户默认为作者，角色只能由管理员修改
+	user.Role = RoleAuthor
+	user.EmailVerified = false
+	// 钱包地址只能通过签名登录绑定，注册时提交的地址会抢占该钱包的账号
+	user.Address = nil
+	// 两步验证需要确认密钥后才能开启
+	user.TOTPEnabled = false
 	err = dao.DB.Create(user).Error
+	if err != nil {
+		return errors.New("create user error")
+	}
 	return nil
 }
 
@@ -38,26 +151,107 @@ func GetUserBy(user *User) (err error) {
 	if userDB.UserId == 0 {
 		return errors.New("user not found")
 	}
-	//加密密码
-	user.Password, err = hashPassword(user.Password)
-	if userDB.Password != user.Password {
+	//校验密码
+	ok, rehash := verifyPassword(user.Password, userDB.Password)
+	if !ok {
 		// 重新定义错误
 		return errors.New("incorrect password")
 	}
+	// 旧算法或旧参数的哈希，登录成功后升级为新的哈希
+	if rehash {
+		if hashed, err := hashPassword(user.Password); err == nil {
+			if dao.DB.Model(&userDB).Update("password", hashed).Error == nil {
+				userDB.Password = hashed
+			}
+		}
+	}
 	// 密码正确
 	*user = userDB
 	return nil
 }
 
-// 加密密码
-func hashPassword(password string) (string, error) {
-	// 定义一个全局的pepper，这个pepper应该来自配置文件或者环境变量，并且要保密
-	pepper := "MyFixedSalt123！"
-	// 将pepper添加到密码中
-	passwordWithPepper := password + pepper
-	hash := sha256.New()
-	hash.Write([]byte(passwordWithPepper))
-	hashedBytes := hash.Sum(nil)
-	hashedPassword := hex.EncodeToString(hashedBytes)
-	return hashedPassword, nil
+// 通过用户名获取用户
+func GetUserByName(userName string) (user *User, err error) {
+	user = new(User)
+	err = dao.DB.Where("user_name = ?", userName).First(user).Error
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	return user, nil
+}
+
+// 修改用户角色
+func SetUserRole(userName string, role string) (err error) {
+	if !ValidRole(role) {
+		return errors.New("invalid role")
+	}
+	res := dao.DB.Model(&User{}).Where("user_name = ?", userName).Update("role", role)
+	if res.Error != nil {
+		return errors.New("update role error")
+	}
+	if res.RowsAffected == 0 {
+		return errors.New("user not found")
+	}
+	return nil
+}
+
+// 还没有管理员时把指定用户设为管理员，用于创建第一个管理员
+// 已有管理员时不做任何修改，返回是否设置成功
+func BootstrapAdmin(userName string) (bool, error) {
+	var count int
+	if err := dao.DB.Model(&User{}).Where("role = ?", RoleAdmin).Count(&count).Error; err != nil {
+		return false, errors.New("read user error")
+	}
+	if count > 0 {
+		return false, nil
+	}
+	if err := SetUserRole(userName, RoleAdmin); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// 通过邮箱获取用户
+func GetUserByEmail(email string) (user *User, err error) {
+	user = new(User)
+	err = dao.DB.Where("email = ?", email).First(user).Error
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	return user, nil
+}
+
+// 修改密码，同时递增令牌版本，已签发的访问令牌全部失效
+func SetPassword(userId int, password string) (err error) {
+	hashed, err := hashPassword(password)
+	if err != nil {
+		return errors.New("hash password error")
+	}
+	err = dao.DB.Model(&User{}).Where("user_id = ?", userId).Updates(map[string]interface{}{
+		"password":      hashed,
+		"token_version": gorm.Expr("token_version + 1"),
+	}).Error
+	if err != nil {
+		return errors.New("update password error")
+	}
+	return nil
+}
+
+// 标记邮箱已验证
+func MarkEmailVerified(userId int) (err error) {
+	err = dao.DB.Model(&User{}).Where("user_id = ?", userId).Update("email_verified", true).Error
+	if err != nil {
+		return errors.New("verify email error")
+	}
+	return nil
+}
+
+// 通过ID获取用户
+func GetUserById(userId int) (user *User, err error) {
+	user = new(User)
+	err = dao.DB.Where("user_id = ?", userId).First(user).Error
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	return user, nil
 }
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"gin_work/dao"
+	"testing"
+)
+
+// 注册请求中只有用户名、密码和邮箱有效，其余字段由服务端决定
+func TestCreateUserResetsServerFields(t *testing.T) {
+	setupTestDB(t, &User{})
+	address := "0x96216849c49358b10257cb55b28ea603c874b05e"
+	user := &User{
+		UserName:      "mallory",
+		Password:      "pw",
+		Role:          RoleAdmin,
+		EmailVerified: true,
+		Address:       &address,
+		TOTPEnabled:   true,
+	}
+	if err := CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	var stored User
+	if err := dao.DB.Where("user_name = ?", "mallory").First(&stored).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Role != RoleAuthor || stored.EmailVerified || stored.Address != nil || stored.TOTPEnabled {
+		t.Fatalf("server fields taken from request: %+v", stored)
+	}
+	// 钱包登录不会落到注册的账号上
+	if err := dao.DB.Where("address = ?", address).First(&User{}).Error; err == nil {
+		t.Fatal("address bound by registration")
+	}
+}
+
+func TestCreateUserReservedName(t *testing.T) {
+	setupTestDB(t, &User{})
+	for _, name := range []string{"0x96216849C49358B10257cb55b28eA603c874b05E", " 0x96216849c49358b10257cb55b28ea603c874b05e "} {
+		if err := CreateUser(&User{UserName: name, Password: "pw"}); err != ErrReservedName {
+			t.Fatalf("CreateUser(%q) err = %v", name, err)
+		}
+	}
+	if err := CreateUser(&User{UserName: "0xabc", Password: "pw"}); err != nil {
+		t.Fatalf("short hex name rejected: %v", err)
+	}
+}
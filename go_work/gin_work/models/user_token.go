@@ -0,0 +1,58 @@
+package models
+
+import (
+	"errors"
+	"gin_work/dao"
+	"time"
+)
+
+// 一次性令牌的用途
+const (
+	TokenVerifyEmail   = "verify_email"
+	TokenResetPassword = "reset_password"
+)
+
+// UserToken 邮箱验证、密码重置等一次性令牌，只保存哈希
+type UserToken struct {
+	TokenId   int        `json:"tokenId" gorm:"PRIMARY_KEY;AUTO_INCREMENT"`
+	UserId    int        `json:"userId" gorm:"index"`
+	Purpose   string     `json:"purpose" gorm:"type:varchar(32)"`
+	TokenHash string     `json:"-" gorm:"type:char(64);UNIQUE"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// 保存一次性令牌，同一用途的旧令牌全部作废
+func CreateUserToken(userId int, purpose, token string, ttl time.Duration) (err error) {
+	now := time.Now()
+	dao.DB.Model(&UserToken{}).Where("user_id = ? AND purpose = ? AND used_at IS NULL", userId, purpose).
+		Update("used_at", now)
+	err = dao.DB.Create(&UserToken{
+		UserId:    userId,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		ExpiresAt: now.Add(ttl),
+	}).Error
+	if err != nil {
+		return errors.New("create token error")
+	}
+	return nil
+}
+
+// 使用一次性令牌，成功时返回令牌所属的用户ID
+func ConsumeUserToken(purpose, token string) (userId int, err error) {
+	hash := hashToken(token)
+	res := dao.DB.Model(&UserToken{}).
+		Where("token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at >= ?", hash, purpose, time.Now()).
+		Update("used_at", time.Now())
+	if res.Error != nil || res.RowsAffected != 1 {
+		return 0, errors.New("invalid or expired token")
+	}
+	var ut UserToken
+	err = dao.DB.Where("token_hash = ?", hash).First(&ut).Error
+	if err != nil {
+		return 0, errors.New("invalid or expired token")
+	}
+	return ut.UserId, nil
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"errors"
+	"gin_work/dao"
+	"github.com/jinzhu/gorm"
+	"log"
+	"sync"
+	"time"
+)
+
+// 浏览数先累计在内存中，定期批量写入数据库，避免每次阅读都写库
+var views = struct {
+	sync.Mutex
+	pending map[int]int
+}{pending: map[int]int{}}
+
+// 记录一次浏览
+func RecordView(blogId int) {
+	views.Lock()
+	views.pending[blogId]++
+	views.Unlock()
+}
+
+// 把内存中的浏览数写入数据库，返回写入的博客数
+// 写入失败的计数会放回内存，下次继续写入
+func FlushViews() (n int, err error) {
+	views.Lock()
+	pending := views.pending
+	views.pending = map[int]int{}
+	views.Unlock()
+
+	for blogId, count := range pending {
+		err = dao.DB.Model(&Blog{}).Where("blog_id = ?", blogId).
+			UpdateColumn("view_count", gorm.Expr("view_count + ?", count)).Error
+		if err != nil {
+			views.Lock()
+			for id, c := range pending {
+				views.pending[id] += c
+			}
+			views.Unlock()
+			return n, errors.New("flush views error")
+		}
+		delete(pending, blogId)
+		n++
+	}
+	return n, nil
+}
+
+// 启动后台协程，定期写入浏览数
+func StartViewFlusher(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := FlushViews(); err != nil {
+				log.Printf("flush blog views failed, err:%v", err)
+			}
+		}
+	}()
+}
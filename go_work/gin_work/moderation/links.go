@@ -0,0 +1,88 @@
+package moderation
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>()"'\[\]]+`)
+
+// 去掉链接后少于该字数的评论视为只有链接
+const minCommentText = 10
+
+// 同一个字母或数字连续出现的次数上限
+const maxRepeatedRunes = 30
+
+// LinkFilter 链接和垃圾内容的规则
+//   - 包含屏蔽域名(及其子域名)的链接时拒绝
+//   - 链接数量超过上限时进入人工审核
+//   - 评论只有链接，或者同一个字符大量重复时进入人工审核
+type LinkFilter struct {
+	// MaxLinks 每种内容允许的链接数量，0表示不限制
+	MaxLinks       map[string]int
+	BlockedDomains []string
+}
+
+func (f *LinkFilter) Name() string { return "links" }
+
+func (f *LinkFilter) Check(c *Content) Decision {
+	links := linkPattern.FindAllString(c.Text, -1)
+	for _, link := range links {
+		if domain := f.blocked(link); domain != "" {
+			return Decision{Verdict: Reject, Reason: "blocked domain " + domain}
+		}
+	}
+	if max := f.MaxLinks[c.Kind]; max > 0 && len(links) > max {
+		return Decision{Verdict: Hold, Reason: fmt.Sprintf("too many links (%d > %d)", len(links), max)}
+	}
+	if c.Kind == KindComment && len(links) > 0 {
+		rest := strings.TrimSpace(linkPattern.ReplaceAllString(c.Text, ""))
+		if utf8.RuneCountInString(rest) < minCommentText {
+			return Decision{Verdict: Hold, Reason: "link-only comment"}
+		}
+	}
+	if repeatedRunes(c.Text) {
+		return Decision{Verdict: Hold, Reason: "repeated characters"}
+	}
+	return Decision{}
+}
+
+// 链接命中的屏蔽域名
+func (f *LinkFilter) blocked(link string) string {
+	if strings.HasPrefix(strings.ToLower(link), "www.") {
+		link = "http://" + link
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, domain := range f.BlockedDomains {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if domain != "" && (host == domain || strings.HasSuffix(host, "."+domain)) {
+			return domain
+		}
+	}
+	return ""
+}
+
+// 是否有字母或数字连续重复超过上限，标点不计，Markdown的分隔线等会大量重复标点
+func repeatedRunes(text string) bool {
+	var last rune
+	n := 0
+	for _, r := range text {
+		if r == last && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			n++
+			if n >= maxRepeatedRunes {
+				return true
+			}
+			continue
+		}
+		last, n = r, 1
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package moderation
+
+import (
+	"fmt"
+	"gin_work/setting"
+	"strings"
+	"time"
+)
+
+// 内容类型
+const (
+	KindBlog    = "blog"
+	KindComment = "comment"
+)
+
+// Verdict 审核结论，数值越大越严格
+type Verdict int
+
+const (
+	Allow  Verdict = iota // 直接发布
+	Hold                  // 进入人工审核
+	Reject                // 拒绝发布
+)
+
+var verdictNames = map[Verdict]string{Allow: "allow", Hold: "hold", Reject: "reject"}
+
+func (v Verdict) String() string {
+	return verdictNames[v]
+}
+
+func (v Verdict) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+// 解析配置中的处理方式
+func ParseVerdict(s string) (Verdict, error) {
+	for v, name := range verdictNames {
+		if strings.EqualFold(s, name) {
+			return v, nil
+		}
+	}
+	return Allow, fmt.Errorf("invalid verdict %q", s)
+}
+
+// Content 待审核的内容
+type Content struct {
+	Kind   string
+	UserId int
+	Title  string
+	Text   string
+	// Update 修改已有的内容，不计入发布频率
+	Update bool
+}
+
+// Decision 一个过滤器的结论
+type Decision struct {
+	Verdict Verdict
+	Reason  string
+}
+
+// Filter 内容过滤器
+type Filter interface {
+	Name() string
+	Check(c *Content) Decision
+}
+
+// Recorder 需要记录已发布内容的过滤器，例如统计发布频率
+type Recorder interface {
+	Record(c *Content)
+}
+
+// Result 审核结果，Reasons 为各过滤器给出的原因
+type Result struct {
+	Verdict Verdict  `json:"verdict"`
+	Reasons []string `json:"reasons"`
+}
+
+// Pipeline 依次执行过滤器，取最严格的结论，遇到拒绝时不再执行后面的过滤器
+// Check 只做判断，内容保存成功后调用 Commit 记录
+type Pipeline struct {
+	filters []Filter
+}
+
+func NewPipeline(filters ...Filter) *Pipeline {
+	return &Pipeline{filters: filters}
+}
+
+func (p *Pipeline) Check(c *Content) Result {
+	result := Result{Reasons: []string{}}
+	for _, f := range p.filters {
+		d := f.Check(c)
+		if d.Verdict == Allow {
+			continue
+		}
+		result.Reasons = append(result.Reasons, f.Name()+": "+d.Reason)
+		if d.Verdict > result.Verdict {
+			result.Verdict = d.Verdict
+		}
+		if d.Verdict == Reject {
+			break
+		}
+	}
+	return result
+}
+
+// Commit 内容通过审核并保存成功后调用，被拒绝或保存失败的提交不计入记录
+func (p *Pipeline) Commit(c *Content) {
+	for _, f := range p.filters {
+		if r, ok := f.(Recorder); ok {
+			r.Record(c)
+		}
+	}
+}
+
+// Default 默认的审核流程，未初始化时不拦截任何内容
+var Default = NewPipeline()
+
+// Init 根据配置创建审核流程，顺序为发布频率、敏感词、链接
+func Init(cfg *setting.ModerationConfig) error {
+	if cfg == nil || !cfg.ModerationEnabled {
+		return nil
+	}
+	var filters []Filter
+	window := cfg.RateWindow
+	if window <= 0 {
+		window = 10 * time.Minute
+	}
+	filters = append(filters, NewRateFilter(window, map[string]int{
+		KindBlog:    cfg.BlogRate,
+		KindComment: cfg.CommentRate,
+	}))
+	if cfg.WordFile != "" {
+		action := Hold
+		if cfg.WordAction != "" {
+			var err error
+			if action, err = ParseVerdict(cfg.WordAction); err != nil {
+				return err
+			}
+		}
+		words, err := LoadWords(cfg.WordFile, action)
+		if err != nil {
+			return err
+		}
+		filters = append(filters, NewWordFilter(words))
+	}
+	filters = append(filters, &LinkFilter{
+		MaxLinks:       map[string]int{KindBlog: cfg.BlogMaxLinks, KindComment: cfg.CommentMaxLinks},
+		BlockedDomains: cfg.BlockedDomains,
+	})
+	Default = NewPipeline(filters...)
+	return nil
+}
@@ -0,0 +1,163 @@
+package moderation
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 返回固定结论的过滤器，记录被调用的次数
+type stubFilter struct {
+	name     string
+	decision Decision
+	calls    int
+}
+
+func (f *stubFilter) Name() string { return f.name }
+
+func (f *stubFilter) Check(c *Content) Decision {
+	f.calls++
+	return f.decision
+}
+
+func TestPipeline(t *testing.T) {
+	allow := &stubFilter{name: "a"}
+	hold := &stubFilter{name: "h", decision: Decision{Verdict: Hold, Reason: "held"}}
+	reject := &stubFilter{name: "r", decision: Decision{Verdict: Reject, Reason: "rejected"}}
+	last := &stubFilter{name: "last", decision: Decision{Verdict: Hold, Reason: "late"}}
+
+	got := NewPipeline(allow, hold, reject, last).Check(&Content{})
+	want := Result{Verdict: Reject, Reasons: []string{"h: held", "r: rejected"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Check = %+v, want %+v", got, want)
+	}
+	if allow.calls != 1 || last.calls != 0 {
+		t.Fatalf("calls = %d, %d; want filters after reject skipped", allow.calls, last.calls)
+	}
+
+	if got = NewPipeline().Check(&Content{}); got.Verdict != Allow || got.Reasons == nil {
+		t.Fatalf("empty pipeline = %+v", got)
+	}
+}
+
+// 排在前面的发布频率过滤器不统计被后面的过滤器拒绝的内容，Commit 之后才计入
+func TestPipelineCommit(t *testing.T) {
+	rate := NewRateFilter(time.Minute, map[string]int{KindComment: 1})
+	reject := &stubFilter{name: "r", decision: Decision{Verdict: Reject, Reason: "rejected"}}
+	comment := &Content{Kind: KindComment, UserId: 1}
+
+	rejecting := NewPipeline(rate, reject)
+	for i := 0; i < 3; i++ {
+		if got := rejecting.Check(comment); !reflect.DeepEqual(got.Reasons, []string{"r: rejected"}) {
+			t.Fatalf("attempt %d reasons = %v", i, got.Reasons)
+		}
+	}
+
+	p := NewPipeline(rate, &stubFilter{name: "a"})
+	if got := p.Check(comment); got.Verdict != Allow {
+		t.Fatalf("first comment = %+v", got)
+	}
+	// 保存失败时不调用 Commit
+	if got := p.Check(comment); got.Verdict != Allow {
+		t.Fatalf("comment after failed save = %+v", got)
+	}
+	p.Commit(comment)
+	if got := p.Check(comment); got.Verdict != Reject {
+		t.Fatalf("comment after commit = %+v", got)
+	}
+	// 没有实现 Recorder 的过滤器被跳过
+	NewPipeline(&stubFilter{name: "a"}).Commit(comment)
+}
+
+func TestParseVerdict(t *testing.T) {
+	for _, s := range []string{"allow", "HOLD", "Reject"} {
+		v, err := ParseVerdict(s)
+		if err != nil || v.String() != strings.ToLower(s) {
+			t.Fatalf("ParseVerdict(%q) = %v, %v", s, v, err)
+		}
+	}
+	if _, err := ParseVerdict("block"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestRateFilter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := NewRateFilter(time.Minute, map[string]int{KindComment: 2})
+	f.now = func() time.Time { return now }
+	// 通过检查的内容保存成功
+	check := func(c Content) Verdict {
+		v := f.Check(&c).Verdict
+		if v == Allow {
+			f.Record(&c)
+		}
+		return v
+	}
+
+	comment := Content{Kind: KindComment, UserId: 1}
+	// 只检查不记录的提交不计入次数
+	for i := 0; i < 3; i++ {
+		if f.Check(&comment).Verdict != Allow {
+			t.Fatal("unrecorded checks should not count")
+		}
+	}
+	if check(comment) != Allow || check(comment) != Allow {
+		t.Fatal("first comments should pass")
+	}
+	if check(comment) != Reject {
+		t.Fatal("third comment in window should be rejected")
+	}
+	// 修改、其他用户和不限制的类型不受影响
+	f.Record(&Content{Kind: KindComment, UserId: 1, Update: true})
+	if check(Content{Kind: KindComment, UserId: 1, Update: true}) != Allow {
+		t.Fatal("updates are not rate limited")
+	}
+	if check(Content{Kind: KindComment, UserId: 2}) != Allow {
+		t.Fatal("other users have their own limit")
+	}
+	if check(Content{Kind: KindBlog, UserId: 1}) != Allow {
+		t.Fatal("kind without limit should pass")
+	}
+	// 被拒绝的请求不计入次数，窗口过去后恢复
+	now = now.Add(time.Minute)
+	if check(comment) != Allow {
+		t.Fatal("comment after window should pass")
+	}
+}
+
+func TestLinkFilter(t *testing.T) {
+	f := &LinkFilter{
+		MaxLinks:       map[string]int{KindBlog: 2},
+		BlockedDomains: []string{" Spam.example "},
+	}
+	longText := "这是一段足够长的评论内容，用来说明问题"
+	tests := []struct {
+		name    string
+		content Content
+		want    Verdict
+	}{
+		{"plain text", Content{Kind: KindBlog, Text: "hello world"}, Allow},
+		{"blocked domain", Content{Kind: KindBlog, Text: "see https://spam.example/x"}, Reject},
+		{"blocked subdomain", Content{Kind: KindBlog, Text: "see http://a.SPAM.example"}, Reject},
+		{"blocked www link", Content{Kind: KindBlog, Text: "see www.spam.example"}, Reject},
+		{"similar domain", Content{Kind: KindBlog, Text: "see https://notspam.example"}, Allow},
+		{"links within limit", Content{Kind: KindBlog, Text: "https://a.example https://b.example"}, Allow},
+		{"too many links", Content{Kind: KindBlog, Text: "https://a.example https://b.example https://c.example"}, Hold},
+		{"comment without limit", Content{Kind: KindComment, Text: longText + " https://a.example https://b.example https://c.example"}, Allow},
+		{"link-only comment", Content{Kind: KindComment, Text: "look https://a.example"}, Hold},
+		{"comment with text", Content{Kind: KindComment, Text: longText + " https://a.example"}, Allow},
+		{"blog with only link", Content{Kind: KindBlog, Text: "https://a.example"}, Allow},
+		{"repeated letters", Content{Kind: KindComment, Text: strings.Repeat("a", maxRepeatedRunes)}, Hold},
+		{"repeated below limit", Content{Kind: KindComment, Text: strings.Repeat("a", maxRepeatedRunes-1)}, Allow},
+		{"repeated punctuation", Content{Kind: KindBlog, Text: strings.Repeat("-", 100)}, Allow},
+		{"repeated chinese", Content{Kind: KindComment, Text: strings.Repeat("啊", maxRepeatedRunes)}, Hold},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if d := f.Check(&tt.content); d.Verdict != tt.want {
+				t.Fatalf("Check(%q) = %v (%s), want %v", tt.content.Text, d.Verdict, d.Reason, tt.want)
+			}
+		})
+	}
+}
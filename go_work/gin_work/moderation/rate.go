@@ -0,0 +1,83 @@
+package moderation
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 每记录这么多次清理一次已经过期的记录
+const rateSweepEvery = 1000
+
+// RateFilter 限制每个用户在时间窗口内发布的数量，超过时拒绝
+// 只统计保存成功的新内容，见 Record，记录保存在内存中，重启后清空
+type RateFilter struct {
+	window time.Duration
+	// limits 每种内容在窗口内允许发布的数量，0表示不限制
+	limits map[string]int
+	now    func() time.Time
+
+	mu      sync.Mutex
+	history map[string][]time.Time
+	records int
+}
+
+func NewRateFilter(window time.Duration, limits map[string]int) *RateFilter {
+	return &RateFilter{window: window, limits: limits, now: time.Now, history: map[string][]time.Time{}}
+}
+
+func (f *RateFilter) Name() string { return "rate" }
+
+func (f *RateFilter) Check(c *Content) Decision {
+	limit := f.limits[c.Kind]
+	if c.Update || limit <= 0 {
+		return Decision{}
+	}
+	now := f.now()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.recent(rateKey(c), now)) >= limit {
+		return Decision{Verdict: Reject, Reason: fmt.Sprintf("posting too fast, at most %d %ss per %s", limit, c.Kind, f.window)}
+	}
+	return Decision{}
+}
+
+// Record 记录一次发布，只统计保存成功的新内容
+func (f *RateFilter) Record(c *Content) {
+	if c.Update || f.limits[c.Kind] <= 0 {
+		return
+	}
+	now := f.now()
+	key := rateKey(c)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.records++
+	if f.records%rateSweepEvery == 0 {
+		for k, times := range f.history {
+			if len(times) == 0 || now.Sub(times[len(times)-1]) >= f.window {
+				delete(f.history, k)
+			}
+		}
+	}
+	f.history[key] = append(f.recent(key, now), now)
+}
+
+// 返回窗口内的发布时间，同时去掉窗口之外的记录，需要持有锁
+func (f *RateFilter) recent(key string, now time.Time) []time.Time {
+	times := f.history[key]
+	i := 0
+	for i < len(times) && now.Sub(times[i]) >= f.window {
+		i++
+	}
+	if i > 0 {
+		times = times[i:]
+		f.history[key] = times
+	}
+	return times
+}
+
+func rateKey(c *Content) string {
+	return fmt.Sprintf("%s:%d", c.Kind, c.UserId)
+}
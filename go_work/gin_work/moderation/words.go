@@ -0,0 +1,208 @@
+package moderation
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Word 一个敏感词及命中后的处理方式
+type Word struct {
+	Text   string
+	Action Verdict
+}
+
+// 读取敏感词文件，每行一个词，可在词后写 hold 或 reject 指定处理方式，否则使用 action
+// 以 # 开头的行为注释
+func LoadWords(path string, action Verdict) ([]Word, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var words []Word
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		word := Word{Text: line, Action: action}
+		fields := strings.Fields(line)
+		if len(fields) > 1 {
+			if v, err := ParseVerdict(fields[len(fields)-1]); err == nil {
+				if v == Allow {
+					return nil, fmt.Errorf("%s:%d: invalid action", path, n)
+				}
+				word = Word{Text: strings.Join(fields[:len(fields)-1], " "), Action: v}
+			}
+		}
+		words = append(words, word)
+	}
+	return words, scanner.Err()
+}
+
+// Matcher 用Aho-Corasick自动机在文本中一次查找所有敏感词
+// 匹配不区分大小写和全角半角；纯英文数字的词需要完整匹配单词，避免误伤包含它的长单词
+type Matcher struct {
+	nodes []acNode
+	words []Word
+	// wholeWord[i] 第i个词是否需要按单词边界匹配
+	wholeWord []bool
+}
+
+type acNode struct {
+	next map[rune]int
+	fail int
+	// 在该节点结束的词，包含通过失败指针可以到达的词
+	out []int
+}
+
+func NewMatcher(words []Word) *Matcher {
+	m := &Matcher{nodes: []acNode{{next: map[rune]int{}}}}
+	for _, word := range words {
+		text := normalize([]rune(word.Text))
+		if len(text) == 0 {
+			continue
+		}
+		cur := 0
+		for _, r := range text {
+			nxt, ok := m.nodes[cur].next[r]
+			if !ok {
+				nxt = len(m.nodes)
+				m.nodes = append(m.nodes, acNode{next: map[rune]int{}})
+				m.nodes[cur].next[r] = nxt
+			}
+			cur = nxt
+		}
+		// 重复的词只保留一个，处理方式取更严格的
+		if out := m.nodes[cur].out; len(out) > 0 {
+			if word.Action > m.words[out[0]].Action {
+				m.words[out[0]].Action = word.Action
+			}
+			continue
+		}
+		m.nodes[cur].out = append(m.nodes[cur].out, len(m.words))
+		m.words = append(m.words, Word{Text: string(text), Action: word.Action})
+		m.wholeWord = append(m.wholeWord, isWordText(text))
+	}
+
+	// 按层构建失败指针
+	queue := make([]int, 0, len(m.nodes))
+	for _, child := range m.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for r, child := range m.nodes[cur].next {
+			fail := m.nodes[cur].fail
+			for fail != 0 {
+				if _, ok := m.nodes[fail].next[r]; ok {
+					break
+				}
+				fail = m.nodes[fail].fail
+			}
+			if target, ok := m.nodes[fail].next[r]; ok && target != child {
+				m.nodes[child].fail = target
+			}
+			m.nodes[child].out = append(m.nodes[child].out, m.nodes[m.nodes[child].fail].out...)
+			queue = append(queue, child)
+		}
+	}
+	return m
+}
+
+// Match 返回文本中出现的敏感词，按词排序，每个词只返回一次
+func (m *Matcher) Match(text string) []Word {
+	runes := normalize([]rune(text))
+	found := map[int]bool{}
+	cur := 0
+	for i, r := range runes {
+		for cur != 0 {
+			if _, ok := m.nodes[cur].next[r]; ok {
+				break
+			}
+			cur = m.nodes[cur].fail
+		}
+		cur = m.nodes[cur].next[r]
+		for _, w := range m.nodes[cur].out {
+			if found[w] {
+				continue
+			}
+			start := i - len([]rune(m.words[w].Text)) + 1
+			if m.wholeWord[w] && (start > 0 && isWordRune(runes[start-1]) || i+1 < len(runes) && isWordRune(runes[i+1])) {
+				continue
+			}
+			found[w] = true
+		}
+	}
+	words := make([]Word, 0, len(found))
+	for w := range found {
+		words = append(words, m.words[w])
+	}
+	sort.Slice(words, func(i, j int) bool { return words[i].Text < words[j].Text })
+	return words
+}
+
+// 转为小写，全角字符转为半角，连续的空白合并为一个空格
+func normalize(runes []rune) []rune {
+	out := make([]rune, 0, len(runes))
+	for _, r := range runes {
+		if r >= 0xFF01 && r <= 0xFF5E {
+			r -= 0xFEE0
+		}
+		if unicode.IsSpace(r) {
+			if len(out) > 0 && out[len(out)-1] == ' ' {
+				continue
+			}
+			r = ' '
+		}
+		out = append(out, unicode.ToLower(r))
+	}
+	return out
+}
+
+func isWordRune(r rune) bool {
+	return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+func isWordText(text []rune) bool {
+	for _, r := range text {
+		if !isWordRune(r) && r != ' ' {
+			return false
+		}
+	}
+	return true
+}
+
+// WordFilter 敏感词过滤，结论为命中的词中最严格的处理方式
+type WordFilter struct {
+	matcher *Matcher
+}
+
+func NewWordFilter(words []Word) *WordFilter {
+	return &WordFilter{matcher: NewMatcher(words)}
+}
+
+func (f *WordFilter) Name() string { return "words" }
+
+func (f *WordFilter) Check(c *Content) Decision {
+	words := f.matcher.Match(c.Title + "\n" + c.Text)
+	if len(words) == 0 {
+		return Decision{}
+	}
+	d := Decision{}
+	texts := make([]string, len(words))
+	for i, word := range words {
+		texts[i] = word.Text
+		if word.Action > d.Verdict {
+			d.Verdict = word.Action
+		}
+	}
+	d.Reason = "sensitive words: " + strings.Join(texts, ", ")
+	return d
+}
@@ -0,0 +1,166 @@
+package moderation
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// 命中的词
+func matchedTexts(words []Word) []string {
+	texts := make([]string, len(words))
+	for i, w := range words {
+		texts[i] = w.Text
+	}
+	return texts
+}
+
+func TestMatcher(t *testing.T) {
+	tests := []struct {
+		name  string
+		words []string
+		text  string
+		want  []string
+	}{
+		{"no words", nil, "anything", []string{}},
+		{"no match", []string{"spam"}, "hello world", []string{}},
+		// 经典的 he/she/his/hers 用例，中文没有单词边界的限制
+		{"overlapping words", []string{"乙丙", "甲乙丙", "乙丁甲", "乙丙戊甲"}, "己甲乙丙戊甲", []string{"乙丙", "甲乙丙", "乙丙戊甲"}},
+		{"word inside another", []string{"赌博", "网络赌博"}, "禁止网络赌博", []string{"网络赌博", "赌博"}},
+		{"suffix via fail link", []string{"甲乙丙丁", "乙丙"}, "戊甲乙丙戊", []string{"乙丙"}},
+		{"ascii overlap needs boundaries", []string{"he", "she", "hers"}, "ushers", []string{}},
+		{"chinese in sentence", []string{"代开发票"}, "专业代开发票，联系我", []string{"代开发票"}},
+		{"case insensitive", []string{"Casino"}, "best CASINO here", []string{"casino"}},
+		{"full width", []string{"casino"}, "ｃａｓｉｎｏ online", []string{"casino"}},
+		{"full width word list", []string{"ＶＩＰ"}, "vip only", []string{"vip"}},
+		{"collapsed whitespace", []string{"free  money"}, "get free \n\t money now", []string{"free money"}},
+		{"whole word only", []string{"ass"}, "classic assignment", []string{}},
+		{"whole word matched", []string{"ass"}, "you ass!", []string{"ass"}},
+		{"whole word at edges", []string{"spam"}, "spam", []string{"spam"}},
+		{"digits are word runes", []string{"88"}, "1889 and 88", []string{"88"}},
+		{"cjk ignores word boundary", []string{"赌"}, "赌场", []string{"赌"}},
+		{"mixed word is substring", []string{"v信"}, "加v信", []string{"v信"}},
+		{"reported once", []string{"spam"}, "spam spam spam", []string{"spam"}},
+		{"duplicate words", []string{"spam", "SPAM"}, "spam", []string{"spam"}},
+		{"empty word skipped", []string{"", "  ", "spam"}, "spam", []string{"spam"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var words []Word
+			for _, w := range tt.words {
+				words = append(words, Word{Text: w, Action: Hold})
+			}
+			sort.Strings(tt.want)
+			if got := matchedTexts(NewMatcher(words).Match(tt.text)); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Match(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+// 与逐个查找的结果比较，中文字符不受单词边界影响
+func TestMatcherAgainstNaive(t *testing.T) {
+	alphabet := []rune("赌博色情发票")
+	r := rand.New(rand.NewSource(1))
+	randomText := func(max int) string {
+		runes := make([]rune, 1+r.Intn(max))
+		for i := range runes {
+			runes[i] = alphabet[r.Intn(len(alphabet))]
+		}
+		return string(runes)
+	}
+	for i := 0; i < 300; i++ {
+		var words []Word
+		seen := map[string]bool{}
+		for j := 0; j < 1+r.Intn(8); j++ {
+			w := randomText(4)
+			if !seen[w] {
+				seen[w] = true
+				words = append(words, Word{Text: w})
+			}
+		}
+		text := randomText(40)
+		want := []string{}
+		for _, w := range words {
+			if strings.Contains(text, w.Text) {
+				want = append(want, w.Text)
+			}
+		}
+		sort.Strings(want)
+		if got := matchedTexts(NewMatcher(words).Match(text)); !reflect.DeepEqual(got, want) {
+			t.Fatalf("Match(%q) with %v = %q, want %q", text, words, got, want)
+		}
+	}
+}
+
+func TestLoadWords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "words.txt")
+	content := "# 注释\n\nspam\nfree money reject\n赌博 hold\n  casino  \nbuy now please\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	words, err := LoadWords(path, Hold)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Word{
+		{Text: "spam", Action: Hold},
+		{Text: "free money", Action: Reject},
+		{Text: "赌博", Action: Hold},
+		{Text: "casino", Action: Hold},
+		{Text: "buy now please", Action: Hold},
+	}
+	if !reflect.DeepEqual(words, want) {
+		t.Fatalf("LoadWords = %+v, want %+v", words, want)
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad.txt")
+	if err = os.WriteFile(bad, []byte("spam allow\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = LoadWords(bad, Hold); err == nil {
+		t.Fatal("expected error for allow action")
+	}
+	if _, err = LoadWords(filepath.Join(t.TempDir(), "missing.txt"), Hold); err == nil {
+		t.Fatal("expected error for missing file")
+	}
+}
+
+func TestWordFilter(t *testing.T) {
+	f := NewWordFilter([]Word{{Text: "spam", Action: Hold}, {Text: "诈骗", Action: Reject}})
+	tests := []struct {
+		name    string
+		content Content
+		want    Verdict
+	}{
+		{"clean", Content{Title: "hello", Text: "world"}, Allow},
+		{"hold in title", Content{Title: "spam offer", Text: "world"}, Hold},
+		{"reject in text", Content{Title: "hello", Text: "这是诈骗"}, Reject},
+		{"strictest wins", Content{Title: "spam", Text: "诈骗"}, Reject},
+		{"title and text are separate", Content{Title: "sp", Text: "am"}, Allow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := f.Check(&tt.content)
+			if d.Verdict != tt.want {
+				t.Fatalf("Check = %v (%s), want %v", d.Verdict, d.Reason, tt.want)
+			}
+			if (d.Reason == "") != (tt.want == Allow) {
+				t.Fatalf("unexpected reason %q", d.Reason)
+			}
+		})
+	}
+}
+
+// 重复的词合并为一个，处理方式取更严格的
+func TestMatcherDuplicateWords(t *testing.T) {
+	m := NewMatcher([]Word{{Text: "spam", Action: Hold}, {Text: "ＳＰＡＭ", Action: Reject}, {Text: "Spam", Action: Hold}})
+	want := []Word{{Text: "spam", Action: Reject}}
+	if got := m.Match("buy spam"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Match = %+v, want %+v", got, want)
+	}
+}
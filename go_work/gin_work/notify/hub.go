@@ -0,0 +1,57 @@
+package notify
+
+import "sync"
+
+// 每个连接缓存的消息数，客户端读取过慢时丢弃新消息
+const bufferSize = 16
+
+// Hub 把消息推送给在线用户的所有连接
+// 订阅关系保存在进程内，多实例部署时每个实例只推送给连接到自己的客户端
+type Hub struct {
+	mu   sync.Mutex
+	subs map[int]map[chan interface{}]struct{}
+}
+
+// Default 默认的推送中心
+var Default = NewHub()
+
+func NewHub() *Hub {
+	return &Hub{subs: map[int]map[chan interface{}]struct{}{}}
+}
+
+// Subscribe 订阅用户的消息，连接断开时需要调用返回的cancel
+func (h *Hub) Subscribe(userId int) (<-chan interface{}, func()) {
+	ch := make(chan interface{}, bufferSize)
+	h.mu.Lock()
+	if h.subs[userId] == nil {
+		h.subs[userId] = map[chan interface{}]struct{}{}
+	}
+	h.subs[userId][ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subs[userId], ch)
+			if len(h.subs[userId]) == 0 {
+				delete(h.subs, userId)
+			}
+			h.mu.Unlock()
+			close(ch)
+		})
+	}
+	return ch, cancel
+}
+
+// Publish 推送消息给用户的所有连接，不会阻塞
+func (h *Hub) Publish(userId int, msg interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs[userId] {
+		select {
+		case ch <- msg:
+		default:
+		}
+	}
+}
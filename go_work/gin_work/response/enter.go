@@ -8,6 +8,8 @@ import (
 var codeMap = map[int]string{
 	1001: "权限错误",
 	1002: "角色错误",
+	1003: "版本冲突",
+	1004: "内容未通过审核",
 }
 
 type Response struct {
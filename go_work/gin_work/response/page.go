@@ -0,0 +1,16 @@
+package response
+
+import "github.com/gin-gonic/gin"
+
+// PageData 分页数据
+type PageData struct {
+	List       any    `json:"list"`
+	Total      int    `json:"total"`
+	Limit      int    `json:"limit"`
+	Page       int    `json:"page,omitempty"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+func OkWithPage(c *gin.Context, page PageData) {
+	OkWithData(c, page)
+}
@@ -2,12 +2,32 @@ package routers
 
 import (
 	"gin_work/controller"
+	"gin_work/models"
+	"gin_work/setting"
 	"gin_work/toolkit"
 	"github.com/gin-gonic/gin"
+	"log"
 )
 
 func SetupRouter() *gin.Engine {
 	r := gin.Default()
+	// 登录失败限制按客户端IP计数，只采用可信代理转发的 X-Forwarded-For
+	if err := r.SetTrustedProxies(setting.Conf.TrustedProxies); err != nil {
+		log.Printf("invalid trusted_proxies, trust none: %v", err)
+		_ = r.SetTrustedProxies(nil)
+	}
+
+	// 公开JWT验证公钥，供其他服务验证博客令牌
+	r.GET("/.well-known/jwks.json", controller.JWKSHandler)
+
+	// RSS/Atom订阅和站点地图
+	r.GET("/feed.rss", controller.RSSHandler)
+	r.GET("/feed.atom", controller.AtomHandler)
+	r.GET("/sitemap.xml", controller.SitemapHandler)
+	r.GET("/sitemaps/:file", controller.SitemapPageHandler)
+
+	// 关注的作者发布的博客时间线
+	r.GET("/feed", toolkit.TokenAuthMiddleware(), controller.FeedHandler)
 
 	// 用户路由
 	// 注册用户相关的注册、登录、注销的路由
@@ -17,22 +37,79 @@ func SetupRouter() *gin.Engine {
 		UserGroup.POST("/login", controller.UserLoginHandler)
 		// 用户注册的路由
 		UserGroup.POST("/register", controller.UserRegisterHandler)
+		// 刷新令牌的路由
+		UserGroup.POST("/refresh", controller.UserRefreshHandler)
+		// 用户注销的路由
+		UserGroup.POST("/logout", toolkit.TokenAuthMiddleware(), controller.UserLogoutHandler)
+		// 钱包登录(EIP-4361)的路由
+		UserGroup.GET("/siwe/nonce", controller.SiweNonceHandler)
+		UserGroup.POST("/siwe/login", controller.SiweLoginHandler)
+		// 邮箱验证的路由
+		UserGroup.GET("/verify", controller.VerifyEmailHandler)
+		UserGroup.POST("/verify/resend", toolkit.TokenAuthMiddleware(), controller.ResendVerifyEmailHandler)
+		// 忘记密码、重置密码的路由
+		UserGroup.POST("/password/forgot", controller.ForgotPasswordHandler)
+		UserGroup.POST("/password/reset", controller.ResetPasswordHandler)
+		// 两步验证的路由
+		UserGroup.POST("/mfa/verify", controller.MFAVerifyHandler)
+		UserGroup.POST("/mfa/enroll", toolkit.TokenAuthMiddleware(), controller.MFAEnrollHandler)
+		UserGroup.POST("/mfa/confirm", toolkit.TokenAuthMiddleware(), controller.MFAConfirmHandler)
+		UserGroup.POST("/mfa/disable", toolkit.TokenAuthMiddleware(), controller.MFADisableHandler)
+		UserGroup.POST("/mfa/recovery-codes", toolkit.TokenAuthMiddleware(), controller.MFARecoveryCodesHandler)
+		// 个人资料的路由
+		UserGroup.GET("/me", toolkit.TokenAuthMiddleware(), controller.GetMeHandler)
+		UserGroup.PATCH("/me", toolkit.TokenAuthMiddleware(), controller.UpdateMeHandler)
+		UserGroup.DELETE("/me", toolkit.TokenAuthMiddleware(), controller.DeleteMeHandler)
+		UserGroup.POST("/me/password", toolkit.TokenAuthMiddleware(), controller.ChangePasswordHandler)
+		UserGroup.GET("/me/bookmarks", toolkit.TokenAuthMiddleware(), controller.MyBookmarksHandler)
+		// 用户公开主页的路由
+		UserGroup.GET("/:name", controller.GetUserProfileHandler)
+		// 关注、取消关注和关注关系列表
+		UserGroup.POST("/:name/follow", toolkit.TokenAuthMiddleware(), controller.FollowUserHandler)
+		UserGroup.DELETE("/:name/follow", toolkit.TokenAuthMiddleware(), controller.UnfollowUserHandler)
+		UserGroup.GET("/:name/followers", controller.FollowersHandler)
+		UserGroup.GET("/:name/following", controller.FollowingHandler)
+		// 作者的RSS/Atom订阅
+		UserGroup.GET("/:name/feed.rss", controller.AuthorRSSHandler)
+		UserGroup.GET("/:name/feed.atom", controller.AuthorAtomHandler)
 	}
 	// 博客路由
 	BlogGroup := r.Group("blog").Use(toolkit.TokenAuthMiddleware())
 	{
 		// 新建博客的路由
-		BlogGroup.POST("/create", controller.CreateBlogHandler)
+		BlogGroup.POST("/create", toolkit.RequireRole(models.RoleAdmin, models.RoleAuthor), controller.CreateBlogHandler)
 		// 更新博客的路由
 		BlogGroup.POST("/update/id=:id", controller.UpdateBlogHandler)
 		// 删除博客的路由
 		BlogGroup.DELETE("/delete/id=:id", controller.DeleteBlogHandler)
+		// 回收站和恢复的路由
+		BlogGroup.GET("/trash", controller.TrashListHandler)
+		BlogGroup.POST("/restore/id=:id", controller.RestoreBlogHandler)
+		// 发布、取消发布、归档博客的路由
+		BlogGroup.POST("/publish/id=:id", controller.PublishBlogHandler)
+		BlogGroup.POST("/unpublish/id=:id", controller.UnpublishBlogHandler)
+		BlogGroup.POST("/archive/id=:id", controller.ArchiveBlogHandler)
+		// 历史版本、版本比较和恢复的路由
+		BlogGroup.GET("/revisions/id=:id", controller.ListRevisionsHandler)
+		BlogGroup.GET("/revisions/id=:id/diff", controller.DiffRevisionsHandler)
+		BlogGroup.GET("/revision/id=:id/version=:version", controller.GetRevisionHandler)
+		BlogGroup.POST("/revisions/id=:id/restore", controller.RestoreRevisionHandler)
 		// 查看所有博客的路由
 		BlogGroup.GET("/list", controller.GetAllBlogsHandler)
 		// 查看单个博客的路由
 		BlogGroup.GET("/list/id=:id", controller.GetBlogByIdHandler)
 		// 博客关键词搜索
 		BlogGroup.GET("/search/query=:query", controller.SearchBlogsHandler)
+		// 点赞、收藏的路由
+		BlogGroup.POST("/like/id=:id", controller.LikeBlogHandler)
+		BlogGroup.DELETE("/like/id=:id", controller.UnlikeBlogHandler)
+		BlogGroup.POST("/bookmark/id=:id", controller.BookmarkBlogHandler)
+		BlogGroup.DELETE("/bookmark/id=:id", controller.UnbookmarkBlogHandler)
+		// 热门博客
+		BlogGroup.GET("/hot", controller.HotBlogsHandler)
+		// 标签云和分类树
+		BlogGroup.GET("/tags", controller.TagCloudHandler)
+		BlogGroup.GET("/categories", controller.CategoryTreeHandler)
 	}
 
 	// 评论路由
@@ -46,6 +123,55 @@ func SetupRouter() *gin.Engine {
 		CommentGroup.GET("/list/id=:id", controller.CommentGetHandler)
 		// 删除指定的评论
 		CommentGroup.DELETE("/delete/id=:id", controller.CommentDeleteHandler)
+		// 修改评论的路由
+		CommentGroup.POST("/update/id=:id", controller.CommentEditHandler)
+		// 评论审核的路由
+		CommentGroup.GET("/pending", controller.CommentPendingHandler)
+		CommentGroup.POST("/approve/id=:id", controller.CommentApproveHandler)
+		CommentGroup.POST("/reject/id=:id", controller.CommentRejectHandler)
+	}
+
+	// 上传路由
+	UploadGroup := r.Group("upload").Use(toolkit.TokenAuthMiddleware())
+	{
+		UploadGroup.POST("", toolkit.RequireRole(models.RoleAdmin, models.RoleAuthor), controller.UploadHandler)
+		UploadGroup.GET("/list", controller.UploadListHandler)
+		UploadGroup.DELETE("/delete/id=:id", controller.UploadDeleteHandler)
+	}
+	// 附件和缩略图的访问地址，博客内容中通过该地址引用
+	r.GET("/attachment/*key", controller.AttachmentHandler)
+
+	// 通知路由
+	NotificationGroup := r.Group("notification")
+	{
+		// 实时推送，EventSource 先换取一次性票据，再通过 ticket 参数传递
+		NotificationGroup.POST("/stream/ticket", toolkit.TokenAuthMiddleware(), controller.NotificationTicketHandler)
+		NotificationGroup.GET("/stream", toolkit.StreamAuthMiddleware(), controller.NotificationStreamHandler)
+		NotificationGroup.GET("/list", toolkit.TokenAuthMiddleware(), controller.NotificationListHandler)
+		NotificationGroup.GET("/unread", toolkit.TokenAuthMiddleware(), controller.NotificationUnreadHandler)
+		NotificationGroup.POST("/read/id=:id", toolkit.TokenAuthMiddleware(), controller.NotificationReadHandler)
+		NotificationGroup.POST("/read-all", toolkit.TokenAuthMiddleware(), controller.NotificationReadAllHandler)
+	}
+
+	// 管理员路由
+	AdminGroup := r.Group("admin").Use(toolkit.TokenAuthMiddleware(), toolkit.RequireRole(models.RoleAdmin))
+	{
+		// 修改用户角色的路由
+		AdminGroup.POST("/user/role", controller.SetUserRoleHandler)
+		// 标签重命名与合并
+		AdminGroup.POST("/tag/rename", controller.RenameTagHandler)
+		AdminGroup.POST("/tag/merge", controller.MergeTagsHandler)
+		// 分类管理
+		AdminGroup.POST("/category", controller.CreateCategoryHandler)
+		AdminGroup.PATCH("/category/id=:id", controller.UpdateCategoryHandler)
+		AdminGroup.DELETE("/category/id=:id", controller.DeleteCategoryHandler)
+		// 导入导出
+		AdminGroup.GET("/export", controller.ExportHandler)
+		AdminGroup.POST("/import", controller.ImportHandler)
+		// 内容审核队列
+		AdminGroup.GET("/review", controller.ReviewListHandler)
+		AdminGroup.POST("/review/approve/id=:id", controller.ReviewApproveHandler)
+		AdminGroup.POST("/review/reject/id=:id", controller.ReviewRejectHandler)
 	}
 	return r
 }
@@ -0,0 +1,95 @@
+package search
+
+import (
+	"html"
+	"sort"
+	"strings"
+)
+
+// 摘要长度（rune）
+const snippetLen = 120
+
+// Highlight 转义整段文本并用<em>标记匹配的词
+func Highlight(text string, terms []string) string {
+	runes := []rune(text)
+	return render(runes, matchRanges(runes, terms), 0, len(runes))
+}
+
+// Snippet 截取第一个匹配附近的一段文本并高亮，没有匹配时返回开头
+func Snippet(text string, terms []string) string {
+	runes := []rune(text)
+	ranges := matchRanges(runes, terms)
+	start := 0
+	if len(ranges) > 0 {
+		start = ranges[0][0] - snippetLen/4
+		if start < 0 {
+			start = 0
+		}
+	}
+	end := start + snippetLen
+	if end > len(runes) {
+		end = len(runes)
+		if start = end - snippetLen; start < 0 {
+			start = 0
+		}
+	}
+	s := render(runes, ranges, start, end)
+	if start > 0 {
+		s = "…" + s
+	}
+	if end < len(runes) {
+		s += "…"
+	}
+	return s
+}
+
+// 找出所有匹配查询词的区间，并合并重叠部分
+func matchRanges(runes []rune, terms []string) [][2]int {
+	want := map[string]bool{}
+	for _, t := range terms {
+		want[t] = true
+	}
+	var ranges [][2]int
+	for _, tok := range Tokenize(string(runes)) {
+		if want[tok.Term] {
+			ranges = append(ranges, [2]int{tok.Start, tok.End})
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+	var merged [][2]int
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && r[0] <= merged[n-1][1] {
+			if r[1] > merged[n-1][1] {
+				merged[n-1][1] = r[1]
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// 输出 runes[start:end]，匹配区间用<em>包裹
+func render(runes []rune, ranges [][2]int, start, end int) string {
+	var b strings.Builder
+	pos := start
+	for _, r := range ranges {
+		s, e := r[0], r[1]
+		if e <= start || s >= end {
+			continue
+		}
+		if s < start {
+			s = start
+		}
+		if e > end {
+			e = end
+		}
+		b.WriteString(html.EscapeString(string(runes[pos:s])))
+		b.WriteString("<em>")
+		b.WriteString(html.EscapeString(string(runes[s:e])))
+		b.WriteString("</em>")
+		pos = e
+	}
+	b.WriteString(html.EscapeString(string(runes[pos:end])))
+	return b.String()
+}
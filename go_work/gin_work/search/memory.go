@@ -0,0 +1,151 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// BM25 参数
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+	// 标题中的词按多次出现计算，提高标题匹配的权重
+	titleBoost = 3
+)
+
+// MemoryEngine 进程内的倒排索引，使用BM25排序
+type MemoryEngine struct {
+	mu       sync.RWMutex
+	postings map[string]map[int]int // 词 -> 文档ID -> 词频
+	docLen   map[int]int
+	docs     map[int]Document
+	totalLen int
+}
+
+func NewMemoryEngine() *MemoryEngine {
+	return &MemoryEngine{
+		postings: map[string]map[int]int{},
+		docLen:   map[int]int{},
+		docs:     map[int]Document{},
+	}
+}
+
+func (e *MemoryEngine) Index(doc Document) error {
+	freq := map[string]int{}
+	length := 0
+	for _, t := range Tokenize(doc.Title) {
+		freq[t.Term] += titleBoost
+		length += titleBoost
+	}
+	for _, t := range Tokenize(doc.Content) {
+		freq[t.Term]++
+		length++
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.remove(doc.ID)
+	for term, tf := range freq {
+		p, ok := e.postings[term]
+		if !ok {
+			p = map[int]int{}
+			e.postings[term] = p
+		}
+		p[doc.ID] = tf
+	}
+	e.docLen[doc.ID] = length
+	e.totalLen += length
+	e.docs[doc.ID] = doc
+	return nil
+}
+
+func (e *MemoryEngine) Delete(id int) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.remove(id)
+	return nil
+}
+
+// 删除文档的所有倒排记录，需要持有写锁
+func (e *MemoryEngine) remove(id int) {
+	doc, ok := e.docs[id]
+	if !ok {
+		return
+	}
+	for _, text := range []string{doc.Title, doc.Content} {
+		for _, t := range Tokenize(text) {
+			if p, ok := e.postings[t.Term]; ok {
+				delete(p, id)
+				if len(p) == 0 {
+					delete(e.postings, t.Term)
+				}
+			}
+		}
+	}
+	e.totalLen -= e.docLen[id]
+	delete(e.docLen, id)
+	delete(e.docs, id)
+}
+
+func (e *MemoryEngine) Search(q Query) (*Result, error) {
+	terms := queryTerms(q.Text)
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	n := float64(len(e.docs))
+	if n == 0 || len(terms) == 0 {
+		return &Result{}, nil
+	}
+	avgLen := float64(e.totalLen) / n
+	scores := map[int]float64{}
+	for _, term := range terms {
+		p := e.postings[term]
+		if len(p) == 0 {
+			continue
+		}
+		df := float64(len(p))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for id, tf := range p {
+			if !e.docs[id].visibleTo(q.ViewerID) {
+				continue
+			}
+			f := float64(tf)
+			dl := float64(e.docLen[id])
+			scores[id] += idf * f * (bm25K1 + 1) / (f + bm25K1*(1-bm25B+bm25B*dl/avgLen))
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, Hit{ID: id, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID > hits[j].ID
+	})
+
+	result := &Result{Total: len(hits)}
+	hits = paginate(hits, q.Offset, q.Limit)
+	for i := range hits {
+		doc := e.docs[hits[i].ID]
+		hits[i].Title = Highlight(doc.Title, terms)
+		hits[i].Snippet = Snippet(doc.Content, terms)
+	}
+	result.Hits = hits
+	return result, nil
+}
+
+// 截取一页
+func paginate(hits []Hit, offset, limit int) []Hit {
+	if offset >= len(hits) {
+		return nil
+	}
+	hits = hits[offset:]
+	if limit > 0 && limit < len(hits) {
+		hits = hits[:limit]
+	}
+	return hits
+}
@@ -0,0 +1,165 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+// 按顺序返回结果中的文档ID
+func hitIDs(t *testing.T, e *MemoryEngine, q Query) []int {
+	t.Helper()
+	res, err := e.Search(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []int{}
+	for _, h := range res.Hits {
+		ids = append(ids, h.ID)
+	}
+	return ids
+}
+
+func indexAll(t *testing.T, e *MemoryEngine, docs ...Document) {
+	t.Helper()
+	for _, doc := range docs {
+		if err := e.Index(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMemoryEngineRanking(t *testing.T) {
+	tests := []struct {
+		name  string
+		docs  []Document
+		query string
+		want  []int
+	}{
+		{"term frequency", []Document{
+			{ID: 1, Content: "golang once among many other words", Public: true},
+			{ID: 2, Content: "golang golang golang among other words", Public: true},
+			{ID: 3, Content: "rust only", Public: true},
+		}, "golang", []int{2, 1}},
+		// 词频相同时短文档排在前面
+		{"document length", []Document{
+			{ID: 1, Content: "golang tips", Public: true},
+			{ID: 2, Content: "golang tips and a very long list of unrelated words to dilute it", Public: true},
+		}, "golang", []int{1, 2}},
+		{"title boost", []Document{
+			{ID: 1, Title: "notes", Content: "golang some words here", Public: true},
+			{ID: 2, Title: "golang", Content: "notes some words here", Public: true},
+		}, "golang", []int{2, 1}},
+		// 罕见词的权重更高
+		{"idf", []Document{
+			{ID: 1, Content: "common common", Public: true},
+			{ID: 2, Content: "common rare", Public: true},
+			{ID: 3, Content: "common words", Public: true},
+			{ID: 4, Content: "common things", Public: true},
+		}, "common rare", []int{2, 1, 4, 3}},
+		// 相关度相同时新文档在前
+		{"tie", []Document{
+			{ID: 1, Content: "same text", Public: true},
+			{ID: 2, Content: "same text", Public: true},
+		}, "same", []int{2, 1}},
+		{"chinese bigram", []Document{
+			{ID: 1, Content: "数据和库存", Public: true},
+			{ID: 2, Content: "数据库索引", Public: true},
+		}, "数据库", []int{2, 1}},
+		{"no match", []Document{{ID: 1, Content: "hello", Public: true}}, "world", []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewMemoryEngine()
+			indexAll(t, e, tt.docs...)
+			if got := hitIDs(t, e, Query{Text: tt.query}); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+// 未公开的文档只有作者能搜到
+func TestMemoryEngineVisibility(t *testing.T) {
+	e := NewMemoryEngine()
+	indexAll(t, e,
+		Document{ID: 1, Content: "draft golang", AuthorID: 7},
+		Document{ID: 2, Content: "public golang", AuthorID: 8, Public: true},
+	)
+	tests := []struct {
+		viewer int
+		want   []int
+	}{
+		{0, []int{2}},
+		{8, []int{2}},
+		{7, []int{2, 1}},
+	}
+	for _, tt := range tests {
+		if got := hitIDs(t, e, Query{Text: "golang", ViewerID: tt.viewer}); !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("viewer %d = %v, want %v", tt.viewer, got, tt.want)
+		}
+	}
+}
+
+func TestMemoryEnginePaginate(t *testing.T) {
+	e := NewMemoryEngine()
+	for id := 1; id <= 5; id++ {
+		indexAll(t, e, Document{ID: id, Content: "page", Public: true})
+	}
+	res, err := e.Search(Query{Text: "page", Offset: 1, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 5 || len(res.Hits) != 2 || res.Hits[0].ID != 4 || res.Hits[1].ID != 3 {
+		t.Fatalf("page = %+v", res)
+	}
+	if got := hitIDs(t, e, Query{Text: "page", Offset: 5}); len(got) != 0 {
+		t.Fatalf("offset past end = %v", got)
+	}
+}
+
+// 更新和删除文档后，旧内容不能再被搜到，索引统计同步更新
+func TestMemoryEngineUpdateDelete(t *testing.T) {
+	e := NewMemoryEngine()
+	indexAll(t, e,
+		Document{ID: 1, Title: "Alpha", Content: "first version", Public: true},
+		Document{ID: 2, Title: "Other", Content: "unrelated", Public: true},
+	)
+	if got := hitIDs(t, e, Query{Text: "alpha"}); !reflect.DeepEqual(got, []int{1}) {
+		t.Fatalf("before update = %v", got)
+	}
+
+	indexAll(t, e, Document{ID: 1, Title: "Beta", Content: "second 版本", Public: true})
+	for _, q := range []string{"alpha", "first"} {
+		if got := hitIDs(t, e, Query{Text: q}); len(got) != 0 {
+			t.Fatalf("%q still matches after update: %v", q, got)
+		}
+	}
+	for _, q := range []string{"beta", "版本"} {
+		if got := hitIDs(t, e, Query{Text: q}); !reflect.DeepEqual(got, []int{1}) {
+			t.Fatalf("%q after update = %v", q, got)
+		}
+	}
+	if _, ok := e.postings["alpha"]; ok {
+		t.Fatal("stale posting for alpha")
+	}
+	// 标题按 titleBoost 计入长度
+	if want := titleBoost*2 + len(Tokenize("unrelated")) + len(Tokenize("second 版本")); e.totalLen != want {
+		t.Fatalf("totalLen = %d, want %d", e.totalLen, want)
+	}
+
+	if err := e.Delete(1); err != nil {
+		t.Fatal(err)
+	}
+	if got := hitIDs(t, e, Query{Text: "beta"}); len(got) != 0 {
+		t.Fatalf("deleted doc found: %v", got)
+	}
+	if err := e.Delete(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Delete(2); err != nil {
+		t.Fatal(err)
+	}
+	if len(e.postings) != 0 || len(e.docs) != 0 || e.totalLen != 0 {
+		t.Fatalf("index not empty: %d postings, %d docs, totalLen %d", len(e.postings), len(e.docs), e.totalLen)
+	}
+}
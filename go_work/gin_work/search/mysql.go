@@ -0,0 +1,77 @@
+package search
+
+import (
+	"errors"
+	"gin_work/dao"
+)
+
+// MySQLEngine 使用MySQL的FULLTEXT索引和ngram分词器，索引由数据库维护
+type MySQLEngine struct{}
+
+// 全文索引名称
+const fulltextIndex = "ft_blogs_title_content"
+
+// NewMySQLEngine 创建引擎，索引不存在时自动创建
+func NewMySQLEngine() (*MySQLEngine, error) {
+	var count int
+	err := dao.DB.Raw("SELECT COUNT(*) FROM information_schema.statistics "+
+		"WHERE table_schema = DATABASE() AND table_name = 'blogs' AND index_name = ?", fulltextIndex).Row().Scan(&count)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		err = dao.DB.Exec("ALTER TABLE blogs ADD FULLTEXT INDEX " + fulltextIndex + " (title, content) WITH PARSER ngram").Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &MySQLEngine{}, nil
+}
+
+// Index 数据库自动维护索引
+func (MySQLEngine) Index(doc Document) error { return nil }
+
+// Delete 数据库自动维护索引
+func (MySQLEngine) Delete(id int) error { return nil }
+
+func (MySQLEngine) Search(q Query) (*Result, error) {
+	terms := queryTerms(q.Text)
+	if len(terms) == 0 {
+		return &Result{}, nil
+	}
+	// 未发布的博客只有作者本人能搜到，回收站中的博客不返回
+	const match = "MATCH(title, content) AGAINST (? IN NATURAL LANGUAGE MODE) AND (status = 'published' OR user_id = ?) AND deleted_at IS NULL"
+	viewer := q.ViewerID
+	if viewer <= 0 {
+		// 未登录时不匹配任何作者
+		viewer = -1
+	}
+	result := &Result{}
+	err := dao.DB.Raw("SELECT COUNT(*) FROM blogs WHERE "+match, q.Text, viewer).Row().Scan(&result.Total)
+	if err != nil {
+		return nil, errors.New("search blog error")
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = result.Total
+	}
+	rows, err := dao.DB.Raw("SELECT blog_id, title, content, MATCH(title, content) AGAINST (? IN NATURAL LANGUAGE MODE) AS score "+
+		"FROM blogs WHERE "+match+" ORDER BY score DESC, blog_id DESC LIMIT ? OFFSET ?",
+		q.Text, q.Text, viewer, limit, q.Offset).Rows()
+	if err != nil {
+		return nil, errors.New("search blog error")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var doc Document
+		var hit Hit
+		if err = rows.Scan(&doc.ID, &doc.Title, &doc.Content, &hit.Score); err != nil {
+			return nil, errors.New("search blog error")
+		}
+		hit.ID = doc.ID
+		hit.Title = Highlight(doc.Title, terms)
+		hit.Snippet = Snippet(doc.Content, terms)
+		result.Hits = append(result.Hits, hit)
+	}
+	return result, rows.Err()
+}
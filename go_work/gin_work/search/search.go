@@ -0,0 +1,85 @@
+package search
+
+import (
+	"fmt"
+	"gin_work/setting"
+)
+
+// Document 被索引的博客
+type Document struct {
+	ID      int
+	Title   string
+	Content string
+	// AuthorID 作者ID，未公开的文档只有作者能搜到
+	AuthorID int
+	// Public 是否对所有人公开
+	Public bool
+}
+
+// 文档对搜索者是否可见
+func (d Document) visibleTo(viewerID int) bool {
+	return d.Public || (viewerID > 0 && d.AuthorID == viewerID)
+}
+
+// Query 搜索条件
+type Query struct {
+	Text string
+	// ViewerID 搜索者的用户ID，未登录为0
+	ViewerID int
+	Offset   int
+	Limit    int
+}
+
+// Hit 一条搜索结果，高亮部分已经做过HTML转义，匹配的词用<em>包裹
+type Hit struct {
+	ID      int     `json:"id"`
+	Score   float64 `json:"score"`
+	Title   string  `json:"title"`
+	Snippet string  `json:"snippet"`
+}
+
+// Result 一页搜索结果
+type Result struct {
+	Hits  []Hit
+	Total int
+}
+
+// Engine 搜索引擎接口
+type Engine interface {
+	// Index 新增或更新文档
+	Index(doc Document) error
+	// Delete 删除文档
+	Delete(id int) error
+	// Search 按相关度排序搜索
+	Search(q Query) (*Result, error)
+}
+
+// Default 全局使用的搜索引擎
+var Default Engine = NewMemoryEngine()
+
+// Init 根据配置选择搜索引擎
+func Init(cfg *setting.SearchConfig) error {
+	engine := ""
+	if cfg != nil {
+		engine = cfg.Engine
+	}
+	switch engine {
+	case "", "memory":
+		Default = NewMemoryEngine()
+	case "mysql":
+		e, err := NewMySQLEngine()
+		if err != nil {
+			return err
+		}
+		Default = e
+	default:
+		return fmt.Errorf("unsupported search engine %q", engine)
+	}
+	return nil
+}
+
+// NeedsRebuild 进程内索引在启动时需要从数据库重建
+func NeedsRebuild() bool {
+	_, ok := Default.(*MemoryEngine)
+	return ok
+}
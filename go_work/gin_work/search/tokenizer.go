@@ -0,0 +1,94 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// 过长的英文单词截断
+const maxTermLen = 64
+
+// Token 一个词及其在原文中的位置（按rune计算，左闭右开）
+type Token struct {
+	Term  string
+	Start int
+	End   int
+}
+
+// 中日韩文字没有空格分词，按字切分
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// Tokenize 对文档分词
+// 英文和数字按单词切分并转为小写；中日韩文字同时输出单字和相邻两字(bigram)，
+// 单字保证单字查询能够命中，bigram 提高多字查询的准确度
+func Tokenize(text string) []Token {
+	return tokenize(text, true)
+}
+
+// TokenizeQuery 对查询分词，连续两个以上的中文只使用bigram
+func TokenizeQuery(text string) []Token {
+	return tokenize(text, false)
+}
+
+func tokenize(text string, withUnigrams bool) []Token {
+	runes := []rune(text)
+	var tokens []Token
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case isCJK(r):
+			j := i
+			for j < len(runes) && isCJK(runes[j]) {
+				j++
+			}
+			tokens = appendCJK(tokens, runes, i, j, withUnigrams)
+			i = j
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			j := i
+			for j < len(runes) && !isCJK(runes[j]) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j])) {
+				j++
+			}
+			end := j
+			if end-i > maxTermLen {
+				end = i + maxTermLen
+			}
+			tokens = append(tokens, Token{Term: strings.ToLower(string(runes[i:end])), Start: i, End: j})
+			i = j
+		default:
+			i++
+		}
+	}
+	return tokens
+}
+
+// 切分一段连续的中日韩文字 runes[start:end]
+func appendCJK(tokens []Token, runes []rune, start, end int, withUnigrams bool) []Token {
+	if end-start == 1 {
+		return append(tokens, Token{Term: string(runes[start]), Start: start, End: end})
+	}
+	for k := start; k < end; k++ {
+		if withUnigrams {
+			tokens = append(tokens, Token{Term: string(runes[k]), Start: k, End: k + 1})
+		}
+		if k+1 < end {
+			tokens = append(tokens, Token{Term: string(runes[k : k+2]), Start: k, End: k + 2})
+		}
+	}
+	return tokens
+}
+
+// 查询中去重后的词
+func queryTerms(text string) []string {
+	seen := map[string]bool{}
+	var terms []string
+	for _, t := range TokenizeQuery(text) {
+		if !seen[t.Term] {
+			seen[t.Term] = true
+			terms = append(terms, t.Term)
+		}
+	}
+	return terms
+}
@@ -0,0 +1,65 @@
+package search
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []Token
+	}{
+		{"latin", "Hello, World 42", []Token{{"hello", 0, 5}, {"world", 7, 12}, {"42", 13, 15}}},
+		{"mixed", "Go语言 v1.22", []Token{
+			{"go", 0, 2}, {"语", 2, 3}, {"语言", 2, 4}, {"言", 3, 4}, {"v1", 5, 7}, {"22", 8, 10},
+		}},
+		// 中文和英文之间没有空格也要切开
+		{"adjacent", "用Gin写API", []Token{
+			{"用", 0, 1}, {"gin", 1, 4}, {"写", 4, 5}, {"api", 5, 8},
+		}},
+		{"three han", "数据库", []Token{
+			{"数", 0, 1}, {"数据", 0, 2}, {"据", 1, 2}, {"据库", 1, 3}, {"库", 2, 3},
+		}},
+		{"kana and hangul", "カナ 한국", []Token{
+			{"カ", 0, 1}, {"カナ", 0, 2}, {"ナ", 1, 2}, {"한", 3, 4}, {"한국", 3, 5}, {"국", 4, 5},
+		}},
+		{"punctuation only", "，。!? ", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Tokenize(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Tokenize(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+// 查询中连续的中文只使用bigram，单个中文仍然保留
+func TestTokenizeQuery(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Go语言", []string{"go", "语言"}},
+		{"数据库 索引", []string{"数据", "据库", "索引"}},
+		{"学 Go", []string{"学", "go"}},
+		{"go Go GO 语言语言", []string{"go", "语言", "言语"}},
+	}
+	for _, tt := range tests {
+		if got := queryTerms(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("queryTerms(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTokenizeLongWord(t *testing.T) {
+	word := strings.Repeat("a", maxTermLen+10)
+	got := Tokenize(word + " b")
+	want := []Token{{strings.Repeat("a", maxTermLen), 0, maxTermLen + 10}, {"b", maxTermLen + 11, maxTermLen + 12}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Tokenize = %v, want %v", got, want)
+	}
+}
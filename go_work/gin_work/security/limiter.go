@@ -0,0 +1,209 @@
+package security
+
+import (
+	"fmt"
+	"gin_work/setting"
+	"sync"
+	"time"
+)
+
+// Store 登录失败计数的存储，多个实例部署时需要使用共享的存储
+type Store interface {
+	// Fail 记录一次失败，返回窗口内累计的失败次数
+	Fail(key string, now time.Time, window time.Duration) (int, error)
+	// Lock 锁定key直到指定时间
+	Lock(key string, until time.Time) error
+	// LockedUntil 返回key的锁定截止时间
+	LockedUntil(key string) (time.Time, error)
+	// Reset 清除key的计数和锁定
+	Reset(key string) error
+}
+
+// Policy 锁定策略
+type Policy struct {
+	// MaxFailures 窗口内允许的失败次数，超过后开始锁定
+	MaxFailures int
+	// Lockout 第一次锁定的时长，之后每多失败一次翻倍
+	Lockout time.Duration
+	// MaxLockout 锁定时长的上限
+	MaxLockout time.Duration
+	// Window 失败计数的统计窗口
+	Window time.Duration
+}
+
+// 第n次失败后的锁定时长，未超过阈值时为0
+func (p Policy) lockout(failures int) time.Duration {
+	if failures < p.MaxFailures {
+		return 0
+	}
+	d := p.Lockout
+	for i := p.MaxFailures; i < failures && d < p.MaxLockout; i++ {
+		d *= 2
+	}
+	if d > p.MaxLockout {
+		d = p.MaxLockout
+	}
+	return d
+}
+
+// Guard 按账号和IP分别统计登录失败
+type Guard struct {
+	Store      Store
+	UserPolicy Policy
+	IPPolicy   Policy
+}
+
+// Default 全局使用的登录保护
+var Default = NewGuard(NewMemoryStore(), nil)
+
+// NewGuard 根据配置创建，cfg为nil时使用默认策略
+func NewGuard(store Store, cfg *setting.LoginConfig) *Guard {
+	g := &Guard{
+		Store:      store,
+		UserPolicy: Policy{MaxFailures: 5, Lockout: time.Minute, MaxLockout: time.Hour, Window: 24 * time.Hour},
+		IPPolicy:   Policy{MaxFailures: 20, Lockout: time.Minute, MaxLockout: time.Hour, Window: 24 * time.Hour},
+	}
+	if cfg != nil {
+		if cfg.MaxFailures > 0 {
+			g.UserPolicy.MaxFailures = cfg.MaxFailures
+		}
+		if cfg.IPMaxFailures > 0 {
+			g.IPPolicy.MaxFailures = cfg.IPMaxFailures
+		}
+		if cfg.Lockout > 0 {
+			g.UserPolicy.Lockout = cfg.Lockout
+			g.IPPolicy.Lockout = cfg.Lockout
+		}
+		if cfg.MaxLockout > 0 {
+			g.UserPolicy.MaxLockout = cfg.MaxLockout
+			g.IPPolicy.MaxLockout = cfg.MaxLockout
+		}
+		if cfg.Window > 0 {
+			g.UserPolicy.Window = cfg.Window
+			g.IPPolicy.Window = cfg.Window
+		}
+	}
+	return g
+}
+
+func userKey(userName string) string { return "user:" + userName }
+func ipKey(ip string) string         { return "ip:" + ip }
+
+// Check 账号或IP被锁定时返回剩余的等待时间
+func (g *Guard) Check(userName, ip string) (time.Duration, error) {
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range []string{userKey(userName), ipKey(ip)} {
+		until, err := g.Store.LockedUntil(key)
+		if err != nil {
+			return 0, err
+		}
+		if d := until.Sub(now); d > wait {
+			wait = d
+		}
+	}
+	if wait > 0 {
+		return wait, fmt.Errorf("too many failed attempts, retry after %d seconds", int(wait.Seconds())+1)
+	}
+	return 0, nil
+}
+
+// Fail 记录一次失败，达到阈值后按指数退避锁定
+func (g *Guard) Fail(userName, ip string) error {
+	now := time.Now()
+	for _, item := range []struct {
+		key    string
+		policy Policy
+	}{{userKey(userName), g.UserPolicy}, {ipKey(ip), g.IPPolicy}} {
+		failures, err := g.Store.Fail(item.key, now, item.policy.Window)
+		if err != nil {
+			return err
+		}
+		if d := item.policy.lockout(failures); d > 0 {
+			if err = g.Store.Lock(item.key, now.Add(d)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Success 登录成功后清除账号的计数
+// IP的计数不清除，避免攻击者用自己的账号登录来重置计数
+func (g *Guard) Success(userName string) error {
+	return g.Store.Reset(userKey(userName))
+}
+
+// MemoryStore 进程内的存储，只适用于单实例部署
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+	cleaned time.Time
+}
+
+type memoryEntry struct {
+	failures     int
+	firstFailure time.Time
+	lockedUntil  time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]*memoryEntry{}}
+}
+
+func (s *MemoryStore) Fail(key string, now time.Time, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cleanup(now, window)
+	e, ok := s.entries[key]
+	if !ok {
+		e = &memoryEntry{}
+		s.entries[key] = e
+	}
+	if e.failures == 0 || now.Sub(e.firstFailure) > window {
+		e.failures = 0
+		e.firstFailure = now
+	}
+	e.failures++
+	return e.failures, nil
+}
+
+func (s *MemoryStore) Lock(key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok {
+		e.lockedUntil = until
+	} else {
+		s.entries[key] = &memoryEntry{lockedUntil: until}
+	}
+	return nil
+}
+
+func (s *MemoryStore) LockedUntil(key string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok {
+		return e.lockedUntil, nil
+	}
+	return time.Time{}, nil
+}
+
+func (s *MemoryStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// 每分钟清理一次过期的记录，需要持有锁
+func (s *MemoryStore) cleanup(now time.Time, window time.Duration) {
+	if now.Sub(s.cleaned) < time.Minute {
+		return
+	}
+	s.cleaned = now
+	for key, e := range s.entries {
+		if now.Sub(e.firstFailure) > window && now.After(e.lockedUntil) {
+			delete(s.entries, key)
+		}
+	}
+}
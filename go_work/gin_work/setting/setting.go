@@ -1,14 +1,31 @@
 package setting
 
-import "gopkg.in/ini.v1"
+import (
+	"gopkg.in/ini.v1"
+	"time"
+)
 
 var Conf = new(AppConfig)
 
 // AppConfig 应用程序配置
 type AppConfig struct {
-	Release      bool `ini:"release"`
-	Port         int  `ini:"port"`
-	*MySQLConfig `ini:"mysql"`
+	Release bool `ini:"release"`
+	Port    int  `ini:"port"`
+	// TrustedProxies 可信的反向代理地址或网段，只有来自这些地址的 X-Forwarded-For 才会被采用
+	TrustedProxies    []string `ini:"trusted_proxies" delim:","`
+	*MySQLConfig      `ini:"mysql"`
+	*JWTConfig        `ini:"jwt"`
+	*SiweConfig       `ini:"siwe"`
+	*MailConfig       `ini:"mail"`
+	*LoginConfig      `ini:"login"`
+	*SearchConfig     `ini:"search"`
+	*CommentConfig    `ini:"comment"`
+	*FeedConfig       `ini:"feed"`
+	*StorageConfig    `ini:"storage"`
+	*SiteConfig       `ini:"site"`
+	*TrashConfig      `ini:"trash"`
+	*ModerationConfig `ini:"moderation"`
+	*AdminConfig      `ini:"admin"`
 }
 
 // MySQLConfig MySQL配置
@@ -20,6 +37,146 @@ type MySQLConfig struct {
 	Port     int    `ini:"port"`
 }
 
+// JWTConfig JWT签名配置
+type JWTConfig struct {
+	// Algorithm 签名算法: HS256、RS256、EdDSA
+	Algorithm string `ini:"algorithm"`
+	// KeyID 当前签名密钥的kid
+	KeyID string `ini:"kid"`
+	// Secret HS256使用的密钥
+	Secret string `ini:"secret"`
+	// PrivateKeyFile RS256/EdDSA使用的PEM私钥文件
+	PrivateKeyFile string `ini:"private_key_file"`
+	// VerifyKeys 轮换期间仍然有效的公钥，格式为 kid:PEM文件路径
+	VerifyKeys []string `ini:"verify_keys" delim:","`
+	// VerifySecrets 轮换期间仍然有效的HS256密钥，格式为 kid:密钥
+	VerifySecrets []string `ini:"verify_secrets" delim:","`
+}
+
+// SiweConfig 以太坊钱包登录(EIP-4361)配置
+type SiweConfig struct {
+	// Domain 消息中必须出现的域名
+	Domain string `ini:"domain"`
+	// ChainID 允许的链ID，0表示不限制
+	ChainID int64 `ini:"chain_id"`
+}
+
+// MailConfig 邮件配置
+type MailConfig struct {
+	// Driver 发送方式: log、file、smtp
+	Driver string `ini:"driver"`
+	// Dir file方式保存邮件的目录
+	Dir          string `ini:"dir"`
+	SMTPHost     string `ini:"smtp_host"`
+	SMTPPort     int    `ini:"smtp_port"`
+	SMTPUser     string `ini:"smtp_user"`
+	SMTPPassword string `ini:"smtp_password"`
+	From         string `ini:"from"`
+	// BaseURL 邮件中链接使用的服务地址
+	BaseURL string `ini:"base_url"`
+}
+
+// LoginConfig 登录失败限制配置
+type LoginConfig struct {
+	// Store 计数存储: memory 单实例、db 多实例共享
+	Store string `ini:"store"`
+	// MaxFailures 每个账号允许的连续失败次数
+	MaxFailures int `ini:"max_failures"`
+	// IPMaxFailures 每个IP允许的失败次数
+	IPMaxFailures int `ini:"ip_max_failures"`
+	// Lockout 第一次锁定时长，之后逐次翻倍
+	Lockout time.Duration `ini:"lockout"`
+	// MaxLockout 最长锁定时长
+	MaxLockout time.Duration `ini:"max_lockout"`
+	// Window 失败计数的统计窗口
+	Window time.Duration `ini:"window"`
+}
+
+// SearchConfig 博客搜索配置
+type SearchConfig struct {
+	// Engine 搜索引擎: memory 进程内倒排索引、mysql FULLTEXT(ngram)索引
+	Engine string `ini:"engine"`
+}
+
+// CommentConfig 评论配置
+type CommentConfig struct {
+	// EditWindow 评论发布后允许作者修改的时长
+	EditWindow time.Duration `ini:"edit_window"`
+	// ModerateComments 为true时，他人的评论需要博客作者审核通过后才会公开
+	// 作为默认设置，博客作者可以在个人资料中单独开启或关闭
+	ModerateComments bool `ini:"moderate"`
+}
+
+// FeedConfig 关注时间线配置
+type FeedConfig struct {
+	// FanoutOnWrite 是否为热门作者开启写扩散，关闭时时间线全部在读取时查询
+	FanoutOnWrite bool `ini:"fanout_on_write"`
+	// PopularFollowers 粉丝数达到该值的作者视为热门作者
+	PopularFollowers int `ini:"popular_followers"`
+	// RefreshInterval 重新统计热门作者的间隔
+	RefreshInterval time.Duration `ini:"refresh_interval"`
+}
+
+// StorageConfig 上传文件的存储配置
+type StorageConfig struct {
+	// Driver 存储方式: local 本地目录、s3 兼容S3协议的对象存储(如MinIO)
+	Driver string `ini:"driver"`
+	// Dir local方式保存文件的目录
+	Dir string `ini:"dir"`
+	// MaxUploadSize 单个文件的最大字节数
+	MaxUploadSize int64 `ini:"max_upload_size"`
+	// OrphanGrace 上传后多久仍未被博客引用的文件会被清理
+	OrphanGrace time.Duration `ini:"orphan_grace"`
+	S3Endpoint  string        `ini:"s3_endpoint"`
+	S3Region    string        `ini:"s3_region"`
+	S3Bucket    string        `ini:"s3_bucket"`
+	S3AccessKey string        `ini:"s3_access_key"`
+	S3SecretKey string        `ini:"s3_secret_key"`
+}
+
+// SiteConfig 站点信息，用于RSS/Atom订阅和站点地图
+type SiteConfig struct {
+	SiteTitle       string `ini:"title"`
+	SiteDescription string `ini:"description"`
+	// SiteURL 站点地址，为空时使用邮件配置中的 base_url
+	SiteURL string `ini:"url"`
+	// BlogPath 博客页面的路径格式，%d 为博客ID
+	BlogPath string `ini:"blog_path"`
+}
+
 func Init(file string) error {
 	return ini.MapTo(Conf, file)
 }
+
+// TrashConfig 博客回收站配置
+type TrashConfig struct {
+	// TrashRetention 博客在回收站中保留的时长，超过后彻底删除
+	TrashRetention time.Duration `ini:"retention"`
+	// PurgeInterval 检查回收站的间隔
+	PurgeInterval time.Duration `ini:"purge_interval"`
+}
+
+// ModerationConfig 博客和评论的内容审核配置
+type ModerationConfig struct {
+	ModerationEnabled bool `ini:"enabled"`
+	// WordFile 敏感词文件，每行一个词
+	WordFile string `ini:"word_file"`
+	// WordAction 敏感词文件中未指定处理方式时的默认处理: hold / reject
+	WordAction string `ini:"word_action"`
+	// 允许的链接数量，超过时进入人工审核，0表示不限制
+	BlogMaxLinks    int `ini:"blog_max_links"`
+	CommentMaxLinks int `ini:"comment_max_links"`
+	// BlockedDomains 包含这些域名的链接直接拒绝
+	BlockedDomains []string `ini:"blocked_domains" delim:","`
+	// RateWindow 统计发布频率的时间窗口
+	RateWindow time.Duration `ini:"rate_window"`
+	// 时间窗口内允许发布的数量，0表示不限制
+	BlogRate    int `ini:"blog_rate"`
+	CommentRate int `ini:"comment_rate"`
+}
+
+// AdminConfig 管理员配置
+type AdminConfig struct {
+	// BootstrapAdmin 还没有管理员时，启动时把该用户设为管理员，用于创建第一个管理员
+	BootstrapAdmin string `ini:"bootstrap"`
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalStore(t *testing.T) {
+	testBlobStore(t, &LocalStore{Dir: t.TempDir()})
+}
+
+func TestLocalStoreRejectsEscapingKeys(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "uploads")
+	store := &LocalStore{Dir: dir}
+	ctx := context.Background()
+	tests := []string{"", "/", "../secret.txt", "a/../../secret.txt", "a/..", "..\\secret.txt"}
+	for _, key := range tests {
+		if err := store.Put(ctx, key, strings.NewReader("x"), 1, ""); err == nil {
+			t.Errorf("Put(%q) succeeded", key)
+		}
+		if _, err := store.Get(ctx, key); err == nil || err == ErrNotFound {
+			t.Errorf("Get(%q): err = %v, want invalid key", key, err)
+		}
+		if err := store.Delete(ctx, key); err == nil {
+			t.Errorf("Delete(%q) succeeded", key)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "secret.txt")); !os.IsNotExist(err) {
+		t.Fatalf("file written outside the store: %v", err)
+	}
+}
+
+func TestLocalStoreLeavesNoTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	store := &LocalStore{Dir: dir}
+	if err := store.Put(context.Background(), "2024/01/a.txt", strings.NewReader("hello"), 5, ""); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "2024", "01"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "a.txt" {
+		t.Fatalf("unexpected files %v", entries)
+	}
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gin_work/setting"
+	"io"
+)
+
+// ErrNotFound 文件不存在
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore 上传文件的存储
+// key 由服务端生成，只包含字母、数字、"/"、"."
+type BlobStore interface {
+	// Put 保存文件，size 为-1时表示长度未知
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get 读取文件，不存在时返回 ErrNotFound
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete 删除文件，文件不存在时不报错
+	Delete(ctx context.Context, key string) error
+}
+
+// Default 默认的存储
+var Default BlobStore = &LocalStore{Dir: "./uploads"}
+
+// Init 根据配置选择存储
+func Init(cfg *setting.StorageConfig) error {
+	if cfg == nil {
+		return nil
+	}
+	switch cfg.Driver {
+	case "", "local":
+		if cfg.Dir != "" {
+			Default = &LocalStore{Dir: cfg.Dir}
+		}
+	case "s3":
+		if cfg.S3Endpoint == "" || cfg.S3Bucket == "" {
+			return errors.New("s3 endpoint and bucket are required")
+		}
+		Default = &S3Store{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+		}
+	default:
+		return fmt.Errorf("unsupported storage driver %q", cfg.Driver)
+	}
+	return nil
+}
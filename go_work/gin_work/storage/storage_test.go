@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+)
+
+// 所有 BlobStore 实现都应满足的行为
+func testBlobStore(t *testing.T, store BlobStore) {
+	t.Helper()
+	ctx := context.Background()
+	read := func(key string) ([]byte, error) {
+		rc, err := store.Get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		data []byte
+	}{
+		{"small file", "2024/01/abc.txt", []byte("hello")},
+		{"empty file", "2024/01/empty.txt", []byte{}},
+		{"binary file", "2024/02/thumb/abc.png", bytes.Repeat([]byte{0, 1, 2, 255}, 1<<14)},
+	}
+	for _, tt := range tests {
+		if err := store.Put(ctx, tt.key, bytes.NewReader(tt.data), int64(len(tt.data)), "application/octet-stream"); err != nil {
+			t.Fatalf("%s: Put: %v", tt.name, err)
+		}
+		got, err := read(tt.key)
+		if err != nil {
+			t.Fatalf("%s: Get: %v", tt.name, err)
+		}
+		if !bytes.Equal(got, tt.data) {
+			t.Fatalf("%s: Get returned %d bytes, want %d", tt.name, len(got), len(tt.data))
+		}
+	}
+
+	// 覆盖已有文件
+	if err := store.Put(ctx, "2024/01/abc.txt", bytes.NewReader([]byte("bye")), 3, "text/plain"); err != nil {
+		t.Fatalf("overwrite: %v", err)
+	}
+	if got, err := read("2024/01/abc.txt"); err != nil || string(got) != "bye" {
+		t.Fatalf("after overwrite got %q, %v", got, err)
+	}
+
+	if _, err := read("2024/01/missing.txt"); err != ErrNotFound {
+		t.Fatalf("Get missing: err = %v, want ErrNotFound", err)
+	}
+	for _, tt := range tests {
+		if err := store.Delete(ctx, tt.key); err != nil {
+			t.Fatalf("%s: Delete: %v", tt.name, err)
+		}
+		if _, err := read(tt.key); err != ErrNotFound {
+			t.Fatalf("%s: Get after delete: err = %v, want ErrNotFound", tt.name, err)
+		}
+	}
+	if err := store.Delete(ctx, "2024/01/missing.txt"); err != nil {
+		t.Fatalf("Delete missing: %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package toolkit
+
+import (
+	"gin_work/models"
+	"gin_work/response"
+	"github.com/gin-gonic/gin"
+)
+
+// 权限相关的错误码，见 response.codeMap
+const (
+	CodeForbidden = 1001 // 权限错误
+	CodeBadRole   = 1002 // 角色错误
+	// 博客已被其他人修改，需要重新读取后再提交
+	CodeVersionConflict = 1003 // 版本冲突
+	// 内容没有通过审核，原因见返回的 data.reasons
+	CodeContentRejected = 1004 // 内容未通过审核
+)
+
+// CurrentUser 获取 TokenAuthMiddleware 加载的当前用户
+func CurrentUser(c *gin.Context) *models.User {
+	if v, ok := c.Get("User"); ok {
+		if user, ok := v.(*models.User); ok {
+			return user
+		}
+	}
+	return nil
+}
+
+// RequireRole 要求当前用户拥有指定角色之一，需要在 TokenAuthMiddleware 之后使用
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := CurrentUser(c)
+		if user != nil {
+			for _, role := range roles {
+				if user.Role == role {
+					c.Next()
+					return
+				}
+			}
+		}
+		response.FailWithCode(c, CodeBadRole)
+		c.Abort()
+	}
+}
+
+// CanModify 只有资源的所有者或管理员可以修改和删除
+func CanModify(c *gin.Context, ownerId int) bool {
+	user := CurrentUser(c)
+	if user == nil {
+		return false
+	}
+	return user.Role == models.RoleAdmin || user.UserId == ownerId
+}
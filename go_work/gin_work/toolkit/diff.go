@@ -0,0 +1,113 @@
+package toolkit
+
+import "strings"
+
+// 差异类型
+const (
+	DiffEqual  = "="
+	DiffInsert = "+"
+	DiffDelete = "-"
+)
+
+// DiffLine 一行差异
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// 编辑距离超过该值时不再计算最短路径，直接视为整体替换，避免占用过多内存
+const maxEditDistance = 2000
+
+// DiffLines 按行比较两段文本，使用Myers算法得到最短编辑序列
+func DiffLines(a, b string) []DiffLine {
+	x, y := splitLines(a), splitLines(b)
+	n, m := len(x), len(y)
+	max := n + m
+	if max > maxEditDistance {
+		max = maxEditDistance
+	}
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	// trace[d] 保存第d步开始前v[-d-1..d+1]的值，用于回溯
+	var trace [][]int
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var i int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				i = v[offset+k+1]
+			} else {
+				i = v[offset+k-1] + 1
+			}
+			j := i - k
+			for i < n && j < m && x[i] == y[j] {
+				i++
+				j++
+			}
+			v[offset+k] = i
+			if i >= n && j >= m {
+				return backtrack(x, y, trace)
+			}
+		}
+	}
+	return replaceAll(x, y)
+}
+
+// 从终点回溯编辑路径
+func backtrack(x, y []string, trace [][]int) []DiffLine {
+	i, j := len(x), len(y)
+	var out []DiffLine
+	for d := len(trace) - 1; d >= 0; d-- {
+		// trace[d]中下标0对应k=-d-1
+		v := func(k int) int { return trace[d][k+d+1] }
+		k := i - j
+		var prevK int
+		if k == -d || (k != d && v(k-1) < v(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevI := v(prevK)
+		prevJ := prevI - prevK
+		for i > prevI && j > prevJ {
+			i--
+			j--
+			out = append(out, DiffLine{Op: DiffEqual, Text: x[i]})
+		}
+		if d > 0 {
+			if i == prevI {
+				j--
+				out = append(out, DiffLine{Op: DiffInsert, Text: y[j]})
+			} else {
+				i--
+				out = append(out, DiffLine{Op: DiffDelete, Text: x[i]})
+			}
+		}
+	}
+	// 回溯得到的是倒序
+	for l, r := 0, len(out)-1; l < r; l, r = l+1, r-1 {
+		out[l], out[r] = out[r], out[l]
+	}
+	return out
+}
+
+// 差异过大时整体删除再插入
+func replaceAll(x, y []string) []DiffLine {
+	out := make([]DiffLine, 0, len(x)+len(y))
+	for _, line := range x {
+		out = append(out, DiffLine{Op: DiffDelete, Text: line})
+	}
+	for _, line := range y {
+		out = append(out, DiffLine{Op: DiffInsert, Text: line})
+	}
+	return out
+}
+
+// 按行切分，统一换行符
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,120 @@
+package toolkit
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 用 "op text" 的形式书写期望结果
+func diffOps(lines ...string) []DiffLine {
+	var out []DiffLine
+	for _, l := range lines {
+		out = append(out, DiffLine{Op: l[:1], Text: l[2:]})
+	}
+	return out
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []DiffLine
+	}{
+		{"both empty", "", "", nil},
+		{"equal", "a\nb\n", "a\nb", diffOps("= a", "= b")},
+		{"insert into empty", "", "a\nb", diffOps("+ a", "+ b")},
+		{"delete all", "a\nb", "", diffOps("- a", "- b")},
+		{"append", "a\nb", "a\nb\nc", diffOps("= a", "= b", "+ c")},
+		{"prepend", "b\nc", "a\nb\nc", diffOps("+ a", "= b", "= c")},
+		{"change middle", "a\nb\nc", "a\nx\nc", diffOps("= a", "- b", "+ x", "= c")},
+		{"crlf", "a\r\nb\r\n", "a\nb\n", diffOps("= a", "= b")},
+		{"blank lines", "a\n\nb", "a\nb", diffOps("= a", "- ", "= b")},
+		{"move line", "a\nb\nc", "b\nc\na", diffOps("- a", "= b", "= c", "+ a")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DiffLines(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("DiffLines(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+// 从差异还原两边的文本
+func applyDiff(diff []DiffLine) (a, b []string) {
+	for _, d := range diff {
+		if d.Op != DiffInsert {
+			a = append(a, d.Text)
+		}
+		if d.Op != DiffDelete {
+			b = append(b, d.Text)
+		}
+	}
+	return a, b
+}
+
+// 最长公共子序列长度，最短编辑序列的增删数为 n+m-2*LCS
+func lcsLength(x, y []string) int {
+	dp := make([][]int, len(x)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else if dp[i+1][j] > dp[i][j+1] {
+				dp[i][j] = dp[i+1][j]
+			} else {
+				dp[i][j] = dp[i][j+1]
+			}
+		}
+	}
+	return dp[0][0]
+}
+
+func TestDiffLinesShortest(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	randomText := func() []string {
+		lines := make([]string, r.Intn(30))
+		for i := range lines {
+			lines[i] = string(rune('a' + r.Intn(4)))
+		}
+		return lines
+	}
+	for i := 0; i < 500; i++ {
+		x, y := randomText(), randomText()
+		diff := DiffLines(strings.Join(x, "\n"), strings.Join(y, "\n"))
+		gotA, gotB := applyDiff(diff)
+		if strings.Join(gotA, "\n") != strings.Join(x, "\n") || strings.Join(gotB, "\n") != strings.Join(y, "\n") {
+			t.Fatalf("diff of %q and %q does not reproduce the inputs: %v", x, y, diff)
+		}
+		edits := 0
+		for _, d := range diff {
+			if d.Op != DiffEqual {
+				edits++
+			}
+		}
+		if want := len(x) + len(y) - 2*lcsLength(x, y); edits != want {
+			t.Fatalf("diff of %q and %q has %d edits, want %d", x, y, edits, want)
+		}
+	}
+}
+
+func TestDiffLinesTooLarge(t *testing.T) {
+	var a, b []string
+	for i := 0; i < maxEditDistance; i++ {
+		a = append(a, "a")
+		b = append(b, "b")
+	}
+	diff := DiffLines(strings.Join(a, "\n"), strings.Join(b, "\n"))
+	if len(diff) != 2*maxEditDistance || diff[0].Op != DiffDelete || diff[len(diff)-1].Op != DiffInsert {
+		t.Fatalf("expected whole replacement, got %d lines", len(diff))
+	}
+	gotA, gotB := applyDiff(diff)
+	if !reflect.DeepEqual(gotA, a) || !reflect.DeepEqual(gotB, b) {
+		t.Fatal("replacement does not reproduce the inputs")
+	}
+}
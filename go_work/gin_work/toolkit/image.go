@@ -0,0 +1,64 @@
+package toolkit
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif" // 注册GIF解码
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // 注册WebP解码
+)
+
+// 允许处理的最大像素数，防止解压炸弹占用过多内存
+const maxImagePixels = 40 * 1000 * 1000
+
+// ImageSize 读取图片的宽高，不解码像素
+func ImageSize(data []byte) (width, height int, err error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxImagePixels {
+		return 0, 0, errors.New("image too large")
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// Thumbnail 生成缩略图，等比缩放到宽高都不超过size，小图不放大
+// PNG和GIF输出为PNG以保留透明度，其他格式输出为JPEG
+func Thumbnail(data []byte, size int) (thumb []byte, contentType string, err error) {
+	if _, _, err = ImageSize(data); err != nil {
+		return nil, "", err
+	}
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > size || h > size {
+		if w >= h {
+			w, h = size, max(1, h*size/w)
+		} else {
+			w, h = max(1, w*size/h), size
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Src, nil)
+
+	var buf bytes.Buffer
+	if format == "png" || format == "gif" {
+		err = png.Encode(&buf, dst)
+		contentType = "image/png"
+	} else {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+		contentType = "image/jpeg"
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), contentType, nil
+}
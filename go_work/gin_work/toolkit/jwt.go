@@ -1,39 +1,186 @@
 package toolkit
 
 import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"gin_work/models"
 	"gin_work/response"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
+	"strings"
 	"time"
 )
 
-// 设置密钥
+const (
+	// AccessTokenTTL 访问令牌有效期
+	AccessTokenTTL = 15 * time.Minute
+	// RefreshTokenTTL 刷新令牌有效期
+	RefreshTokenTTL = 7 * 24 * time.Hour
+	// MFATokenTTL 两步验证中间令牌的有效期
+	MFATokenTTL = 5 * time.Minute
+	// StreamTicketTTL 建立SSE连接的票据的有效期
+	StreamTicketTTL = time.Minute
+)
 
-var jwtKey = []byte("secret_key")
+// 令牌用途，访问令牌的Purpose为空
+const (
+	purposeMFA    = "mfa"
+	purposeStream = "stream"
+)
 
 //Claims 是一个结构体，继承jwt.StandardClaim
 
 type Claims struct {
+	// UserId 令牌所属的用户，用户名在账号注销后可以被重新注册，验证时以ID为准
+	UserId   int    `json:"uid"`
 	Username string `json:"username"`
+	// Version 签发时用户的令牌版本，修改密码后旧版本的令牌失效
+	Version int `json:"ver,omitempty"`
+	// Purpose 非访问令牌的用途，中间件只接受为空的令牌
+	Purpose string `json:"purpose,omitempty"`
+	// AccessID、AccessExpiresAt 签发连接票据的访问令牌，票据建立的连接随访问令牌一起失效
+	AccessID        string           `json:"aid,omitempty"`
+	AccessExpiresAt *jwt.NumericDate `json:"aexp,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// SessionID 令牌所属的访问令牌ID，注销后该ID被吊销
+func (c *Claims) SessionID() string {
+	if c.AccessID != "" {
+		return c.AccessID
+	}
+	return c.ID
+}
+
+// SessionExpiresAt 令牌所属的访问令牌的过期时间
+func (c *Claims) SessionExpiresAt() time.Time {
+	if c.AccessExpiresAt != nil {
+		return c.AccessExpiresAt.Time
+	}
+	if c.ExpiresAt != nil {
+		return c.ExpiresAt.Time
+	}
+	return time.Now().Add(AccessTokenTTL)
+}
+
 // 生成JWT
-func GenerateToken(username string) (string, error) {
+func GenerateToken(user *models.User) (string, error) {
+	return generateToken(user, "", AccessTokenTTL)
+}
+
+// GenerateMFAToken 生成两步验证的中间令牌，只能用于提交验证码
+func GenerateMFAToken(user *models.User) (string, error) {
+	return generateToken(user, purposeMFA, MFATokenTTL)
+}
+
+// GenerateStreamTicket 用访问令牌换取建立SSE连接的一次性票据
+// EventSource 只能通过地址传递凭证，票据很快过期且只能使用一次，不会因为出现在日志中泄露访问令牌
+func GenerateStreamTicket(access *Claims) (string, error) {
+	claims, err := newClaims(access.UserId, access.Username, purposeStream, StreamTicketTTL)
+	if err != nil {
+		return "", err
+	}
+	claims.Version = access.Version
+	claims.AccessID = access.ID
+	claims.AccessExpiresAt = access.ExpiresAt
+	return signToken(claims)
+}
+
+func generateToken(user *models.User, purpose string, ttl time.Duration) (string, error) {
+	claims, err := newClaims(user.UserId, user.UserName, purpose, ttl)
+	if err != nil {
+		return "", err
+	}
+	claims.Version = user.TokenVersion
+
+	//加密身份
+	return signToken(claims)
+}
+
+func newClaims(userId int, username, purpose string, ttl time.Duration) (*Claims, error) {
 	//设置令牌过期时间
-	expirationTime := time.Now().Add(24 * time.Hour)
+	now := time.Now()
+	expirationTime := now.Add(ttl)
 
-	claims := &Claims{
+	jti, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+	return &Claims{
+		UserId:   userId,
 		Username: username,
+		Purpose:  purpose,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			IssuedAt:  jwt.NewNumericDate(now),
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 		},
+	}, nil
+}
+
+// ParseToken 解析并校验访问令牌，同时检查令牌是否已被吊销
+func ParseToken(tokenString string) (*Claims, error) {
+	return parseToken(tokenString, "")
+}
+
+// ParseMFAToken 解析两步验证的中间令牌
+func ParseMFAToken(tokenString string) (*Claims, error) {
+	return parseToken(tokenString, purposeMFA)
+}
+
+// 解析连接票据，票据使用后立即作废；签发票据的访问令牌已吊销时同样无效
+func parseStreamTicket(tokenString string) (*Claims, error) {
+	claims, err := parseToken(tokenString, purposeStream)
+	if err != nil {
+		return nil, err
+	}
+	if claims.AccessID == "" || models.IsTokenRevoked(claims.AccessID) {
+		return nil, errors.New("token revoked")
+	}
+	if err = models.RevokeAccessToken(claims.ID, claims.ExpiresAt.Time); err != nil {
+		return nil, errors.New("invalid token")
 	}
+	return claims, nil
+}
 
-	//加密身份
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtKey)
-	return tokenString, err
+func parseToken(tokenString, purpose string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, lookupKey, jwt.WithValidMethods(validMethods()))
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	if claims.ID == "" || models.IsTokenRevoked(claims.ID) {
+		return nil, errors.New("token revoked")
+	}
+	if claims.Purpose != purpose {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// GenerateRefreshToken 生成随机的刷新令牌
+func GenerateRefreshToken() (string, error) {
+	return RandomToken()
+}
+
+// RandomToken 生成32字节的随机令牌，用于刷新令牌、邮箱验证等场景
+func RandomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// 生成n字节的随机十六进制字符串
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
 }
 
 // TokenAuthMiddleware 设置中间件验证请求头中的令牌
@@ -41,21 +188,65 @@ func GenerateToken(username string) (string, error) {
 func TokenAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 获取验证请求头中的信息
-		tokenString := c.GetHeader("Authorization")
-
-		claims := &Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return jwtKey, nil
-		})
+		tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		authenticate(c, tokenString)
+	}
+}
 
-		// 如果有错误或者token无效
-		if err != nil || !token.Valid {
-			response.FailWithMsg(c, "Unauthorized")
-			c.Abort()
+// StreamAuthMiddleware 用于SSE等长连接的验证中间件
+// 浏览器的EventSource不能设置请求头，没有Authorization时从 ticket 参数读取一次性连接票据，见 GenerateStreamTicket
+func StreamAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if tokenString == "" {
+			authenticateWith(c, c.Query("ticket"), parseStreamTicket)
 			return
 		}
+		authenticate(c, tokenString)
+	}
+}
 
-		c.Set("Username", claims.Username)
-		c.Next()
+// 校验令牌并加载用户，失败时终止请求
+func authenticate(c *gin.Context, tokenString string) {
+	authenticateWith(c, tokenString, ParseToken)
+}
+
+func authenticateWith(c *gin.Context, tokenString string, parse func(string) (*Claims, error)) {
+	claims, err := parse(tokenString)
+
+	// 如果有错误或者token无效
+	if err != nil {
+		response.FailWithMsg(c, "Unauthorized")
+		c.Abort()
+		return
+	}
+
+	// 加载用户，角色变更和账号删除可以立即生效
+	user, err := ClaimsUser(claims)
+	if err != nil {
+		response.FailWithMsg(c, "Unauthorized")
+		c.Abort()
+		return
+	}
+
+	c.Set("Username", user.UserName)
+	c.Set("Claims", claims)
+	c.Set("User", user)
+	c.Next()
+}
+
+// ClaimsUser 加载令牌所属的用户
+// 按ID加载，账号注销后用相同用户名注册的新用户不能使用旧账号的令牌；修改密码前签发的令牌也不再有效
+func ClaimsUser(claims *Claims) (*models.User, error) {
+	if claims.UserId == 0 {
+		return nil, errors.New("user not found")
+	}
+	user, err := models.GetUserById(claims.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if user.TokenVersion != claims.Version {
+		return nil, errors.New("token revoked")
 	}
+	return user, nil
 }
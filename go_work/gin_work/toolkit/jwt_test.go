@@ -0,0 +1,266 @@
+package toolkit
+
+import (
+	"gin_work/dao"
+	"gin_work/models"
+	"gin_work/setting"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+// 令牌吊销记录和用户保存在内存中的SQLite，并使用HS256测试密钥，返回测试用户 alice
+func setupTokenDB(t *testing.T) *models.User {
+	t.Helper()
+	db, err := gorm.Open("sqlite3", "file:"+t.Name()+"?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.DB().SetMaxOpenConns(1)
+	if err = db.AutoMigrate(&models.RevokedToken{}, &models.User{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	alice := &models.User{UserName: "alice"}
+	if err = db.Create(alice).Error; err != nil {
+		t.Fatal(err)
+	}
+	old := dao.DB
+	dao.DB = db
+	t.Cleanup(func() {
+		dao.DB = old
+		_ = db.Close()
+	})
+	t.Setenv(secretEnv, "")
+	useKeys(t, &setting.JWTConfig{Secret: testSecret})
+	return alice
+}
+
+// 用当前密钥签发自定义的声明
+func signClaims(t *testing.T, mutate func(c *Claims)) string {
+	t.Helper()
+	claims, err := newClaims(1, "alice", "", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mutate(claims)
+	s, err := signToken(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestParseTokenClaims(t *testing.T) {
+	setupTokenDB(t)
+	tests := []struct {
+		name   string
+		mutate func(c *Claims)
+		valid  bool
+	}{
+		{"valid", func(c *Claims) {}, true},
+		{"expired", func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Second)) }, false},
+		{"not yet valid", func(c *Claims) { c.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Hour)) }, false},
+		{"missing jti", func(c *Claims) { c.ID = "" }, false},
+		{"mfa purpose", func(c *Claims) { c.Purpose = purposeMFA }, false},
+		{"stream purpose", func(c *Claims) { c.Purpose = purposeStream }, false},
+		{"unknown purpose", func(c *Claims) { c.Purpose = "reset" }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseToken(signClaims(t, tt.mutate))
+			if (err == nil) != tt.valid {
+				t.Fatalf("ParseToken err = %v, valid %v", err, tt.valid)
+			}
+		})
+	}
+	if _, err := ParseToken("not.a.token"); err == nil {
+		t.Fatal("garbage accepted")
+	}
+}
+
+func TestParseTokenRevoked(t *testing.T) {
+	alice := setupTokenDB(t)
+	tokenString, err := GenerateToken(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := ParseToken(tokenString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = models.RevokeAccessToken(claims.ID, claims.ExpiresAt.Time); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ParseToken(tokenString); err == nil {
+		t.Fatal("revoked token accepted")
+	}
+}
+
+// 两步验证的中间令牌只能用于提交验证码，访问令牌也不能代替中间令牌
+func TestMFATokenPurpose(t *testing.T) {
+	alice := setupTokenDB(t)
+	mfaToken, err := GenerateMFAToken(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessToken, err := GenerateToken(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ParseToken(mfaToken); err == nil {
+		t.Fatal("mfa token accepted as access token")
+	}
+	if _, err = ParseMFAToken(accessToken); err == nil {
+		t.Fatal("access token accepted as mfa token")
+	}
+	claims, err := ParseMFAToken(mfaToken)
+	if err != nil || claims.Username != "alice" {
+		t.Fatalf("ParseMFAToken = (%+v, %v)", claims, err)
+	}
+	if ttl := time.Until(claims.ExpiresAt.Time); ttl > MFATokenTTL || ttl < MFATokenTTL-time.Minute {
+		t.Fatalf("mfa token ttl = %v", ttl)
+	}
+}
+
+func TestStreamTicket(t *testing.T) {
+	alice := setupTokenDB(t)
+	accessToken, err := GenerateToken(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	access, err := ParseToken(accessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticket, err := GenerateStreamTicket(access)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ParseToken(ticket); err == nil {
+		t.Fatal("ticket accepted as access token")
+	}
+	claims, err := parseStreamTicket(ticket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 连接随签发票据的访问令牌一起过期和注销
+	if claims.SessionID() != access.ID || !claims.SessionExpiresAt().Equal(access.ExpiresAt.Time) {
+		t.Fatalf("session = (%s, %v), want (%s, %v)", claims.SessionID(), claims.SessionExpiresAt(), access.ID, access.ExpiresAt.Time)
+	}
+	if _, err = parseStreamTicket(ticket); err == nil {
+		t.Fatal("ticket used twice")
+	}
+
+	// 访问令牌注销后，之前签发的票据失效
+	second, err := GenerateStreamTicket(access)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = models.RevokeAccessToken(access.ID, access.ExpiresAt.Time); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = parseStreamTicket(second); err == nil {
+		t.Fatal("ticket of revoked access token accepted")
+	}
+	// 没有关联访问令牌的票据无效
+	orphan := signClaims(t, func(c *Claims) { c.Purpose = purposeStream })
+	if _, err = parseStreamTicket(orphan); err == nil {
+		t.Fatal("ticket without access id accepted")
+	}
+}
+
+// 账号注销后用相同用户名重新注册，旧账号的令牌不能用于新账号
+func TestTokenAuthReRegisteredName(t *testing.T) {
+	alice := setupTokenDB(t)
+	accessToken, err := GenerateToken(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/me", TokenAuthMiddleware(), func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString("Username"))
+	})
+	get := func() string {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		r.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+	if body := get(); body != "alice" {
+		t.Fatalf("before delete body = %q", body)
+	}
+
+	if err = dao.DB.Delete(alice).Error; err != nil {
+		t.Fatal(err)
+	}
+	again := &models.User{UserName: "alice"}
+	if err = dao.DB.Create(again).Error; err != nil {
+		t.Fatal(err)
+	}
+	if again.UserId == alice.UserId {
+		t.Fatalf("user id %d reused", again.UserId)
+	}
+	if body := get(); !strings.Contains(body, "Unauthorized") {
+		t.Fatalf("old token accepted after re-register: %q", body)
+	}
+}
+
+// 修改密码后，之前签发的访问令牌和连接票据全部失效
+func TestClaimsUserPasswordChanged(t *testing.T) {
+	alice := setupTokenDB(t)
+	accessToken, err := GenerateToken(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := ParseToken(accessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticket, err := GenerateStreamTicket(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ClaimsUser(old); err != nil {
+		t.Fatalf("ClaimsUser before change: %v", err)
+	}
+
+	if err = models.SetPassword(alice.UserId, "new password"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ClaimsUser(old); err == nil {
+		t.Fatal("access token accepted after password change")
+	}
+	stream, err := parseStreamTicket(ticket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ClaimsUser(stream); err == nil {
+		t.Fatal("stream ticket accepted after password change")
+	}
+
+	// 重新加载用户后签发的令牌有效
+	user, err := models.GetUserById(alice.UserId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessToken, err = GenerateToken(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := ParseToken(accessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ClaimsUser(claims); err != nil {
+		t.Fatalf("ClaimsUser after reload: %v", err)
+	}
+}
@@ -0,0 +1,196 @@
+package toolkit
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"gin_work/setting"
+	"github.com/golang-jwt/jwt/v4"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+)
+
+// jwtKey 一个可用于签名或验证的密钥
+type jwtKey struct {
+	kid    string
+	method jwt.SigningMethod
+	// 签名使用的密钥：HS256为[]byte，RS256为*rsa.PrivateKey，EdDSA为ed25519.PrivateKey
+	sign interface{}
+	// 验证使用的密钥：HS256为[]byte，RS256为*rsa.PublicKey，EdDSA为ed25519.PublicKey
+	verify interface{}
+}
+
+// HS256 密钥可以通过环境变量设置，避免写在配置文件中
+const secretEnv = "JWT_SECRET"
+
+// HS256 密钥的最小长度，与签名的哈希长度相同，示例配置中的 secret_key 之类的占位值会被拒绝
+const minSecretLen = 32
+
+var (
+	// 当前的签名密钥
+	signingKey *jwtKey
+	// 按kid索引的验证密钥，包含当前签名密钥和轮换期间保留的旧密钥
+	verifyKeys = map[string]*jwtKey{}
+)
+
+// InitKeys 根据配置加载签名和验证密钥
+func InitKeys(cfg *setting.JWTConfig) error {
+	if cfg == nil {
+		return errors.New("jwt config not found")
+	}
+	kid := cfg.KeyID
+	if kid == "" {
+		kid = "default"
+	}
+	key := &jwtKey{kid: kid}
+	switch strings.ToUpper(cfg.Algorithm) {
+	case "", "HS256":
+		secret := cfg.Secret
+		if env := os.Getenv(secretEnv); env != "" {
+			secret = env
+		}
+		if secret == "" {
+			return errors.New("jwt secret is required for HS256, set " + secretEnv + " or [jwt] secret")
+		}
+		if len(secret) < minSecretLen {
+			return fmt.Errorf("jwt secret must be a random string of at least %d bytes", minSecretLen)
+		}
+		key.method = jwt.SigningMethodHS256
+		key.sign = []byte(secret)
+		key.verify = key.sign
+	case "RS256":
+		data, err := os.ReadFile(cfg.PrivateKeyFile)
+		if err != nil {
+			return err
+		}
+		private, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+		if err != nil {
+			return err
+		}
+		key.method = jwt.SigningMethodRS256
+		key.sign = private
+		key.verify = &private.PublicKey
+	case "EDDSA":
+		data, err := os.ReadFile(cfg.PrivateKeyFile)
+		if err != nil {
+			return err
+		}
+		private, err := jwt.ParseEdPrivateKeyFromPEM(data)
+		if err != nil {
+			return err
+		}
+		key.method = jwt.SigningMethodEdDSA
+		key.sign = private
+		key.verify = private.(crypto.Signer).Public()
+	default:
+		return fmt.Errorf("unsupported jwt algorithm %q", cfg.Algorithm)
+	}
+
+	keys := map[string]*jwtKey{kid: key}
+	for _, item := range cfg.VerifyKeys {
+		k, err := loadPublicKey(item)
+		if err != nil {
+			return err
+		}
+		keys[k.kid] = k
+	}
+	for _, item := range cfg.VerifySecrets {
+		oldKid, secret, ok := strings.Cut(strings.TrimSpace(item), ":")
+		if !ok || oldKid == "" || secret == "" {
+			return fmt.Errorf("invalid verify secret %q", item)
+		}
+		keys[oldKid] = &jwtKey{kid: oldKid, method: jwt.SigningMethodHS256, verify: []byte(secret)}
+	}
+	signingKey = key
+	verifyKeys = keys
+	return nil
+}
+
+// 加载 kid:PEM文件路径 格式的公钥，算法由密钥类型决定
+func loadPublicKey(item string) (*jwtKey, error) {
+	kid, file, ok := strings.Cut(strings.TrimSpace(item), ":")
+	if !ok || kid == "" || file == "" {
+		return nil, fmt.Errorf("invalid verify key %q", item)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if public, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return &jwtKey{kid: kid, method: jwt.SigningMethodRS256, verify: public}, nil
+	}
+	if public, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+		return &jwtKey{kid: kid, method: jwt.SigningMethodEdDSA, verify: public}, nil
+	}
+	return nil, fmt.Errorf("unsupported public key in %s", file)
+}
+
+// 签名令牌，并在header中写入kid
+func signToken(claims jwt.Claims) (string, error) {
+	if signingKey == nil {
+		return "", errors.New("jwt keys not initialized")
+	}
+	token := jwt.NewWithClaims(signingKey.method, claims)
+	token.Header["kid"] = signingKey.kid
+	return token.SignedString(signingKey.sign)
+}
+
+// 解析令牌时根据kid选择验证密钥，并拒绝与密钥不匹配的alg
+func lookupKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := verifyKeys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected alg %q", token.Method.Alg())
+	}
+	return key.verify, nil
+}
+
+// 当前允许的签名算法
+func validMethods() []string {
+	seen := map[string]bool{}
+	var methods []string
+	for _, key := range verifyKeys {
+		if alg := key.method.Alg(); !seen[alg] {
+			seen[alg] = true
+			methods = append(methods, alg)
+		}
+	}
+	return methods
+}
+
+// JWKS 返回所有非对称验证公钥的JSON Web Key Set，HS256密钥不会对外公开
+func JWKS() map[string]interface{} {
+	keys := make([]map[string]string, 0, len(verifyKeys))
+	for _, key := range verifyKeys {
+		switch public := key.verify.(type) {
+		case *rsa.PublicKey:
+			keys = append(keys, map[string]string{
+				"kty": "RSA",
+				"use": "sig",
+				"alg": key.method.Alg(),
+				"kid": key.kid,
+				"n":   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			keys = append(keys, map[string]string{
+				"kty": "OKP",
+				"crv": "Ed25519",
+				"use": "sig",
+				"alg": key.method.Alg(),
+				"kid": key.kid,
+				"x":   base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i]["kid"] < keys[j]["kid"] })
+	return map[string]interface{}{"keys": keys}
+}
@@ -0,0 +1,306 @@
+package toolkit
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"gin_work/setting"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// 测试结束后恢复全局密钥
+func useKeys(t *testing.T, cfg *setting.JWTConfig) {
+	t.Helper()
+	oldSigning, oldVerify := signingKey, verifyKeys
+	t.Cleanup(func() { signingKey, verifyKeys = oldSigning, oldVerify })
+	if err := InitKeys(cfg); err != nil {
+		t.Fatalf("InitKeys: %v", err)
+	}
+}
+
+func writePEM(t *testing.T, name, kind string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// 生成RSA密钥，返回私钥和公钥文件
+func rsaKeyFiles(t *testing.T) (*rsa.PrivateKey, string, string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, writePEM(t, "rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key)),
+		writePEM(t, "rsa.pub.pem", "PUBLIC KEY", public)
+}
+
+func ed25519KeyFiles(t *testing.T) (ed25519.PublicKey, string, string) {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return public, writePEM(t, "ed.pem", "PRIVATE KEY", privateDER), writePEM(t, "ed.pub.pem", "PUBLIC KEY", publicDER)
+}
+
+// 用任意密钥签名，模拟攻击者构造的令牌
+func forgeToken(t *testing.T, method jwt.SigningMethod, kid string, key interface{}) string {
+	t.Helper()
+	claims, err := newClaims(1, "mallory", "", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestInitKeysSecret(t *testing.T) {
+	tests := []struct {
+		name    string
+		secret  string
+		env     string
+		wantErr bool
+	}{
+		{"missing", "", "", true},
+		{"placeholder", "secret_key", "", true},
+		{"too short", testSecret[:31], "", true},
+		{"config", testSecret, "", false},
+		{"env overrides placeholder", "secret_key", testSecret, false},
+		{"short env", testSecret, "short", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(secretEnv, tt.env)
+			oldSigning, oldVerify := signingKey, verifyKeys
+			defer func() { signingKey, verifyKeys = oldSigning, oldVerify }()
+			err := InitKeys(&setting.JWTConfig{Secret: tt.secret})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("InitKeys err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInitKeysInvalid(t *testing.T) {
+	t.Setenv(secretEnv, "")
+	_, _, rsaPublic := rsaKeyFiles(t)
+	tests := []struct {
+		name string
+		cfg  setting.JWTConfig
+	}{
+		{"unsupported algorithm", setting.JWTConfig{Algorithm: "none"}},
+		{"missing private key", setting.JWTConfig{Algorithm: "RS256", PrivateKeyFile: filepath.Join(t.TempDir(), "missing.pem")}},
+		{"public key as private key", setting.JWTConfig{Algorithm: "RS256", PrivateKeyFile: rsaPublic}},
+		{"verify key without kid", setting.JWTConfig{Secret: testSecret, VerifyKeys: []string{rsaPublic}}},
+		{"verify secret without kid", setting.JWTConfig{Secret: testSecret, VerifySecrets: []string{"old"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldSigning, oldVerify := signingKey, verifyKeys
+			defer func() { signingKey, verifyKeys = oldSigning, oldVerify }()
+			if err := InitKeys(&tt.cfg); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestTokenAlgorithms(t *testing.T) {
+	alice := setupTokenDB(t)
+	t.Setenv(secretEnv, "")
+	_, rsaPrivate, _ := rsaKeyFiles(t)
+	_, edPrivate, _ := ed25519KeyFiles(t)
+	tests := []struct {
+		name string
+		cfg  setting.JWTConfig
+		alg  string
+	}{
+		{"HS256", setting.JWTConfig{Secret: testSecret}, "HS256"},
+		{"RS256", setting.JWTConfig{Algorithm: "RS256", KeyID: "rsa-1", PrivateKeyFile: rsaPrivate}, "RS256"},
+		{"EdDSA", setting.JWTConfig{Algorithm: "EdDSA", KeyID: "ed-1", PrivateKeyFile: edPrivate}, "EdDSA"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useKeys(t, &tt.cfg)
+			tokenString, err := GenerateToken(alice)
+			if err != nil {
+				t.Fatal(err)
+			}
+			header := decodeHeader(t, tokenString)
+			if header["alg"] != tt.alg || header["kid"] != signingKey.kid {
+				t.Fatalf("header = %v", header)
+			}
+			claims, err := ParseToken(tokenString)
+			if err != nil || claims.Username != "alice" || claims.ID == "" {
+				t.Fatalf("ParseToken = (%+v, %v)", claims, err)
+			}
+		})
+	}
+}
+
+func decodeHeader(t *testing.T, tokenString string) map[string]interface{} {
+	t.Helper()
+	token, _, err := new(jwt.Parser).ParseUnverified(tokenString, &Claims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token.Header
+}
+
+// 令牌的alg必须与kid对应的密钥一致，不能用公钥作为HS256密钥伪造令牌
+func TestLookupKeyRejectsForgedTokens(t *testing.T) {
+	setupTokenDB(t)
+	rsaKey, rsaPrivate, rsaPublic := rsaKeyFiles(t)
+	useKeys(t, &setting.JWTConfig{
+		Algorithm: "RS256", KeyID: "rsa-1", PrivateKeyFile: rsaPrivate,
+		VerifySecrets: []string{"hs-old:" + testSecret},
+	})
+	publicPEM, err := os.ReadFile(rsaPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"hs256 with public key pem", forgeToken(t, jwt.SigningMethodHS256, "rsa-1", publicPEM)},
+		{"hs256 with modulus", forgeToken(t, jwt.SigningMethodHS256, "rsa-1", rsaKey.PublicKey.N.Bytes())},
+		{"hs256 secret under rsa kid", forgeToken(t, jwt.SigningMethodHS256, "rsa-1", []byte(testSecret))},
+		{"rs256 under hs kid", forgeToken(t, jwt.SigningMethodRS256, "hs-old", rsaKey)},
+		{"unknown kid", forgeToken(t, jwt.SigningMethodRS256, "rsa-2", rsaKey)},
+		{"missing kid", forgeToken(t, jwt.SigningMethodRS256, "", rsaKey)},
+		{"other rsa key", forgeToken(t, jwt.SigningMethodRS256, "rsa-1", other)},
+		{"ps256 with same key", forgeToken(t, jwt.SigningMethodPS256, "rsa-1", rsaKey)},
+		{"alg none", forgeToken(t, jwt.SigningMethodNone, "rsa-1", jwt.UnsafeAllowNoneSignatureType)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if claims, err := ParseToken(tt.token); err == nil {
+				t.Fatalf("forged token accepted: %+v", claims)
+			}
+		})
+	}
+	// 同一个密钥正常签发的令牌可以通过
+	if _, err = ParseToken(forgeToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey)); err != nil {
+		t.Fatalf("valid token rejected: %v", err)
+	}
+	// 轮换保留的HS256密钥仍可验证旧令牌
+	if _, err = ParseToken(forgeToken(t, jwt.SigningMethodHS256, "hs-old", []byte(testSecret))); err != nil {
+		t.Fatalf("token signed with retained secret rejected: %v", err)
+	}
+}
+
+// 轮换后用旧密钥签发的令牌在旧公钥保留期间仍然有效，移除后失效
+func TestKeyRotation(t *testing.T) {
+	alice := setupTokenDB(t)
+	_, oldPrivate, oldPublic := rsaKeyFiles(t)
+	_, newPrivate, _ := ed25519KeyFiles(t)
+
+	useKeys(t, &setting.JWTConfig{Algorithm: "RS256", KeyID: "2024-01", PrivateKeyFile: oldPrivate})
+	oldToken, err := GenerateToken(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	useKeys(t, &setting.JWTConfig{Algorithm: "EdDSA", KeyID: "2024-06", PrivateKeyFile: newPrivate,
+		VerifyKeys: []string{"2024-01:" + oldPublic}})
+	if _, err = ParseToken(oldToken); err != nil {
+		t.Fatalf("token signed before rotation rejected: %v", err)
+	}
+	newToken, err := GenerateToken(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := decodeHeader(t, newToken)["kid"]; kid != "2024-06" {
+		t.Fatalf("new token kid = %v", kid)
+	}
+	// 旧公钥只能验证，不能用来签发
+	if signingKey.kid != "2024-06" {
+		t.Fatalf("signing with %s", signingKey.kid)
+	}
+
+	useKeys(t, &setting.JWTConfig{Algorithm: "EdDSA", KeyID: "2024-06", PrivateKeyFile: newPrivate})
+	if _, err = ParseToken(oldToken); err == nil {
+		t.Fatal("token signed with removed key accepted")
+	}
+	if _, err = ParseToken(newToken); err != nil {
+		t.Fatalf("current token rejected: %v", err)
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	t.Setenv(secretEnv, "")
+	rsaKey, rsaPrivate, _ := rsaKeyFiles(t)
+	edPublic, _, edPublicFile := ed25519KeyFiles(t)
+	useKeys(t, &setting.JWTConfig{
+		Algorithm: "RS256", KeyID: "b-rsa", PrivateKeyFile: rsaPrivate,
+		VerifyKeys:    []string{"a-ed:" + edPublicFile},
+		VerifySecrets: []string{"c-hs:" + testSecret},
+	})
+	keys := JWKS()["keys"].([]map[string]string)
+	if len(keys) != 2 {
+		t.Fatalf("JWKS has %d keys, want 2 (HS256 secrets are private): %v", len(keys), keys)
+	}
+	ed, rsaJWK := keys[0], keys[1]
+	if ed["kid"] != "a-ed" || ed["kty"] != "OKP" || ed["crv"] != "Ed25519" || ed["alg"] != "EdDSA" ||
+		ed["x"] != base64.RawURLEncoding.EncodeToString(edPublic) {
+		t.Fatalf("ed25519 jwk = %v", ed)
+	}
+	if rsaJWK["kid"] != "b-rsa" || rsaJWK["kty"] != "RSA" || rsaJWK["alg"] != "RS256" || rsaJWK["use"] != "sig" {
+		t.Fatalf("rsa jwk = %v", rsaJWK)
+	}
+	n, err := base64.RawURLEncoding.DecodeString(rsaJWK["n"])
+	if err != nil || new(big.Int).SetBytes(n).Cmp(rsaKey.N) != 0 {
+		t.Fatalf("modulus mismatch: %v", err)
+	}
+	if rsaJWK["e"] != "AQAB" {
+		t.Fatalf("exponent = %s", rsaJWK["e"])
+	}
+	// 不能包含私钥参数
+	for _, key := range keys {
+		for _, name := range []string{"d", "p", "q", "dp", "dq", "qi", "k"} {
+			if _, ok := key[name]; ok {
+				t.Fatalf("private field %q published in %v", name, key)
+			}
+		}
+	}
+}
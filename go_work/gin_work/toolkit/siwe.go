@@ -0,0 +1,169 @@
+package toolkit
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SiweMessage EIP-4361 登录消息
+type SiweMessage struct {
+	Domain         string
+	Address        common.Address
+	Statement      string
+	URI            string
+	Version        string
+	ChainID        int64
+	Nonce          string
+	IssuedAt       time.Time
+	ExpirationTime *time.Time
+	NotBefore      *time.Time
+	RequestID      string
+	Resources      []string
+}
+
+const siweHeaderSuffix = " wants you to sign in with your Ethereum account:"
+
+// ParseSiweMessage 解析EIP-4361格式的消息
+func ParseSiweMessage(message string) (*SiweMessage, error) {
+	lines := strings.Split(strings.ReplaceAll(message, "\r\n", "\n"), "\n")
+	if len(lines) < 3 || !strings.HasSuffix(lines[0], siweHeaderSuffix) {
+		return nil, errors.New("invalid siwe header")
+	}
+	msg := &SiweMessage{Domain: strings.TrimSuffix(lines[0], siweHeaderSuffix)}
+	if i := strings.Index(msg.Domain, "://"); i >= 0 {
+		msg.Domain = msg.Domain[i+3:]
+	}
+	if !common.IsHexAddress(lines[1]) {
+		return nil, errors.New("invalid siwe address")
+	}
+	msg.Address = common.HexToAddress(lines[1])
+
+	// 地址之后到 "URI: " 之间的非空行是可选的statement
+	i := 2
+	var statement []string
+	for ; i < len(lines) && !strings.HasPrefix(lines[i], "URI: "); i++ {
+		if lines[i] != "" {
+			statement = append(statement, lines[i])
+		}
+	}
+	msg.Statement = strings.Join(statement, "\n")
+
+	var err error
+	inResources := false
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if inResources {
+			if strings.HasPrefix(line, "- ") {
+				msg.Resources = append(msg.Resources, strings.TrimPrefix(line, "- "))
+				continue
+			}
+			if line == "" {
+				continue
+			}
+			return nil, fmt.Errorf("invalid siwe resource %q", line)
+		}
+		if line == "" {
+			continue
+		}
+		if line == "Resources:" {
+			inResources = true
+			continue
+		}
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			return nil, fmt.Errorf("invalid siwe field %q", line)
+		}
+		switch key {
+		case "URI":
+			msg.URI = value
+		case "Version":
+			msg.Version = value
+		case "Chain ID":
+			msg.ChainID, err = strconv.ParseInt(value, 10, 64)
+		case "Nonce":
+			msg.Nonce = value
+		case "Issued At":
+			msg.IssuedAt, err = time.Parse(time.RFC3339, value)
+		case "Expiration Time":
+			var t time.Time
+			t, err = time.Parse(time.RFC3339, value)
+			msg.ExpirationTime = &t
+		case "Not Before":
+			var t time.Time
+			t, err = time.Parse(time.RFC3339, value)
+			msg.NotBefore = &t
+		case "Request ID":
+			msg.RequestID = value
+		default:
+			return nil, fmt.Errorf("unknown siwe field %q", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid siwe field %q", key)
+		}
+	}
+
+	if msg.URI == "" || msg.Version != "1" || msg.ChainID == 0 || len(msg.Nonce) < 8 || msg.IssuedAt.IsZero() {
+		return nil, errors.New("missing siwe fields")
+	}
+	return msg, nil
+}
+
+// Validate 校验消息的域名、链ID和有效期，chainID为0时不校验链
+func (m *SiweMessage) Validate(domain string, chainID int64, now time.Time) error {
+	if m.Domain != domain {
+		return errors.New("siwe domain mismatch")
+	}
+	if chainID != 0 && m.ChainID != chainID {
+		return errors.New("siwe chain id mismatch")
+	}
+	if m.ExpirationTime != nil && now.After(*m.ExpirationTime) {
+		return errors.New("siwe message expired")
+	}
+	if m.NotBefore != nil && now.Before(*m.NotBefore) {
+		return errors.New("siwe message not yet valid")
+	}
+	return nil
+}
+
+// VerifySiweSignature 按EIP-191(personal_sign)恢复签名者地址，并与消息中的地址比较
+func VerifySiweSignature(message string, signature string, address common.Address) error {
+	sig, err := hexutil.Decode(signature)
+	if err != nil || len(sig) != crypto.SignatureLength {
+		return errors.New("invalid signature")
+	}
+	// 钱包返回的v为27/28，SigToPub需要0/1
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	pub, err := crypto.SigToPub(accounts.TextHash([]byte(message)), sig)
+	if err != nil {
+		return errors.New("invalid signature")
+	}
+	if crypto.PubkeyToAddress(*pub) != address {
+		return errors.New("signature address mismatch")
+	}
+	return nil
+}
+
+// GenerateSiweNonce 生成EIP-4361要求的字母数字随机数
+func GenerateSiweNonce() (string, error) {
+	const letters = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	b := make([]byte, 16)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(letters))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = letters[n.Int64()]
+	}
+	return string(b), nil
+}
@@ -0,0 +1,171 @@
+package toolkit
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// 固定的测试私钥及其地址
+const (
+	siweTestKey     = "fad9c8855b740a0b7ed4c221dbad0f33a83a49cad6b3fe8d5817ac83d38b6a19"
+	siweTestAddress = "0x96216849c49358B10257cb55b28eA603c874b05E"
+)
+
+func siweTestMessage(address string) string {
+	return "example.com wants you to sign in with your Ethereum account:\n" +
+		address + "\n\n" +
+		"Sign in to the blog.\n\n" +
+		"URI: https://example.com/login\n" +
+		"Version: 1\n" +
+		"Chain ID: 1\n" +
+		"Nonce: 32891756abcdEF\n" +
+		"Issued At: 2021-09-30T16:25:24Z\n" +
+		"Expiration Time: 2021-10-01T16:25:24Z\n" +
+		"Not Before: 2021-09-30T16:00:00Z\n" +
+		"Request ID: req-1\n" +
+		"Resources:\n" +
+		"- ipfs://bafybeiemxf5abjwjbikoz4mc3a3dla6ual3jsgpdr4cjr3oz3evfyavhwq/\n" +
+		"- https://example.com/my-web2-claim.json"
+}
+
+// 按 personal_sign 签名，v 为钱包返回的27/28
+func siweSign(t *testing.T, message string) string {
+	t.Helper()
+	key, err := crypto.HexToECDSA(siweTestKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := crypto.Sign(accounts.TextHash([]byte(message)), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	return hexutil.Encode(sig)
+}
+
+func TestParseSiweMessage(t *testing.T) {
+	address := siweTestAddress
+	msg, err := ParseSiweMessage(siweTestMessage(address))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if msg.Domain != "example.com" || msg.Address.Hex() != address || msg.Statement != "Sign in to the blog." ||
+		msg.URI != "https://example.com/login" || msg.ChainID != 1 || msg.Nonce != "32891756abcdEF" ||
+		msg.RequestID != "req-1" || len(msg.Resources) != 2 {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+	if msg.ExpirationTime == nil || msg.NotBefore == nil || !msg.IssuedAt.Equal(time.Date(2021, 9, 30, 16, 25, 24, 0, time.UTC)) {
+		t.Fatalf("unexpected times %+v", msg)
+	}
+
+	valid := siweTestMessage(address)
+	tests := []struct {
+		name    string
+		message string
+	}{
+		{"missing header", strings.Replace(valid, " wants you to sign in", " wants you", 1)},
+		{"invalid address", strings.Replace(valid, address, "0x1234", 1)},
+		{"unknown field", strings.Replace(valid, "Request ID: req-1", "Foo: bar", 1)},
+		{"invalid chain id", strings.Replace(valid, "Chain ID: 1", "Chain ID: one", 1)},
+		{"invalid issued at", strings.Replace(valid, "2021-09-30T16:25:24Z", "yesterday", 1)},
+		{"unsupported version", strings.Replace(valid, "Version: 1", "Version: 2", 1)},
+		{"short nonce", strings.Replace(valid, "Nonce: 32891756abcdEF", "Nonce: 1234", 1)},
+		{"invalid resource", strings.Replace(valid, "- https://example.com/my-web2-claim.json", "https://example.com", 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseSiweMessage(tt.message); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestSiweMessageValidate(t *testing.T) {
+	msg, err := ParseSiweMessage(siweTestMessage(siweTestAddress))
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := time.Date(2021, 9, 30, 17, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		domain  string
+		chainID int64
+		now     time.Time
+		wantErr bool
+	}{
+		{"valid", "example.com", 1, valid, false},
+		{"any chain", "example.com", 0, valid, false},
+		{"domain mismatch", "evil.com", 1, valid, true},
+		{"chain mismatch", "example.com", 5, valid, true},
+		{"expired", "example.com", 1, valid.Add(48 * time.Hour), true},
+		{"not yet valid", "example.com", 1, valid.Add(-2 * time.Hour), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := msg.Validate(tt.domain, tt.chainID, tt.now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifySiweSignature(t *testing.T) {
+	address := common.HexToAddress(siweTestAddress)
+	message := siweTestMessage(siweTestAddress)
+	signature := siweSign(t, message)
+
+	// 部分钱包返回的v为0/1
+	raw := hexutil.MustDecode(signature)
+	raw[crypto.RecoveryIDOffset] -= 27
+	lowV := hexutil.Encode(raw)
+
+	tests := []struct {
+		name      string
+		message   string
+		signature string
+		address   common.Address
+		wantErr   bool
+	}{
+		{"valid", message, signature, address, false},
+		{"v is 0 or 1", message, lowV, address, false},
+		{"tampered message", strings.Replace(message, "Nonce: 32891756abcdEF", "Nonce: 32891756abcdEG", 1), signature, address, true},
+		{"other address", message, signature, common.HexToAddress("0x000000000000000000000000000000000000dEaD"), true},
+		{"not hex", message, "signature", address, true},
+		{"short signature", message, signature[:len(signature)-2], address, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifySiweSignature(tt.message, tt.signature, tt.address)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifySiweSignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGenerateSiweNonce(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		nonce, err := GenerateSiweNonce()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(nonce) != 16 || seen[nonce] {
+			t.Fatalf("bad nonce %q", nonce)
+		}
+		for _, r := range nonce {
+			if !('0' <= r && r <= '9' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z') {
+				t.Fatalf("nonce %q is not alphanumeric", nonce)
+			}
+		}
+		seen[nonce] = true
+	}
+}
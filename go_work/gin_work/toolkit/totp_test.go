@@ -0,0 +1,128 @@
+package toolkit
+
+import (
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// RFC 6238 附录B的SHA-1测试密钥 "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// RFC 6238 附录B的SHA-1测试向量，RFC使用8位验证码，这里取后6位
+func TestTOTPCodeRFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},          // 94287082
+		{1111111109, "081804"},  // 07081804
+		{1111111111, "050471"},  // 14050471
+		{1234567890, "005924"},  // 89005924
+		{2000000000, "279037"},  // 69279037
+		{20000000000, "353130"}, // 65353130
+	}
+	for _, tt := range tests {
+		got, err := TOTPCode(rfcSecret, tt.unix/totpPeriod)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("TOTPCode(T=%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+		// 小写和带填充的密钥同样可用
+		if lower, _ := TOTPCode("gezdgnbvgy3tqojqgezdgnbvgy3tqojq==", tt.unix/totpPeriod); lower != tt.want {
+			t.Errorf("lowercase secret gives %s", lower)
+		}
+	}
+	if _, err := TOTPCode("not base32!", 1); err == nil {
+		t.Fatal("invalid secret accepted")
+	}
+}
+
+func TestVerifyTOTPSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := now.Unix() / totpPeriod
+	code := func(step int64) string {
+		c, err := TOTPCode(rfcSecret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	tests := []struct {
+		name   string
+		offset int64
+		ok     bool
+	}{
+		{"two steps behind", -2, false},
+		{"one step behind", -1, true},
+		{"current", 0, true},
+		{"one step ahead", 1, true},
+		{"two steps ahead", 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := VerifyTOTP(rfcSecret, code(current+tt.offset), now)
+			if ok != tt.ok {
+				t.Fatalf("VerifyTOTP ok = %v, want %v", ok, tt.ok)
+			}
+			// 返回匹配的时间步，用于拒绝重放
+			if ok && step != current+tt.offset {
+				t.Fatalf("step = %d, want %d", step, current+tt.offset)
+			}
+		})
+	}
+
+	valid := code(current)
+	for _, bad := range []string{"", valid[:5], valid + "0", "abcdef"} {
+		if _, ok := VerifyTOTP(rfcSecret, bad, now); ok {
+			t.Fatalf("code %q accepted", bad)
+		}
+	}
+	if _, ok := VerifyTOTP(rfcSecret, " "+valid+"\n", now); !ok {
+		t.Fatal("code with surrounding spaces rejected")
+	}
+	if _, ok := VerifyTOTP("not base32!", valid, now); ok {
+		t.Fatal("invalid secret accepted")
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(key) != 20 {
+		t.Fatalf("secret %q decodes to %d bytes: %v", secret, len(key), err)
+	}
+	u, err := url.Parse(TOTPURI("My Blog", "alice", secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/My Blog:alice" ||
+		q.Get("secret") != secret || q.Get("issuer") != "My Blog" || q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Fatalf("unexpected uri %s", u)
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pattern := regexp.MustCompile(`^[a-z2-7]{5}-[a-z2-7]{5}$`)
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if !pattern.MatchString(code) || seen[code] {
+			t.Fatalf("bad or duplicate code %q in %v", code, codes)
+		}
+		seen[code] = true
+	}
+	if len(codes) != 10 {
+		t.Fatalf("got %d codes", len(codes))
+	}
+}
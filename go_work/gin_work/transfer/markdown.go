@@ -0,0 +1,216 @@
+package transfer
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"gin_work/models"
+	"gopkg.in/yaml.v3"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// zip中单个文件和全部文件解压后的大小上限，防止压缩炸弹
+const (
+	maxMarkdownFile  = 32 << 20
+	maxMarkdownTotal = 512 << 20
+)
+
+// 解压后的总大小超过上限，中止导入
+var errZipTooLarge = errors.New("zip content too large")
+
+// 导出信息的文件名
+const metaFile = "export.yaml"
+
+const frontMatterDelim = "---\n"
+
+type markdownWriter struct {
+	zw *zip.Writer
+}
+
+// NewMarkdownWriter 写出Markdown文件的zip包，记录的字段写在YAML front matter中，正文作为Markdown内容
+//
+//	export.yaml                    导出信息
+//	users/<用户ID>.md               正文为个人简介
+//	blogs/<博客ID>.md
+//	comments/<博客ID>/<评论ID>.md
+func NewMarkdownWriter(w io.Writer) Writer {
+	return &markdownWriter{zw: zip.NewWriter(w)}
+}
+
+func (m *markdownWriter) Write(kind string, record interface{}) error {
+	var name, body string
+	switch r := record.(type) {
+	case *models.ExportMeta:
+		name = metaFile
+	case *models.ExportUser:
+		name, body = fmt.Sprintf("users/%d.md", r.Id), r.Bio
+	case *models.ExportBlog:
+		name, body = fmt.Sprintf("blogs/%d.md", r.Id), r.Content
+	case *models.ExportComment:
+		name, body = fmt.Sprintf("comments/%d/%d.md", r.BlogId, r.Id), r.Content
+	default:
+		return fmt.Errorf("unknown record type %q", kind)
+	}
+	header, err := yaml.Marshal(record)
+	if err != nil {
+		return err
+	}
+	w, err := m.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	if name == metaFile {
+		_, err = w.Write(header)
+		return err
+	}
+	_, err = io.WriteString(w, frontMatterDelim+string(header)+frontMatterDelim+"\n"+body)
+	return err
+}
+
+func (m *markdownWriter) Close() error {
+	return m.zw.Close()
+}
+
+// zip中的一条记录
+type entry struct {
+	ref    string
+	id     int
+	record interface{}
+}
+
+// ReadMarkdownZip 读取 NewMarkdownWriter 写出的zip包，引用为文件名
+// 按导出信息、用户、博客、评论的顺序交给 fn，同类记录按原ID排序，保证父评论在回复之前
+func ReadMarkdownZip(r io.ReaderAt, size int64, fn ReadFunc) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return errors.New("invalid zip file")
+	}
+	groups := map[string][]entry{}
+	// 解析后的记录全部保存在内存中，限制解压后的总大小
+	remaining := int64(maxMarkdownTotal)
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		kind, record, n, err := readFile(f, remaining)
+		remaining -= n
+		if err == errZipTooLarge {
+			return err
+		}
+		if err != nil {
+			fn(f.Name, nil, err)
+			continue
+		}
+		groups[kind] = append(groups[kind], entry{ref: f.Name, id: recordId(record), record: record})
+	}
+	for _, kind := range []string{models.RecordMeta, models.RecordUser, models.RecordBlog, models.RecordComment} {
+		entries := groups[kind]
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].id < entries[j].id })
+		for _, e := range entries {
+			fn(e.ref, e.record, nil)
+		}
+	}
+	return nil
+}
+
+// 根据文件路径判断记录类型并解析，返回解压后读取的字节数
+// remaining 为剩余的总大小额度
+func readFile(f *zip.File, remaining int64) (string, interface{}, int64, error) {
+	var kind string
+	switch dir := strings.SplitN(f.Name, "/", 2)[0]; {
+	case f.Name == metaFile:
+		kind = models.RecordMeta
+	case path.Ext(f.Name) != ".md":
+		return "", nil, 0, errors.New("unknown file")
+	case dir == "users":
+		kind = models.RecordUser
+	case dir == "blogs":
+		kind = models.RecordBlog
+	case dir == "comments":
+		kind = models.RecordComment
+	default:
+		return "", nil, 0, errors.New("unknown file")
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return "", nil, 0, errors.New("read file error")
+	}
+	defer rc.Close()
+	limit := int64(maxMarkdownFile)
+	if remaining < limit {
+		limit = remaining
+	}
+	data, err := io.ReadAll(io.LimitReader(rc, limit+1))
+	n := int64(len(data))
+	if err != nil {
+		return "", nil, n, errors.New("read file error")
+	}
+	if n > maxMarkdownFile {
+		return "", nil, n, errors.New("file too large")
+	}
+	if n > remaining {
+		return "", nil, n, errZipTooLarge
+	}
+	record, _ := newRecord(kind)
+	if kind == models.RecordMeta {
+		if err = yaml.Unmarshal(data, record); err != nil {
+			return "", nil, n, errors.New("invalid yaml")
+		}
+		return kind, record, n, nil
+	}
+	header, body, err := splitFrontMatter(data)
+	if err != nil {
+		return "", nil, n, err
+	}
+	if err = yaml.Unmarshal(header, record); err != nil {
+		return "", nil, n, errors.New("invalid front matter")
+	}
+	switch r := record.(type) {
+	case *models.ExportUser:
+		r.Bio = body
+	case *models.ExportBlog:
+		r.Content = body
+	case *models.ExportComment:
+		r.Content = body
+	}
+	return kind, record, n, nil
+}
+
+// 拆分 front matter 和正文，正文前的一个空行不属于正文
+func splitFrontMatter(data []byte) ([]byte, string, error) {
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	if !bytes.HasPrefix(data, []byte(frontMatterDelim)) {
+		return nil, "", errors.New("missing front matter")
+	}
+	data = data[len(frontMatterDelim):]
+	var header []byte
+	if bytes.HasPrefix(data, []byte(frontMatterDelim)) {
+		// 空的 front matter
+		data = data[len(frontMatterDelim):]
+	} else {
+		end := bytes.Index(data, []byte("\n"+frontMatterDelim))
+		if end < 0 {
+			return nil, "", errors.New("unterminated front matter")
+		}
+		header = data[:end+1]
+		data = data[end+1+len(frontMatterDelim):]
+	}
+	return header, string(bytes.TrimPrefix(data, []byte("\n"))), nil
+}
+
+func recordId(record interface{}) int {
+	switch r := record.(type) {
+	case *models.ExportUser:
+		return r.Id
+	case *models.ExportBlog:
+		return r.Id
+	case *models.ExportComment:
+		return r.Id
+	}
+	return 0
+}
@@ -0,0 +1,154 @@
+package transfer
+
+import (
+	"archive/zip"
+	"bytes"
+	"gin_work/models"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 用给定的文件内容生成zip包
+func buildZip(t *testing.T, files ...[2]string) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: f[0], Method: zip.Deflate})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = io.WriteString(w, f[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func readZip(t *testing.T, r *bytes.Reader) ([]readItem, error) {
+	t.Helper()
+	var items []readItem
+	err := ReadMarkdownZip(r, r.Size(), collect(&items))
+	return items, err
+}
+
+func TestMarkdownRoundTrip(t *testing.T) {
+	records := exportRecords()
+	var buf bytes.Buffer
+	w := NewMarkdownWriter(&buf)
+	// 写入顺序打乱，读取时按类型和ID排序
+	for _, i := range []int{5, 3, 2, 0, 4, 1} {
+		if err := w.Write(kindOf(records[i]), records[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(models.RecordUser, "not a record"); err == nil {
+		t.Fatal("unknown record written")
+	}
+
+	items, err := readZip(t, bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertRecords(t, items, records)
+	refs := []string{metaFile, "users/1.md", "users/2.md", "blogs/3.md", "comments/3/4.md", "comments/3/5.md"}
+	for i, ref := range refs {
+		if items[i].ref != ref {
+			t.Fatalf("ref %d = %q, want %q", i, items[i].ref, ref)
+		}
+	}
+}
+
+func TestReadMarkdownFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		record  interface{}
+		err     string
+	}{
+		{"crlf", "---\r\nid: 7\r\ntitle: 标题\r\n---\r\n\r\n第一行\r\n第二行\r\n",
+			&models.ExportBlog{Id: 7, Title: "标题", Content: "第一行\n第二行\n"}, ""},
+		{"empty front matter", "---\n---\n\nbody", &models.ExportBlog{Content: "body"}, ""},
+		{"empty front matter crlf", "---\r\n---\r\nbody", &models.ExportBlog{Content: "body"}, ""},
+		{"no blank line", "---\nid: 1\n---\nbody", &models.ExportBlog{Id: 1, Content: "body"}, ""},
+		{"empty body", "---\nid: 1\n---\n", &models.ExportBlog{Id: 1}, ""},
+		{"missing front matter", "# title\n", nil, "missing front matter"},
+		{"unterminated", "---\nid: 1\nbody", nil, "unterminated front matter"},
+		{"invalid yaml", "---\nid: [\n---\n", nil, "invalid front matter"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, err := readZip(t, buildZip(t, [2]string{"blogs/1.md", tt.content}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []readItem{{ref: "blogs/1.md", record: tt.record, err: tt.err}}
+			if !reflect.DeepEqual(items, want) {
+				t.Fatalf("items = %+v, want %+v", items, want)
+			}
+		})
+	}
+}
+
+// 无法识别的文件单独报错，目录忽略
+func TestReadMarkdownUnknownFiles(t *testing.T) {
+	items, err := readZip(t, buildZip(t,
+		[2]string{"blogs/", ""},
+		[2]string{"blogs/1.txt", "text"},
+		[2]string{"drafts/1.md", "---\n---\n"},
+		[2]string{"users/1.md", "---\nid: 1\nuserName: alice\n---\n"},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []readItem{
+		{ref: "blogs/1.txt", err: "unknown file"},
+		{ref: "drafts/1.md", err: "unknown file"},
+		{ref: "users/1.md", record: &models.ExportUser{Id: 1, UserName: "alice"}},
+	}
+	if !reflect.DeepEqual(items, want) {
+		t.Fatalf("items = %+v, want %+v", items, want)
+	}
+
+	if err = ReadMarkdownZip(strings.NewReader("not a zip"), 9, collect(&items)); err == nil {
+		t.Fatal("invalid zip accepted")
+	}
+}
+
+// 单个文件超过上限时跳过该文件，解压后的总大小超过上限时中止导入
+func TestReadMarkdownSizeLimits(t *testing.T) {
+	body := func(n int) string {
+		header := "---\nid: 1\n---\n"
+		return header + strings.Repeat("a", n-len(header))
+	}
+	items, err := readZip(t, buildZip(t,
+		[2]string{"blogs/1.md", body(maxMarkdownFile)},
+		[2]string{"blogs/2.md", body(maxMarkdownFile + 1)},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].ref != "blogs/2.md" || items[0].err != "file too large" ||
+		items[1].ref != "blogs/1.md" || items[1].err != "" {
+		t.Fatalf("items = %+v", items)
+	}
+
+	// 每个文件都在单个文件的上限内，合计超过总大小上限
+	big := strings.Repeat("a", maxMarkdownFile)
+	var files [][2]string
+	for i := 0; i < maxMarkdownTotal/maxMarkdownFile; i++ {
+		files = append(files, [2]string{"blogs/big.md", big})
+	}
+	files = append(files, [2]string{"users/1.md", "---\nid: 1\n---\n"})
+	if _, err = readZip(t, buildZip(t, files...)); err != errZipTooLarge {
+		t.Fatalf("total err = %v, want %v", err, errZipTooLarge)
+	}
+}
@@ -0,0 +1,104 @@
+package transfer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gin_work/models"
+	"io"
+)
+
+// Writer 按某种格式写出导出的记录
+type Writer interface {
+	Write(kind string, record interface{}) error
+	Close() error
+}
+
+// ReadFunc 接收读取到的一条记录，err 不为空时表示这条记录无法解析
+type ReadFunc func(ref string, record interface{}, err error)
+
+// JSON Lines 中的一行
+type line struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+type jsonlWriter struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+// NewJSONLWriter 每行写出一条 {"type":..., "data":...} 记录
+func NewJSONLWriter(w io.Writer) Writer {
+	buf := bufio.NewWriter(w)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	return &jsonlWriter{buf: buf, enc: enc}
+}
+
+func (j *jsonlWriter) Write(kind string, record interface{}) error {
+	return j.enc.Encode(line{Type: kind, Data: record})
+}
+
+func (j *jsonlWriter) Close() error {
+	return j.buf.Flush()
+}
+
+// ReadJSONL 逐行读取记录，引用为行号，空行忽略
+// 返回的错误只表示读取失败，单条记录的错误交给 fn 处理
+func ReadJSONL(r io.Reader, fn ReadFunc) error {
+	br := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		data, err := br.ReadBytes('\n')
+		if len(data) > 0 {
+			decodeLine(fmt.Sprintf("line %d", n), data, fn)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func decodeLine(ref string, data []byte, fn ReadFunc) {
+	var l struct {
+		Type string          `json:"type"`
+		Data json.RawMessage `json:"data"`
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return
+	}
+	if err := json.Unmarshal(data, &l); err != nil {
+		fn(ref, nil, errors.New("invalid json"))
+		return
+	}
+	record, err := newRecord(l.Type)
+	if err != nil {
+		fn(ref, nil, err)
+		return
+	}
+	if err = json.Unmarshal(l.Data, record); err != nil {
+		fn(ref, nil, fmt.Errorf("invalid %s record", l.Type))
+		return
+	}
+	fn(ref, record, nil)
+}
+
+// 根据类型创建空记录
+func newRecord(kind string) (interface{}, error) {
+	switch kind {
+	case models.RecordMeta:
+		return &models.ExportMeta{}, nil
+	case models.RecordUser:
+		return &models.ExportUser{}, nil
+	case models.RecordBlog:
+		return &models.ExportBlog{}, nil
+	case models.RecordComment:
+		return &models.ExportComment{}, nil
+	}
+	return nil, fmt.Errorf("unknown record type %q", kind)
+}
@@ -0,0 +1,125 @@
+package transfer
+
+import (
+	"bytes"
+	"gin_work/models"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 读取到的一条记录或错误
+type readItem struct {
+	ref    string
+	record interface{}
+	err    string
+}
+
+// 收集 ReadFunc 收到的全部记录
+func collect(items *[]readItem) ReadFunc {
+	return func(ref string, record interface{}, err error) {
+		item := readItem{ref: ref, record: record}
+		if err != nil {
+			item.err = err.Error()
+		}
+		*items = append(*items, item)
+	}
+}
+
+// 导出的测试数据，按导出顺序排列
+func exportRecords() []interface{} {
+	created := time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC)
+	edited := created.Add(time.Hour)
+	return []interface{}{
+		&models.ExportMeta{Origin: "https://old.example.com", Version: models.ExportVersion, ExportedAt: created},
+		&models.ExportUser{Id: 1, UserName: "alice", Email: "alice@example.com", Role: models.RoleAdmin,
+			EmailVerified: true, DisplayName: "爱丽丝", CreatedAt: created, Bio: "第一行\n\n第二行"},
+		&models.ExportUser{Id: 2, UserName: "bob", CreatedAt: created},
+		&models.ExportBlog{Id: 3, Author: "alice", Title: "Title: with colon", Status: "published", PublishAt: &edited,
+			Category: []string{"技术", "Go"}, Tags: []string{"go", "yaml"}, CreatedAt: created, UpdatedAt: edited,
+			// 正文中的分隔线和开头的空行不能影响解析
+			Content: "\n---\nnot: front matter\n---\n\n# 标题\n"},
+		&models.ExportComment{Id: 4, BlogId: 3, Author: "bob", Status: "approved", CreatedAt: created, Content: "parent"},
+		&models.ExportComment{Id: 5, BlogId: 3, ParentId: 4, Status: "removed", CreatedAt: created,
+			EditedAt: &edited, RemovedAt: &edited, Content: ""},
+	}
+}
+
+func kindOf(record interface{}) string {
+	switch record.(type) {
+	case *models.ExportMeta:
+		return models.RecordMeta
+	case *models.ExportUser:
+		return models.RecordUser
+	case *models.ExportBlog:
+		return models.RecordBlog
+	}
+	return models.RecordComment
+}
+
+// 比较读取到的记录和导出的记录
+func assertRecords(t *testing.T, items []readItem, want []interface{}) {
+	t.Helper()
+	if len(items) != len(want) {
+		t.Fatalf("read %d records, want %d: %+v", len(items), len(want), items)
+	}
+	for i, item := range items {
+		if item.err != "" {
+			t.Fatalf("%s: %s", item.ref, item.err)
+		}
+		if !reflect.DeepEqual(item.record, want[i]) {
+			t.Fatalf("%s = %+v, want %+v", item.ref, item.record, want[i])
+		}
+	}
+}
+
+func TestJSONLRoundTrip(t *testing.T) {
+	records := exportRecords()
+	var buf bytes.Buffer
+	w := NewJSONLWriter(&buf)
+	for _, record := range records {
+		if err := w.Write(kindOf(record), record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != len(records) {
+		t.Fatalf("%d lines, want %d", lines, len(records))
+	}
+
+	var items []readItem
+	if err := ReadJSONL(&buf, collect(&items)); err != nil {
+		t.Fatal(err)
+	}
+	assertRecords(t, items, records)
+	if items[0].ref != "line 1" || items[5].ref != "line 6" {
+		t.Fatalf("refs = %q, %q", items[0].ref, items[5].ref)
+	}
+}
+
+// 空行跳过，CRLF换行和最后一行没有换行符都可以读取，错误的行不影响其他行
+func TestReadJSONL(t *testing.T) {
+	input := "{\"type\":\"user\",\"data\":{\"id\":1,\"userName\":\"alice\"}}\r\n" +
+		"\r\n" +
+		"not json\n" +
+		"{\"type\":\"unknown\",\"data\":{}}\n" +
+		"{\"type\":\"blog\",\"data\":{\"id\":\"x\"}}\n" +
+		"{\"type\":\"blog\",\"data\":{\"id\":2,\"content\":\"<p>&</p>\"}}"
+	var items []readItem
+	if err := ReadJSONL(strings.NewReader(input), collect(&items)); err != nil {
+		t.Fatal(err)
+	}
+	want := []readItem{
+		{ref: "line 1", record: &models.ExportUser{Id: 1, UserName: "alice"}},
+		{ref: "line 3", err: "invalid json"},
+		{ref: "line 4", err: `unknown record type "unknown"`},
+		{ref: "line 5", err: "invalid blog record"},
+		{ref: "line 6", record: &models.ExportBlog{Id: 2, Content: "<p>&</p>"}},
+	}
+	if !reflect.DeepEqual(items, want) {
+		t.Fatalf("items = %+v, want %+v", items, want)
+	}
+}